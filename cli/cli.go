@@ -0,0 +1,231 @@
+// Package cli is the agnos admin command. It migrates and seeds the
+// database and manages hospitals, staff and patients from a shell, through
+// the same repositories and services as the API.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/database"
+	"agnos_candidate_assignment/fieldcrypt"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
+	"agnos_candidate_assignment/repositories"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: agnos <command> [flags]
+
+  migrate up|down|status [-steps n]
+  seed [-fixtures dir]
+  drop -confirm
+  hospital create -name name [-api-url url] [-his-adapter type]
+  hospital list
+  staff create -hospital h -username u -role r [-password p]
+  staff reset-password -hospital h -username u [-password p]
+  staff disable -hospital h -username u
+  patient import -hospital h [-file path]
+  patient export -hospital h [-role r] [-file path]
+  patient reencrypt [-batch n]
+  token mint -hospital h -username u [-ttl d]
+
+A hospital is given by name or ID. Staff passwords left out are generated
+and printed. Run a command with -h for its flags.
+`
+
+type handler func(e *env, args []string) error
+
+var commands = map[string]handler{
+	"migrate": migrate,
+	"seed":    seed,
+	"drop":    drop,
+	"hospital": group("hospital", map[string]handler{
+		"create": createHospital,
+		"list":   listHospitals,
+	}),
+	"staff": group("staff", map[string]handler{
+		"create":         createStaff,
+		"reset-password": resetStaffPassword,
+		"disable":        disableStaff,
+	}),
+	"patient": group("patient", map[string]handler{
+		"import":    importPatients,
+		"export":    exportPatients,
+		"reencrypt": reencryptPatients,
+	}),
+	"token": group("token", map[string]handler{
+		"mint": mintToken,
+	}),
+}
+
+// usageError is a malformed command line; Run prints it with the usage.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// errBadFlags means the flag package has already reported the problem.
+var errBadFlags = errors.New("bad flags")
+
+// Run runs the command in args and returns the process exit code: 0 on
+// success, 2 for a malformed command line and 1 for any other failure.
+func Run(args []string, conf *config.Config, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{conf: conf, stdin: stdin, stdout: stdout, stderr: stderr}
+	err := dispatch(e, args)
+	if e.db != nil {
+		if sqlDB, dbErr := e.db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+	}
+
+	var bad usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errBadFlags):
+		return 2
+	case errors.As(err, &bad):
+		fmt.Fprintf(stderr, "agnos: %s\n\n%s", bad, usage)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "agnos: %v\n", err)
+		return 1
+	}
+}
+
+func dispatch(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageError("missing command")
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(e.stdout, usage)
+		return nil
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		return usageError(fmt.Sprintf("unknown command %q", args[0]))
+	}
+	return run(e, args[1:])
+}
+
+// group dispatches to the subcommands of a command such as "staff".
+func group(name string, subcommands map[string]handler) handler {
+	return func(e *env, args []string) error {
+		if len(args) == 0 {
+			return usageError(name + ": missing subcommand")
+		}
+		run, ok := subcommands[args[0]]
+		if !ok {
+			return usageError(fmt.Sprintf("%s: unknown subcommand %q", name, args[0]))
+		}
+		return run(e, args[1:])
+	}
+}
+
+// env is what a command runs with. The database and field keys are opened
+// on first use, so a command rejected for its flags touches neither.
+type env struct {
+	conf   *config.Config
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	db   *gorm.DB
+	keys *fieldcrypt.Keyring
+}
+
+func (e *env) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("agnos "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// parse parses args into fs, requiring the named flags to be non-empty and
+// allowing no arguments after the flags.
+func parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errBadFlags
+	}
+	command := strings.TrimPrefix(fs.Name(), "agnos ")
+	if fs.NArg() > 0 {
+		return usageError(fmt.Sprintf("%s: unexpected argument %q", command, fs.Arg(0)))
+	}
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			return usageError(fmt.Sprintf("%s: -%s is required", command, name))
+		}
+	}
+	return nil
+}
+
+func (e *env) database() (*gorm.DB, error) {
+	if e.db == nil {
+		if e.conf.DatabaseUrl == "" {
+			return nil, errors.New("DATABASE_URL is not set")
+		}
+		db, err := database.NewPostgresConnectionNoMigrate(e.conf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to db: %w", err)
+		}
+		e.db = db
+	}
+	return e.db, nil
+}
+
+func (e *env) fieldKeys() (*fieldcrypt.Keyring, error) {
+	if e.keys == nil {
+		keys, err := fieldcrypt.Load(e.conf.FieldKeys, e.conf.FieldKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load field encryption keys (FIELD_KEYS or FIELD_KEYS_FILE): %w", err)
+		}
+		e.keys = keys
+	}
+	return e.keys, nil
+}
+
+func (e *env) passwordPolicy() (*password.Policy, error) {
+	policy := &password.Policy{MinLength: e.conf.PasswordMinLength, MinClasses: e.conf.PasswordMinClasses}
+	if e.conf.PasswordBreachedFile != "" {
+		if _, err := policy.LoadBreached(e.conf.PasswordBreachedFile); err != nil {
+			return nil, fmt.Errorf("failed to load breached password list: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// hospital finds a hospital by ID when ref is a number and by name
+// otherwise, as the API's :hospital path segment does.
+func (e *env) hospital(ref string) (*models.Hospital, error) {
+	db, err := e.database()
+	if err != nil {
+		return nil, err
+	}
+	repo := repositories.NewHospitalRepository(db)
+	var h *models.Hospital
+	if id, convErr := strconv.ParseUint(ref, 10, 0); convErr == nil {
+		h, err = repo.FindByID(uint(id))
+	} else {
+		h, err = repo.FindByName(ref)
+	}
+	if errors.Is(err, repositories.ErrHospitalNotFound) {
+		return nil, fmt.Errorf("hospital %q not found", ref)
+	}
+	return h, err
+}
+
+func (e *env) staffMember(h *models.Hospital, username string) (*models.Staff, error) {
+	staff, err := repositories.NewStaffRepository(e.db).GetByUsenameAndHospital(username, h.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("staff %q not found in %s", username, h.Name)
+	}
+	return staff, err
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+func createHospital(e *env, args []string) error {
+	fs := e.flags("hospital create")
+	name := fs.String("name", "", "hospital name, also its URL segment")
+	apiURL := fs.String("api-url", "", "base URL of the hospital's HIS")
+	adapter := fs.String("his-adapter", "", "HIS adapter type (default agnos)")
+	if err := parse(fs, args, "name"); err != nil {
+		return err
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+	h := &models.Hospital{Name: strings.TrimSpace(*name), HISAdapter: *adapter}
+	if *apiURL != "" {
+		h.APIURL = apiURL
+	}
+	err = repositories.NewHospitalRepository(db).Create(h)
+	if errors.Is(err, repositories.ErrDuplicateKey) {
+		return fmt.Errorf("hospital %q already exists", h.Name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "created hospital %s (id %d)\n", h.Name, h.ID)
+	return nil
+}
+
+func listHospitals(e *env, args []string) error {
+	if err := parse(e.flags("hospital list"), args); err != nil {
+		return err
+	}
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+	hospitals, err := repositories.NewHospitalRepository(db).List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tHIS\tSTATUS")
+	for _, h := range hospitals {
+		hisURL := "-"
+		if h.APIURL != nil {
+			hisURL = *h.APIURL
+		}
+		status := "active"
+		if !h.Active() {
+			status = "deactivated"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", h.ID, h.Name, hisURL, status)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+
+	"agnos_candidate_assignment/database"
+)
+
+// migrate applies, reverts or lists the schema migrations built into the
+// API (database/migrations).
+func migrate(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageError("migrate: missing up, down or status")
+	}
+	action := args[0]
+	fs := e.flags("migrate " + action)
+	steps := fs.Int("steps", 1, "migrations reverted by down")
+	if err := parse(fs, args[1:]); err != nil {
+		return err
+	}
+	switch {
+	case action != "up" && action != "down" && action != "status":
+		return usageError(fmt.Sprintf("migrate: unknown action %q", action))
+	case *steps < 1:
+		return usageError("migrate down: -steps must be at least 1")
+	}
+
+	migrator, err := e.migrator()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Fprintf(e.stdout, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("migrate up: %w", err)
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(e.stdout, "no pending migrations")
+		}
+	case "down":
+		return revert(e, migrator, *steps)
+	case "status":
+		states, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("migrate status: %w", err)
+		}
+		for _, s := range states {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied, file missing"
+			case s.Modified:
+				state = "applied, file modified"
+			case s.AppliedAt != nil:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(e.stdout, "%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+	}
+	return nil
+}
+
+// drop reverts every migration, deleting all tables and their data.
+func drop(e *env, args []string) error {
+	fs := e.flags("drop")
+	confirm := fs.Bool("confirm", false, "confirm that every table and its data should be deleted")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if !*confirm {
+		return usageError("drop: deletes every table and its data; pass -confirm to go ahead")
+	}
+
+	migrator, err := e.migrator()
+	if err != nil {
+		return err
+	}
+	migrations, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	return revert(e, migrator, len(migrations))
+}
+
+func (e *env) migrator() (*database.Migrator, error) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	db, err := e.database()
+	if err != nil {
+		return nil, err
+	}
+	return database.NewMigrator(db, migrations), nil
+}
+
+func revert(e *env, migrator *database.Migrator, steps int) error {
+	reverted, err := migrator.Down(steps)
+	for _, m := range reverted {
+		fmt.Fprintf(e.stdout, "reverted %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate down: %w", err)
+	}
+	if len(reverted) == 0 {
+		fmt.Fprintln(e.stdout, "no applied migrations")
+	}
+	return nil
+}
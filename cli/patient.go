@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+)
+
+func (e *env) patientService() (*services.PatientService, error) {
+	keys, err := e.fieldKeys()
+	if err != nil {
+		return nil, err
+	}
+	db, err := e.database()
+	if err != nil {
+		return nil, err
+	}
+	return services.NewPatientService(repositories.NewPatientRepository(db, keys), repositories.NewHospitalRepository(db), his.NewRegistry(e.conf.HISTimeout)), nil
+}
+
+// importPatients reads patients in the API's JSON form, one object after
+// another as export writes them, and upserts each by HN. It stops at the
+// first invalid patient; running it again is safe.
+func importPatients(e *env, args []string) error {
+	fs := e.flags("patient import")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	file := fs.String("file", "-", "file to read, - for stdin")
+	if err := parse(fs, args, "hospital"); err != nil {
+		return err
+	}
+
+	in := e.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	patientService, err := e.patientService()
+	if err != nil {
+		return err
+	}
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(in)
+	n := 0
+	for {
+		var p models.Patient
+		err := dec.Decode(&p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("patient %d: %w", n+1, err)
+		}
+		if err := patientService.Import(h.ID, &p); err != nil {
+			return fmt.Errorf("patient %d (%s): %w", n+1, p.PatientHN, err)
+		}
+		n++
+	}
+	fmt.Fprintf(e.stderr, "imported %d patients into %s\n", n, h.Name)
+	return nil
+}
+
+// exportPatients writes the hospital's patients as JSON lines, shaped by
+// the hospital's field policy for role as the API would show them.
+func exportPatients(e *env, args []string) error {
+	fs := e.flags("patient export")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	role := fs.String("role", models.RoleAdmin, "role whose field policy applies")
+	file := fs.String("file", "-", "file to write, - for stdout")
+	if err := parse(fs, args, "hospital", "role"); err != nil {
+		return err
+	}
+
+	patientService, err := e.patientService()
+	if err != nil {
+		return err
+	}
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+	policy, err := services.NewFieldPolicyService(repositories.NewFieldPolicyRepository(e.db), repositories.NewRoleRepository(e.db)).PolicyFor(h.ID, *role)
+	if err != nil {
+		return err
+	}
+
+	out := e.stdout
+	var f *os.File
+	if *file != "-" {
+		if f, err = os.Create(*file); err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	n := 0
+	err = patientService.Export(h.ID, policy, func(record interface{}) error {
+		n++
+		return enc.Encode(record)
+	})
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(e.stderr, "exported %d patients of %s\n", n, h.Name)
+	return nil
+}
+
+// reencryptPatients re-encrypts every patient row, and every staff TOTP
+// secret, under the active field encryption key. To rotate, put a new key first in FIELD_KEYS (or the
+// FIELD_KEYS_FILE) keeping the old ones after it, restart the API so new
+// writes use it, run this, and then drop the old keys.
+func reencryptPatients(e *env, args []string) error {
+	fs := e.flags("patient reencrypt")
+	batchSize := fs.Int("batch", 500, "rows re-encrypted per transaction")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *batchSize < 1 {
+		return usageError("patient reencrypt: -batch must be at least 1")
+	}
+
+	keys, err := e.fieldKeys()
+	if err != nil {
+		return err
+	}
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+	n, err := repositories.NewPatientRepository(db, keys).Reencrypt(*batchSize)
+	if err != nil {
+		return fmt.Errorf("re-encryption stopped after %d rows: %w", n, err)
+	}
+	fmt.Fprintf(e.stdout, "re-encrypted %d patient rows under key %q\n", n, keys.ActiveKeyID())
+
+	n, err = repositories.NewMFARepository(db, keys).Reencrypt()
+	if err != nil {
+		return fmt.Errorf("re-encrypting TOTP secrets: %w", err)
+	}
+	fmt.Fprintf(e.stdout, "re-encrypted %d staff TOTP secrets under key %q\n", n, keys.ActiveKeyID())
+	return nil
+}
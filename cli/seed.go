@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agnos_candidate_assignment/database"
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"gorm.io/gorm"
+)
+
+// Fixtures is a data set for seed, read from hospitals.json, staff.json and
+// patients.json in one directory. Each file holds a JSON array and may be
+// left out. Staff and patients name their hospital, which must be one of
+// Hospitals.
+type Fixtures struct {
+	Hospitals []HospitalFixture
+	Staff     []StaffFixture
+	Patients  []PatientFixture
+}
+
+type HospitalFixture struct {
+	Name       string  `json:"name"`
+	APIURL     *string `json:"api_url"`
+	HISAdapter string  `json:"his_adapter"`
+}
+
+// StaffFixture is a staff account. Its password is stored as given, without
+// the password policy, so fixtures can hold short, memorable ones.
+type StaffFixture struct {
+	Username string `json:"username"`
+	Hospital string `json:"hospital"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// PatientFixture is a patient in the API's JSON form, with "hospital"
+// naming its hospital.
+type PatientFixture struct {
+	Hospital string `json:"hospital"`
+	models.Patient
+}
+
+// LoadFixtures reads the fixtures in dir and checks that every staff member
+// and patient names a hospital among them.
+func LoadFixtures(dir string) (*Fixtures, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	fx := &Fixtures{}
+	for _, file := range []struct {
+		name string
+		into interface{}
+	}{
+		{"hospitals.json", &fx.Hospitals},
+		{"staff.json", &fx.Staff},
+		{"patients.json", &fx.Patients},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, file.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, file.into); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.name, err)
+		}
+	}
+
+	hospitals := map[string]bool{}
+	for i, h := range fx.Hospitals {
+		if h.Name == "" {
+			return nil, fmt.Errorf("hospitals.json: hospital %d has no name", i+1)
+		}
+		hospitals[h.Name] = true
+	}
+	for _, s := range fx.Staff {
+		if s.Username == "" || s.Password == "" || s.Role == "" {
+			return nil, fmt.Errorf("staff.json: staff %q needs a username, password and role", s.Username)
+		}
+		if !hospitals[s.Hospital] {
+			return nil, fmt.Errorf("staff.json: staff %s: unknown hospital %q", s.Username, s.Hospital)
+		}
+	}
+	for _, p := range fx.Patients {
+		if !hospitals[p.Hospital] {
+			return nil, fmt.Errorf("patients.json: patient %s: unknown hospital %q", p.PatientHN, p.Hospital)
+		}
+	}
+	return fx, nil
+}
+
+// seed migrates the database and loads a fixture directory into it. It can
+// be run again: hospitals and staff that exist are left alone and patients
+// are upserted by HN.
+func seed(e *env, args []string) error {
+	fs := e.flags("seed")
+	dir := fs.String("fixtures", filepath.Join("fixtures", "dev"), "directory holding hospitals.json, staff.json and patients.json")
+	if err := parse(fs, args, "fixtures"); err != nil {
+		return err
+	}
+
+	fx, err := LoadFixtures(*dir)
+	if err != nil {
+		return err
+	}
+	keys, err := e.fieldKeys()
+	if err != nil {
+		return err
+	}
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	applied, err := database.Migrate(db)
+	if err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	for _, m := range applied {
+		fmt.Fprintf(e.stdout, "applied %04d_%s\n", m.Version, m.Name)
+	}
+
+	hospitalRepo := repositories.NewHospitalRepository(db)
+	staffRepo := repositories.NewStaffRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	if err := roleRepo.EnsureDefaults(models.DefaultRoles); err != nil {
+		return fmt.Errorf("failed to create default roles: %w", err)
+	}
+
+	hospitalIDs := make(map[string]uint, len(fx.Hospitals))
+	hospitalsCreated := 0
+	for _, f := range fx.Hospitals {
+		h, err := hospitalRepo.FindByName(f.Name)
+		if errors.Is(err, repositories.ErrHospitalNotFound) {
+			h = &models.Hospital{Name: f.Name, APIURL: f.APIURL, HISAdapter: f.HISAdapter}
+			err = hospitalRepo.Create(h)
+			hospitalsCreated++
+		}
+		if err != nil {
+			return fmt.Errorf("hospital %s: %w", f.Name, err)
+		}
+		hospitalIDs[f.Name] = h.ID
+	}
+
+	hasher := password.Hasher{Cost: e.conf.BcryptCost}
+	staffCreated := 0
+	for _, f := range fx.Staff {
+		hospitalID := hospitalIDs[f.Hospital]
+		_, err := staffRepo.GetByUsenameAndHospital(f.Username, hospitalID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("staff %s: %w", f.Username, err)
+		}
+		role, err := roleRepo.FindByName(f.Role)
+		if err != nil {
+			return fmt.Errorf("staff %s: role %s: %w", f.Username, f.Role, err)
+		}
+		hash, err := hasher.Hash(f.Password)
+		if err != nil {
+			return fmt.Errorf("staff %s: %w", f.Username, err)
+		}
+		err = staffRepo.CreateStaff(&models.Staff{
+			UserName:     f.Username,
+			PasswordHash: hash,
+			HospitalID:   hospitalID,
+			RoleID:       &role.ID,
+			Status:       models.StaffActive,
+		})
+		if err != nil {
+			return fmt.Errorf("staff %s: %w", f.Username, err)
+		}
+		staffCreated++
+	}
+
+	patientService := services.NewPatientService(repositories.NewPatientRepository(db, keys), hospitalRepo, his.NewRegistry(e.conf.HISTimeout))
+	for _, f := range fx.Patients {
+		p := f.Patient
+		if err := patientService.Import(hospitalIDs[f.Hospital], &p); err != nil {
+			return fmt.Errorf("patient %s: %w", f.PatientHN, err)
+		}
+	}
+
+	fmt.Fprintf(e.stdout, "seeded %d hospitals (%d new), %d staff (%d new), %d patients\n",
+		len(fx.Hospitals), hospitalsCreated, len(fx.Staff), staffCreated, len(fx.Patients))
+	return nil
+}
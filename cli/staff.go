@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"gorm.io/gorm"
+)
+
+func createStaff(e *env, args []string) error {
+	fs := e.flags("staff create")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	username := fs.String("username", "", "login name")
+	roleName := fs.String("role", "", "role, e.g. "+models.RoleAdmin+" or "+models.RoleDoctor)
+	pw := fs.String("password", "", "password (generated and printed if left out)")
+	if err := parse(fs, args, "hospital", "username", "role"); err != nil {
+		return err
+	}
+
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+	role, err := repositories.NewRoleRepository(e.db).FindByName(*roleName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("role %q not found", *roleName)
+	}
+	if err != nil {
+		return err
+	}
+	secret, hash, err := e.newPassword(*pw, *username)
+	if err != nil {
+		return err
+	}
+
+	staff := &models.Staff{
+		UserName:     *username,
+		PasswordHash: hash,
+		HospitalID:   h.ID,
+		RoleID:       &role.ID,
+		Status:       models.StaffActive,
+	}
+	err = repositories.NewStaffRepository(e.db).CreateStaff(staff)
+	if errors.Is(err, repositories.ErrDuplicateKey) {
+		return fmt.Errorf("username %q is taken", *username)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "created %s %s (id %d) in %s\n", role.Name, staff.UserName, staff.ID, h.Name)
+	if *pw == "" {
+		fmt.Fprintf(e.stdout, "password: %s\n", secret)
+	}
+	return nil
+}
+
+// resetStaffPassword sets a new password and, as a reset through the API
+// does, ends the staff member's sessions and clears their lockout.
+func resetStaffPassword(e *env, args []string) error {
+	fs := e.flags("staff reset-password")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	username := fs.String("username", "", "login name")
+	pw := fs.String("password", "", "new password (generated and printed if left out)")
+	if err := parse(fs, args, "hospital", "username"); err != nil {
+		return err
+	}
+
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+	staff, err := e.staffMember(h, *username)
+	if err != nil {
+		return err
+	}
+	secret, hash, err := e.newPassword(*pw, staff.UserName)
+	if err != nil {
+		return err
+	}
+	if err := repositories.NewPasswordRepository(e.db).SetPassword(h.ID, staff.ID, hash, time.Now()); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "reset the password of %s in %s and ended their sessions\n", staff.UserName, h.Name)
+	if *pw == "" {
+		fmt.Fprintf(e.stdout, "password: %s\n", secret)
+	}
+	return nil
+}
+
+// disableStaff disables a staff member with the API's rules, so the
+// hospital's last active admin cannot be disabled.
+func disableStaff(e *env, args []string) error {
+	fs := e.flags("staff disable")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	username := fs.String("username", "", "login name")
+	if err := parse(fs, args, "hospital", "username"); err != nil {
+		return err
+	}
+
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+	staff, err := e.staffMember(h, *username)
+	if err != nil {
+		return err
+	}
+	staffService := services.NewStaffService(
+		repositories.NewStaffRepository(e.db),
+		repositories.NewRoleRepository(e.db),
+		repositories.NewInvitationRepository(e.db),
+		repositories.NewPasswordRepository(e.db),
+	)
+	// No actor: the CLI is not a staff member of the hospital.
+	if _, err := staffService.Disable(h.ID, 0, staff.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "disabled %s in %s and ended their sessions\n", staff.UserName, h.Name)
+	return nil
+}
+
+// newPassword checks pw against the password policy and hashes it, first
+// generating one when pw is empty. It returns the password and its hash.
+func (e *env) newPassword(pw, username string) (string, string, error) {
+	if pw == "" {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return "", "", err
+		}
+		pw = base64.RawURLEncoding.EncodeToString(b)
+	}
+	policy, err := e.passwordPolicy()
+	if err != nil {
+		return "", "", err
+	}
+	if err := policy.Check(pw, username); err != nil {
+		return "", "", err
+	}
+	hash, err := password.Hasher{Cost: e.conf.BcryptCost}.Hash(pw)
+	if err != nil {
+		return "", "", err
+	}
+	return pw, hash, nil
+}
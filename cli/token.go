@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"agnos_candidate_assignment/keyset"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+)
+
+// mintToken prints an access token for a staff member without a login, for
+// calling the API while testing locally. It signs with the active key in
+// JWT_KEYS_DIR, which must be the API's for the token to be accepted, and
+// only reads the directory: generating and retiring keys is left to the API.
+func mintToken(e *env, args []string) error {
+	fs := e.flags("token mint")
+	hospitalRef := fs.String("hospital", "", "hospital name or ID")
+	username := fs.String("username", "", "staff login name")
+	ttl := fs.Duration("ttl", e.conf.AccessTokenTTL, "token lifetime")
+	if err := parse(fs, args, "hospital", "username"); err != nil {
+		return err
+	}
+	if *ttl <= 0 {
+		return usageError("token mint: -ttl must be positive")
+	}
+	if e.conf.JWTKeysDir == "" {
+		return errors.New("JWT_KEYS_DIR is not set; the API could not verify a token signed with a throwaway key")
+	}
+
+	keys, err := keyset.Load(e.conf.JWTKeysDir, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	h, err := e.hospital(*hospitalRef)
+	if err != nil {
+		return err
+	}
+	staff, err := e.staffMember(h, *username)
+	if err != nil {
+		return err
+	}
+	if staff.Status != models.StaffActive {
+		return fmt.Errorf("staff %s is %s", staff.UserName, staff.Status)
+	}
+
+	conf := *e.conf
+	conf.AccessTokenTTL = *ttl
+	policy, err := e.passwordPolicy()
+	if err != nil {
+		return err
+	}
+	authService := services.NewAuthService(
+		repositories.NewStaffRepository(e.db),
+		repositories.NewHospitalRepository(e.db),
+		repositories.NewInvitationRepository(e.db),
+		repositories.NewTokenRepository(e.db),
+		// Minting never reads a TOTP secret, so no field keys are needed.
+		repositories.NewMFARepository(e.db, nil),
+		repositories.NewPasswordRepository(e.db),
+		policy, keys, &conf,
+	)
+	pair, err := authService.IssueAccessToken(staff, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, pair.AccessToken)
+	fmt.Fprintf(e.stderr, "token for %s in %s, expires %s\n", staff.UserName, h.Name, pair.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,20 @@
+// Command agnos administers the API's database: migrations, seeding, and
+// hospitals, staff and patients. It reads the API's configuration from the
+// environment and .env. Run it without arguments for the commands.
+//
+//	go run ./cmd/agnos seed -fixtures fixtures/dev
+package main
+
+import (
+	"os"
+
+	"agnos_candidate_assignment/cli"
+	"agnos_candidate_assignment/config"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+	os.Exit(cli.Run(os.Args[1:], config.Load(), os.Stdin, os.Stdout, os.Stderr))
+}
@@ -3,28 +3,143 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	DatabaseUrl string
-	ServerPort  string
-	GinMode     string
-	JwtSecret   string
+	DatabaseUrl     string
+	ServerPort      string
+	GinMode         string
+	HISTimeout      time.Duration
+	HISSyncInterval time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Tokens are signed with JWTAlgorithm (RS256 or EdDSA) by keys kept as
+	// PEM files in JWTKeysDir; with no directory an in-memory key is used.
+	// A new key takes over every JWTKeyRotation (0 disables rotation).
+	JWTKeysDir     string
+	JWTAlgorithm   string
+	JWTKeyRotation time.Duration
+	JWTIssuer      string
+	JWTAudience    string
+
+	// New staff passwords need PasswordMinLength characters from at least
+	// PasswordMinClasses character classes, and must not appear in the
+	// breached password list at PasswordBreachedFile. Hashes made with a
+	// bcrypt cost other than BcryptCost are redone at the next login.
+	PasswordMinLength    int
+	PasswordMinClasses   int
+	PasswordBreachedFile string
+	BcryptCost           int
+
+	// Staff logins are delayed after repeated wrong passwords and locked for
+	// LoginLockout after LoginMaxFailures in a row, per account; a client IP
+	// is locked after LoginIPMaxFailures.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
+
+	// PublicPatientVerify enables the unauthenticated, masked patient verify
+	// endpoint, which each client IP may call PublicVerifyPerMinute times a
+	// minute.
+	PublicPatientVerify   bool
+	PublicVerifyPerMinute int
+
+	// FieldKeys is the key list that encrypts patient fields, in the format
+	// fieldcrypt.Parse reads; FieldKeysFile, when set, names a file holding
+	// it instead.
+	FieldKeys     string
+	FieldKeysFile string
+
+	// The API keeps at most DBMaxOpenConns connections to Postgres, of which
+	// DBMaxIdleConns stay open between requests. Each request that reads or
+	// writes patients or staff holds one for its transaction.
+	DBMaxOpenConns int
+	DBMaxIdleConns int
+
+	// MigrateOnStart applies pending schema migrations when the API starts;
+	// with it off they are applied with the migrate command.
+	MigrateOnStart bool
+
+	// TrustedProxies are the proxies whose X-Forwarded-For is believed when
+	// working out a client's IP.
+	TrustedProxies []string
+
+	// SystemAdminUsername and SystemAdminPassword create the first system
+	// admin at startup when none exists yet.
+	SystemAdminUsername string
+	SystemAdminPassword string
 }
 
 func Load() *Config {
 	cfg := &Config{
-		DatabaseUrl: getEnv("DATABASE_URL", ""),
-		ServerPort:  getEnv("SERVER_PORT", "8080"),
-		GinMode:     getEnv("GIN_MODE", "release"),
-		JwtSecret:   getEnv("JWT_SECRET", "defaultsecret"),
+		DatabaseUrl:     getEnv("DATABASE_URL", ""),
+		ServerPort:      getEnv("SERVER_PORT", "8080"),
+		GinMode:         getEnv("GIN_MODE", "release"),
+		HISTimeout:      getDuration("HIS_TIMEOUT", 10*time.Second),
+		HISSyncInterval: getDuration("HIS_SYNC_INTERVAL", 15*time.Minute),
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+
+		JWTKeysDir:     getEnv("JWT_KEYS_DIR", ""),
+		JWTAlgorithm:   getEnv("JWT_ALG", "EdDSA"),
+		JWTKeyRotation: getDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTIssuer:      getEnv("JWT_ISSUER", "agnos"),
+		JWTAudience:    getEnv("JWT_AUDIENCE", "agnos-api"),
+
+		PasswordMinLength:    getInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMinClasses:   getInt("PASSWORD_MIN_CLASSES", 3),
+		PasswordBreachedFile: getEnv("PASSWORD_BREACHED_FILE", ""),
+		BcryptCost:           getInt("BCRYPT_COST", 10),
+
+		LoginMaxFailures:   getInt("LOGIN_MAX_FAILURES", 10),
+		LoginIPMaxFailures: getInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockout:       getDuration("LOGIN_LOCKOUT", 15*time.Minute),
+
+		PublicPatientVerify:   getBool("PUBLIC_PATIENT_VERIFY", false),
+		PublicVerifyPerMinute: getInt("PUBLIC_VERIFY_PER_MINUTE", 10),
+
+		FieldKeys:     getEnv("FIELD_KEYS", ""),
+		FieldKeysFile: getEnv("FIELD_KEYS_FILE", ""),
+
+		DBMaxOpenConns: getInt("DB_MAX_OPEN_CONNS", 20),
+		DBMaxIdleConns: getInt("DB_MAX_IDLE_CONNS", 5),
+
+		MigrateOnStart: getBool("MIGRATE_ON_START", true),
+
+		TrustedProxies: getList("TRUSTED_PROXIES", "127.0.0.1,::1,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16"),
+
+		SystemAdminUsername: getEnv("SYSTEM_ADMIN_USERNAME", ""),
+		SystemAdminPassword: getEnv("SYSTEM_ADMIN_PASSWORD", ""),
 	}
 	if v, _ := os.LookupEnv("SILENCE_LOGS"); v != "true" {
-		log.Printf("Configuration loaded: %+v\n", cfg)
+		logged := *cfg
+		if logged.SystemAdminPassword != "" {
+			logged.SystemAdminPassword = "***"
+		}
+		if logged.FieldKeys != "" {
+			logged.FieldKeys = "***"
+		}
+		log.Printf("Configuration loaded: %+v\n", logged)
 	}
 	return cfg
 }
 
+// SystemAdminAudience is the audience of system admin tokens, distinct from
+// JWTAudience so neither kind of token is accepted in place of the other.
+func (c *Config) SystemAdminAudience() string {
+	return c.JWTAudience + "/admin"
+}
+
+// MFAAudience is the audience of MFA challenge tokens, which only the second
+// login step accepts.
+func (c *Config) MFAAudience() string {
+	return c.JWTAudience + "/mfa"
+}
+
 func getEnv(key, defaultValue string) string {
 	if v, ok := os.LookupEnv(key); ok {
 		return v
@@ -32,3 +147,56 @@ func getEnv(key, defaultValue string) string {
 
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid duration for %s: %q, using %s", key, v, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getInt(key string, defaultValue int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid integer for %s: %q, using %d", key, v, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getBool(key string, defaultValue bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid boolean for %s: %q, using %t", key, v, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getList reads a comma-separated list; an empty value gives an empty list.
+func getList(key, defaultValue string) []string {
+	list := []string{}
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
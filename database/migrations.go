@@ -0,0 +1,327 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the Postgres advisory lock held while migrating, so
+// API instances starting together apply each migration once.
+const migrationLockKey int64 = 0x61676e6f73 // "agnos"
+
+// noTransaction, as the first line of an up or down file, runs it outside a
+// transaction, for statements such as CREATE INDEX CONCURRENTLY. Such a file
+// must hold a single statement, and a failure part way leaves the migration
+// unrecorded, to be repaired by hand.
+const noTransaction = "-- migrate:no-transaction"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	ErrUnknownMigration = errors.New("database has a migration this build does not know")
+	ErrOutOfOrder       = errors.New("pending migration is older than the latest applied one")
+)
+
+// Migration is one schema change, read from NNNN_name.up.sql and
+// NNNN_name.down.sql. Checksum is the hex SHA-256 of the up file, recorded
+// when it is applied so later edits to it are caught.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationState is a migration as the database sees it. Modified is set
+// when the recorded checksum differs from the file's, and Missing when the
+// migration is recorded but has no file.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool
+	Missing   bool
+}
+
+// schemaMigration is a row of schema_migrations.
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	checksum varchar(64) NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// Migrations returns the migrations built into the binary.
+func Migrations() ([]Migration, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(dir)
+}
+
+// LoadMigrations reads the migrations in the root of fsys, ordered by
+// version. Every version needs both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		parts := migrationFileName.FindStringSubmatch(e.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: bad version", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		} else if m.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d: named both %s and %s", version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs non-empty up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations, recording them in
+// schema_migrations. Each migration runs in its own transaction together
+// with its record, so a failed one leaves nothing behind; all of them run
+// under an advisory lock.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Migrate applies the built-in migrations that are pending.
+func Migrate(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(db, migrations).Up()
+}
+
+// Up applies every pending migration in order and returns them. It refuses
+// to run when an applied migration was modified or is unknown, or when a
+// pending one is older than the latest applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(func(conn *gorm.DB) error {
+		states, err := m.status(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkApplied(states); err != nil {
+			return err
+		}
+
+		var latest int64
+		for _, s := range states {
+			if s.AppliedAt == nil {
+				continue
+			}
+			latest = s.Version
+		}
+		for _, s := range states {
+			if s.AppliedAt != nil {
+				continue
+			}
+			if s.Version < latest {
+				return fmt.Errorf("migration %d_%s: %w", s.Version, s.Name, ErrOutOfOrder)
+			}
+			if err := m.apply(conn, s.Migration); err != nil {
+				return err
+			}
+			applied = append(applied, s.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(func(conn *gorm.DB) error {
+		states, err := m.status(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkApplied(states); err != nil {
+			return err
+		}
+		for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+			if states[i].AppliedAt == nil {
+				continue
+			}
+			if err := m.revert(conn, states[i].Migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, states[i].Migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known and every applied migration by version.
+func (m *Migrator) Status() ([]MigrationState, error) {
+	var states []MigrationState
+	err := m.locked(func(conn *gorm.DB) error {
+		var err error
+		states, err = m.status(conn)
+		return err
+	})
+	return states, err
+}
+
+// locked runs fn on a single connection holding the migration lock, waiting
+// for any other migrator to finish first.
+func (m *Migrator) locked(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+
+		if err := conn.Exec(createSchemaMigrations).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) status(conn *gorm.DB) ([]MigrationState, error) {
+	var rows []schemaMigration
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	recorded := map[int64]schemaMigration{}
+	for _, r := range rows {
+		recorded[r.Version] = r
+	}
+
+	var states []MigrationState
+	for _, mig := range m.migrations {
+		s := MigrationState{Migration: mig}
+		if r, ok := recorded[mig.Version]; ok {
+			appliedAt := r.AppliedAt
+			s.AppliedAt = &appliedAt
+			s.Modified = r.Checksum != mig.Checksum
+			delete(recorded, mig.Version)
+		}
+		states = append(states, s)
+	}
+	for _, r := range recorded {
+		appliedAt := r.AppliedAt
+		states = append(states, MigrationState{
+			Migration: Migration{Version: r.Version, Name: r.Name, Checksum: r.Checksum},
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+func checkApplied(states []MigrationState) error {
+	for _, s := range states {
+		if s.Missing {
+			return fmt.Errorf("migration %d_%s: %w", s.Version, s.Name, ErrUnknownMigration)
+		}
+		if s.Modified {
+			return fmt.Errorf("migration %d_%s: %w", s.Version, s.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(conn *gorm.DB, mig Migration) error {
+	return m.run(conn, mig, mig.Up, func(tx *gorm.DB) error {
+		return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now()}).Error
+	})
+}
+
+func (m *Migrator) revert(conn *gorm.DB, mig Migration) error {
+	return m.run(conn, mig, mig.Down, func(tx *gorm.DB) error {
+		return tx.Delete(&schemaMigration{}, mig.Version).Error
+	})
+}
+
+// run executes script and then record, in one transaction unless the
+// script opts out.
+func (m *Migrator) run(conn *gorm.DB, mig Migration, script string, record func(tx *gorm.DB) error) error {
+	exec := func(tx *gorm.DB) error {
+		// Straight to the driver: gorm would read ? in the script as a
+		// placeholder.
+		if _, err := tx.Statement.ConnPool.ExecContext(context.Background(), script); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return record(tx)
+	}
+	if strings.HasPrefix(script, noTransaction) {
+		return exec(conn)
+	}
+	return conn.Transaction(exec)
+}
@@ -0,0 +1,50 @@
+-- The schema the service started with, as AutoMigrate created it before
+-- any of the later migrations existed. Every statement is guarded, so a
+-- database created by AutoMigrate is adopted here and brought up to date by
+-- the migrations that follow, each of which tolerates the columns and
+-- tables a later AutoMigrate may already have added.
+
+CREATE TABLE IF NOT EXISTS hospitals (
+	id bigserial PRIMARY KEY,
+	name varchar(255) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hospitals_name ON hospitals (name);
+
+CREATE TABLE IF NOT EXISTS staffs (
+	id bigserial PRIMARY KEY,
+	user_name varchar(255) NOT NULL,
+	password_hash varchar(255) NOT NULL,
+	hospital_id bigint NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz,
+	CONSTRAINT fk_staffs_hospital FOREIGN KEY (hospital_id) REFERENCES hospitals (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_staffs_user_name ON staffs (user_name);
+CREATE INDEX IF NOT EXISTS idx_staffs_hospital_id ON staffs (hospital_id);
+
+CREATE TABLE IF NOT EXISTS patients (
+	id bigserial PRIMARY KEY,
+	hospital_id bigint NOT NULL,
+	first_name_th varchar(255),
+	middle_name_th varchar(255),
+	first_name_en varchar(255),
+	middle_name_en varchar(255),
+	last_name_th varchar(255),
+	last_name_en varchar(255),
+	date_of_birth date NOT NULL,
+	patient_hn varchar(50),
+	national_id varchar(255),
+	passport_id varchar(255),
+	phone_number varchar(50),
+	email varchar(255),
+	gender varchar(1) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz,
+	CONSTRAINT fk_patients_hospital FOREIGN KEY (hospital_id) REFERENCES hospitals (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_id ON patients (hospital_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_patient_hn ON patients (patient_hn);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_national_id ON patients (national_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_passport_id ON patients (passport_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS his_sync_runs;
+ALTER TABLE hospitals DROP COLUMN IF EXISTS his_synced_until;
+ALTER TABLE hospitals DROP COLUMN IF EXISTS his_adapter;
+ALTER TABLE hospitals DROP COLUMN IF EXISTS api_url;
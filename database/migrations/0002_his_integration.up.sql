@@ -0,0 +1,22 @@
+-- Per-hospital HIS adapters and the scheduled sync's cursor and run history.
+
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS api_url varchar(512);
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS his_adapter varchar(50);
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS his_synced_until timestamptz;
+
+CREATE TABLE IF NOT EXISTS his_sync_runs (
+	id bigserial PRIMARY KEY,
+	hospital_id bigint NOT NULL,
+	"trigger" varchar(20) NOT NULL,
+	status varchar(20) NOT NULL,
+	cursor_from timestamptz,
+	cursor_to timestamptz,
+	fetched bigint NOT NULL DEFAULT 0,
+	upserted bigint NOT NULL DEFAULT 0,
+	failed bigint NOT NULL DEFAULT 0,
+	error text,
+	started_at timestamptz NOT NULL,
+	finished_at timestamptz,
+	CONSTRAINT fk_his_sync_runs_hospital FOREIGN KEY (hospital_id) REFERENCES hospitals (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_his_sync_runs_hospital_id ON his_sync_runs (hospital_id);
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS system_admins;
+DROP TABLE IF EXISTS staff_invitations;
+
+DROP INDEX IF EXISTS idx_staffs_deleted_at;
+DROP INDEX IF EXISTS idx_staffs_role_id;
+ALTER TABLE staffs DROP CONSTRAINT IF EXISTS fk_staffs_role;
+ALTER TABLE staffs DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE staffs DROP COLUMN IF EXISTS department;
+ALTER TABLE staffs DROP COLUMN IF EXISTS license_number;
+ALTER TABLE staffs DROP COLUMN IF EXISTS display_name;
+ALTER TABLE staffs DROP COLUMN IF EXISTS status;
+ALTER TABLE staffs DROP COLUMN IF EXISTS role_id;
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
+
+ALTER TABLE hospitals DROP COLUMN IF EXISTS deactivated_at;
+ALTER TABLE hospitals DROP COLUMN IF EXISTS require_staff_approval;
@@ -0,0 +1,63 @@
+-- Roles and permissions, invitations, approval and deactivation, staff
+-- profiles with soft deletion, and the system admins who manage hospitals.
+
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS require_staff_approval boolean NOT NULL DEFAULT false;
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS deactivated_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS permissions (
+	id bigserial PRIMARY KEY,
+	name varchar(100) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_name ON permissions (name);
+
+CREATE TABLE IF NOT EXISTS roles (
+	id bigserial PRIMARY KEY,
+	name varchar(50) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_name ON roles (name);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_id bigint,
+	permission_id bigint,
+	PRIMARY KEY (role_id, permission_id),
+	CONSTRAINT fk_role_permissions_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+	CONSTRAINT fk_role_permissions_permission FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS role_id bigint;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'active';
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS display_name varchar(255);
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS license_number varchar(64);
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS department varchar(128);
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE staffs DROP CONSTRAINT IF EXISTS fk_staffs_role;
+ALTER TABLE staffs ADD CONSTRAINT fk_staffs_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE SET NULL ON UPDATE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_staffs_role_id ON staffs (role_id);
+CREATE INDEX IF NOT EXISTS idx_staffs_deleted_at ON staffs (deleted_at);
+
+CREATE TABLE IF NOT EXISTS staff_invitations (
+	id bigserial PRIMARY KEY,
+	hospital_id bigint NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	role_id bigint,
+	created_by_id bigint NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz,
+	used_by_id bigint,
+	created_at timestamptz,
+	CONSTRAINT fk_staff_invitations_hospital FOREIGN KEY (hospital_id) REFERENCES hospitals (id) ON DELETE CASCADE ON UPDATE CASCADE,
+	CONSTRAINT fk_staff_invitations_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_staff_invitations_token_hash ON staff_invitations (token_hash);
+CREATE INDEX IF NOT EXISTS idx_staff_invitations_hospital_id ON staff_invitations (hospital_id);
+
+CREATE TABLE IF NOT EXISTS system_admins (
+	id bigserial PRIMARY KEY,
+	user_name varchar(255) NOT NULL,
+	password_hash varchar(255) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_system_admins_user_name ON system_admins (user_name);
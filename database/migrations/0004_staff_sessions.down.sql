@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+
+ALTER TABLE staffs DROP COLUMN IF EXISTS password_changed_at;
+ALTER TABLE staffs DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE staffs DROP COLUMN IF EXISTS last_failed_login_at;
+ALTER TABLE staffs DROP COLUMN IF EXISTS failed_logins;
+ALTER TABLE staffs DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE staffs DROP COLUMN IF EXISTS mfa_enabled;
+ALTER TABLE staffs DROP COLUMN IF EXISTS totp_secret;
+
+ALTER TABLE hospitals DROP COLUMN IF EXISTS require_mfa;
@@ -0,0 +1,59 @@
+-- Refresh tokens and the access token denylist, MFA with recovery codes,
+-- login lockout and admin-issued password resets.
+
+ALTER TABLE hospitals ADD COLUMN IF NOT EXISTS require_mfa boolean NOT NULL DEFAULT false;
+
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS totp_secret varchar(64);
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS mfa_enabled boolean NOT NULL DEFAULT false;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS failed_logins bigint NOT NULL DEFAULT 0;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS last_failed_login_at timestamptz;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS locked_until timestamptz;
+ALTER TABLE staffs ADD COLUMN IF NOT EXISTS password_changed_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id bigserial PRIMARY KEY,
+	staff_id bigint NOT NULL,
+	family_id varchar(32) NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz,
+	revoked_at timestamptz,
+	created_at timestamptz,
+	CONSTRAINT fk_refresh_tokens_staff FOREIGN KEY (staff_id) REFERENCES staffs (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_staff_id ON refresh_tokens (staff_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+	jti varchar(32) PRIMARY KEY,
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id bigserial PRIMARY KEY,
+	staff_id bigint NOT NULL,
+	code_hash varchar(64) NOT NULL,
+	used_at timestamptz,
+	created_at timestamptz,
+	CONSTRAINT fk_recovery_codes_staff FOREIGN KEY (staff_id) REFERENCES staffs (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_staff_id ON recovery_codes (staff_id);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+	id bigserial PRIMARY KEY,
+	staff_id bigint NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	created_by_id bigint NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz,
+	created_at timestamptz,
+	CONSTRAINT fk_password_reset_tokens_staff FOREIGN KEY (staff_id) REFERENCES staffs (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_staff_id ON password_reset_tokens (staff_id);
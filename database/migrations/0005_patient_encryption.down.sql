@@ -0,0 +1,35 @@
+-- The PII columns stay text: the values in them may be ciphertext, which
+-- does not fit the original widths and cannot be decrypted here.
+
+DROP INDEX IF EXISTS idx_patients_last_name_en_bidx;
+DROP INDEX IF EXISTS idx_patients_middle_name_en_bidx;
+DROP INDEX IF EXISTS idx_patients_first_name_en_bidx;
+DROP INDEX IF EXISTS idx_patients_last_name_th_bidx;
+DROP INDEX IF EXISTS idx_patients_middle_name_th_bidx;
+DROP INDEX IF EXISTS idx_patients_first_name_th_bidx;
+DROP INDEX IF EXISTS idx_patients_email_bidx;
+DROP INDEX IF EXISTS idx_patients_phone_number_bidx;
+DROP INDEX IF EXISTS idx_patients_passport_id_bidx;
+DROP INDEX IF EXISTS idx_patients_national_id_bidx;
+DROP INDEX IF EXISTS idx_patients_hospital_updated;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS email_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS phone_number_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS passport_id_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS national_id_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_en_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_th_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_en_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_en_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_th_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_th_bidx;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_en_key;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_th_key;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_en_key;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_th_key;
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_en_key;
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_th_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_national_id ON patients (national_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_passport_id ON patients (passport_id);
@@ -0,0 +1,50 @@
+-- Patient PII is stored encrypted, so the columns holding it widen to text
+-- for the ciphertext, and each gains a blind index for exact lookups. Names
+-- also gain the phonetic keys name search matches on. Uniqueness of the
+-- national and passport IDs moves from the ciphertext, which differs on
+-- every write, to their blind indexes. Rows written before encryption are
+-- encrypted and indexed by the API at startup.
+
+ALTER TABLE patients ALTER COLUMN first_name_th TYPE text;
+ALTER TABLE patients ALTER COLUMN middle_name_th TYPE text;
+ALTER TABLE patients ALTER COLUMN first_name_en TYPE text;
+ALTER TABLE patients ALTER COLUMN middle_name_en TYPE text;
+ALTER TABLE patients ALTER COLUMN last_name_th TYPE text;
+ALTER TABLE patients ALTER COLUMN last_name_en TYPE text;
+ALTER TABLE patients ALTER COLUMN national_id TYPE text;
+ALTER TABLE patients ALTER COLUMN passport_id TYPE text;
+ALTER TABLE patients ALTER COLUMN phone_number TYPE text;
+ALTER TABLE patients ALTER COLUMN email TYPE text;
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_th_key varchar(255);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_en_key varchar(255);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_th_key varchar(255);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_en_key varchar(255);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_th_key varchar(255);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_en_key varchar(255);
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_th_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_th_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_en_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_en_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_th_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_en_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS national_id_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS passport_id_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS phone_number_bidx varchar(32);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS email_bidx varchar(32);
+
+DROP INDEX IF EXISTS idx_patients_national_id;
+DROP INDEX IF EXISTS idx_patients_passport_id;
+
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_updated ON patients (hospital_id, updated_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_national_id_bidx ON patients (national_id_bidx);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_passport_id_bidx ON patients (passport_id_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_phone_number_bidx ON patients (phone_number_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_email_bidx ON patients (email_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_bidx ON patients (first_name_th_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_th_bidx ON patients (middle_name_th_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_bidx ON patients (last_name_th_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_bidx ON patients (first_name_en_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_en_bidx ON patients (middle_name_en_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_bidx ON patients (last_name_en_bidx);
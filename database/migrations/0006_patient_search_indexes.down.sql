@@ -0,0 +1,8 @@
+-- pg_trgm is left installed; other database objects may use it.
+
+DROP INDEX IF EXISTS idx_patients_first_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_first_name_en_key_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_en_key_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_en_key_trgm;
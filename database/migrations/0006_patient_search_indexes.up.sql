@@ -0,0 +1,19 @@
+-- Trigram indexes over the phonetic name keys used by patient name search.
+-- Names are encrypted, so the trigram indexes over them are dropped where
+-- an older schema still has them.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DROP INDEX IF EXISTS idx_patients_first_name_th_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_th_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_th_trgm;
+DROP INDEX IF EXISTS idx_patients_first_name_en_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_en_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_en_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_key_trgm ON patients USING gin (first_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_key_trgm ON patients USING gin (first_name_en_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_th_key_trgm ON patients USING gin (middle_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_en_key_trgm ON patients USING gin (middle_name_en_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_key_trgm ON patients USING gin (last_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_key_trgm ON patients USING gin (last_name_en_key gin_trgm_ops);
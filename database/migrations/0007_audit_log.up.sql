@@ -0,0 +1,38 @@
+-- The hash-chained patient access log. It is append-only and its patient
+-- IDs are indexed for auditors to filter by. The triggers refuse updates,
+-- deletes and truncation from any client, including the API's own database
+-- user.
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id bigserial PRIMARY KEY,
+	hospital_id bigint NOT NULL,
+	seq bigint NOT NULL,
+	staff_id bigint,
+	action varchar(32) NOT NULL,
+	patient_ids jsonb NOT NULL,
+	filters text,
+	status bigint NOT NULL,
+	ip varchar(64),
+	user_agent varchar(512),
+	request_id varchar(64),
+	created_at timestamptz NOT NULL,
+	prev_hash varchar(64) NOT NULL,
+	hash varchar(64) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_chain ON audit_logs (hospital_id, seq);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_staff_id ON audit_logs (staff_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request_id ON audit_logs (request_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_patient_ids ON audit_logs USING gin (patient_ids);
+
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_no_change ON audit_logs;
+CREATE TRIGGER audit_logs_no_change BEFORE UPDATE OR DELETE ON audit_logs FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
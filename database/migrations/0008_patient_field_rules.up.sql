@@ -0,0 +1,10 @@
+-- Per-hospital overrides of the fields each role sees on a patient.
+
+CREATE TABLE IF NOT EXISTS patient_field_rules (
+	id bigserial PRIMARY KEY,
+	hospital_id bigint NOT NULL,
+	role varchar(50) NOT NULL,
+	field varchar(50) NOT NULL,
+	action varchar(10) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patient_field_rules_rule ON patient_field_rules (hospital_id, role, field);
@@ -0,0 +1,7 @@
+DROP POLICY IF EXISTS staffs_hospital_scope ON staffs;
+ALTER TABLE staffs NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE staffs DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS patients_hospital_scope ON patients;
+ALTER TABLE patients NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE patients DISABLE ROW LEVEL SECURITY;
@@ -0,0 +1,25 @@
+-- Confine patients and staffs to the hospital a transaction is scoped to,
+-- for reads and writes alike (see repositories.InHospital). A row is
+-- admitted when app.hospital_id is unset or names its hospital; NULLIF keeps
+-- the cast from failing on the empty string the setting reads as once a
+-- transaction that set it has ended. FORCE applies the policies to the
+-- tables' owner, which is the API's own database user; superusers and
+-- BYPASSRLS roles still skip them.
+
+ALTER TABLE patients ENABLE ROW LEVEL SECURITY;
+ALTER TABLE patients FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS patients_hospital_scope ON patients;
+CREATE POLICY patients_hospital_scope ON patients
+	USING (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
+
+ALTER TABLE staffs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE staffs FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS staffs_hospital_scope ON staffs;
+CREATE POLICY staffs_hospital_scope ON staffs
+	USING (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
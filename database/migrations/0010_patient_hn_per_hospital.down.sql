@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_patients_patient_hn;
+CREATE UNIQUE INDEX idx_patients_patient_hn ON patients (patient_hn);
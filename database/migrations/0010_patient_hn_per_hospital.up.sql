@@ -0,0 +1,6 @@
+-- HNs are assigned by each hospital, so they are unique per hospital rather
+-- than across all of them. Patient upserts use this index as their conflict
+-- target.
+
+DROP INDEX IF EXISTS idx_patients_patient_hn;
+CREATE UNIQUE INDEX idx_patients_patient_hn ON patients (hospital_id, patient_hn);
@@ -0,0 +1,16 @@
+-- Back to the policies of 0009, which admit every row when app.hospital_id
+-- is unset.
+
+DROP POLICY IF EXISTS patients_hospital_scope ON patients;
+CREATE POLICY patients_hospital_scope ON patients
+	USING (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
+
+DROP POLICY IF EXISTS staffs_hospital_scope ON staffs;
+CREATE POLICY staffs_hospital_scope ON staffs
+	USING (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (NULLIF(current_setting('app.hospital_id', true), '') IS NULL
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
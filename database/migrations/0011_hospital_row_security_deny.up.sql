@@ -0,0 +1,20 @@
+-- Row-level security on patients and staffs denies by default: a
+-- transaction that has not set app.hospital_id sees no rows and cannot
+-- write any. Work spanning hospitals sets app.all_hospitals to 'on' instead
+-- (see repositories.AllHospitals). NULLIF keeps the cast from failing on the
+-- empty string a setting reads as once a transaction that set it has ended;
+-- comparing with the resulting NULL admits nothing.
+
+DROP POLICY IF EXISTS patients_hospital_scope ON patients;
+CREATE POLICY patients_hospital_scope ON patients
+	USING (current_setting('app.all_hospitals', true) = 'on'
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (current_setting('app.all_hospitals', true) = 'on'
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
+
+DROP POLICY IF EXISTS staffs_hospital_scope ON staffs;
+CREATE POLICY staffs_hospital_scope ON staffs
+	USING (current_setting('app.all_hospitals', true) = 'on'
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint)
+	WITH CHECK (current_setting('app.all_hospitals', true) = 'on'
+		OR hospital_id = NULLIF(current_setting('app.hospital_id', true), '')::bigint);
@@ -0,0 +1,30 @@
+-- The keyed phonetic keys mean nothing to the previous release, so they are
+-- cleared for it to re-derive in plaintext at startup.
+
+DROP INDEX IF EXISTS idx_patients_first_name_th_key;
+DROP INDEX IF EXISTS idx_patients_first_name_en_key;
+DROP INDEX IF EXISTS idx_patients_middle_name_th_key;
+DROP INDEX IF EXISTS idx_patients_middle_name_en_key;
+DROP INDEX IF EXISTS idx_patients_last_name_th_key;
+DROP INDEX IF EXISTS idx_patients_last_name_en_key;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_th_grams;
+ALTER TABLE patients DROP COLUMN IF EXISTS first_name_en_grams;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_th_grams;
+ALTER TABLE patients DROP COLUMN IF EXISTS middle_name_en_grams;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_th_grams;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_name_en_grams;
+
+UPDATE patients SET
+    first_name_th_key = NULL, first_name_en_key = NULL,
+    middle_name_th_key = NULL, middle_name_en_key = NULL,
+    last_name_th_key = NULL, last_name_en_key = NULL;
+
+DROP FUNCTION IF EXISTS token_similarity(text[], text[]);
+
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_key_trgm ON patients USING gin (first_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_key_trgm ON patients USING gin (first_name_en_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_th_key_trgm ON patients USING gin (middle_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_en_key_trgm ON patients USING gin (middle_name_en_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_key_trgm ON patients USING gin (last_name_th_key gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_key_trgm ON patients USING gin (last_name_en_key gin_trgm_ops);
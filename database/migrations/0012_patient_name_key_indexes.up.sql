@@ -0,0 +1,47 @@
+-- Phonetic name keys were stored in plaintext, which gives away roughly how
+-- every patient's name sounds. They are now stored as blind indexes, with
+-- the blind indexes of their trigrams in <column>_grams serving prefix,
+-- contains and fuzzy name search. The plaintext keys are cleared here and
+-- re-derived by the API at startup.
+
+CREATE OR REPLACE FUNCTION token_similarity(a text[], b text[]) RETURNS float8
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE
+        WHEN coalesce(cardinality(a), 0) = 0 OR coalesce(cardinality(b), 0) = 0 THEN 0
+        ELSE (SELECT count(*) FROM (SELECT unnest(a) INTERSECT SELECT unnest(b)) s)::float8
+            / (SELECT count(*) FROM (SELECT unnest(a) UNION SELECT unnest(b)) s)
+    END
+$$;
+
+DROP INDEX IF EXISTS idx_patients_first_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_first_name_en_key_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_middle_name_en_key_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_th_key_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_en_key_trgm;
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_th_grams text[];
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS first_name_en_grams text[];
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_th_grams text[];
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS middle_name_en_grams text[];
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_th_grams text[];
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_name_en_grams text[];
+
+UPDATE patients SET
+    first_name_th_key = NULL, first_name_en_key = NULL,
+    middle_name_th_key = NULL, middle_name_en_key = NULL,
+    last_name_th_key = NULL, last_name_en_key = NULL;
+
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_key ON patients (first_name_th_key);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_key ON patients (first_name_en_key);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_th_key ON patients (middle_name_th_key);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_en_key ON patients (middle_name_en_key);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_key ON patients (last_name_th_key);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_key ON patients (last_name_en_key);
+
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_grams ON patients USING gin (first_name_th_grams);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_grams ON patients USING gin (first_name_en_grams);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_th_grams ON patients USING gin (middle_name_th_grams);
+CREATE INDEX IF NOT EXISTS idx_patients_middle_name_en_grams ON patients USING gin (middle_name_en_grams);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_grams ON patients USING gin (last_name_th_grams);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_grams ON patients USING gin (last_name_en_grams);
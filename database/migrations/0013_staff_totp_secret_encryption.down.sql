@@ -0,0 +1,4 @@
+-- Encrypted secrets do not fit the old varchar(64), so the column stays
+-- text; staff enrolled since must enroll again on the previous release.
+
+ALTER TABLE staffs ALTER COLUMN totp_secret TYPE text;
@@ -0,0 +1,5 @@
+-- TOTP secrets are stored encrypted with the field keys, so the column
+-- widens to text for the ciphertext. Secrets written before encryption are
+-- encrypted by the API at startup.
+
+ALTER TABLE staffs ALTER COLUMN totp_secret TYPE text;
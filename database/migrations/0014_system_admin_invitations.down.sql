@@ -0,0 +1,4 @@
+DELETE FROM staff_invitations WHERE created_by_id IS NULL;
+ALTER TABLE staff_invitations DROP CONSTRAINT IF EXISTS fk_staff_invitations_created_by_admin;
+ALTER TABLE staff_invitations DROP COLUMN IF EXISTS created_by_admin_id;
+ALTER TABLE staff_invitations ALTER COLUMN created_by_id SET NOT NULL;
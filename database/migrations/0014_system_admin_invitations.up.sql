@@ -0,0 +1,13 @@
+-- System admins issue invitations too, such as for a hospital's first
+-- admin, so an invitation records either the staff member or the system
+-- admin who issued it.
+
+ALTER TABLE staff_invitations ALTER COLUMN created_by_id DROP NOT NULL;
+ALTER TABLE staff_invitations ADD COLUMN IF NOT EXISTS created_by_admin_id bigint;
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_staff_invitations_created_by_admin') THEN
+		ALTER TABLE staff_invitations ADD CONSTRAINT fk_staff_invitations_created_by_admin
+			FOREIGN KEY (created_by_admin_id) REFERENCES system_admins (id) ON DELETE SET NULL ON UPDATE CASCADE;
+	END IF;
+END $$;
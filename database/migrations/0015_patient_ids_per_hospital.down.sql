@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_patients_national_id_bidx;
+DROP INDEX IF EXISTS idx_patients_passport_id_bidx;
+CREATE UNIQUE INDEX idx_patients_national_id_bidx ON patients (national_id_bidx);
+CREATE UNIQUE INDEX idx_patients_passport_id_bidx ON patients (passport_id_bidx);
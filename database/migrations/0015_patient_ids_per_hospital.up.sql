@@ -0,0 +1,11 @@
+-- National and passport IDs are unique per hospital, like HNs: a patient
+-- registered at several hospitals has a row in each, and a duplicate in
+-- another hospital must not surface as a conflict that reveals it.
+
+DROP INDEX IF EXISTS idx_patients_national_id;
+DROP INDEX IF EXISTS idx_patients_passport_id;
+
+DROP INDEX IF EXISTS idx_patients_national_id_bidx;
+DROP INDEX IF EXISTS idx_patients_passport_id_bidx;
+CREATE UNIQUE INDEX idx_patients_national_id_bidx ON patients (hospital_id, national_id_bidx);
+CREATE UNIQUE INDEX idx_patients_passport_id_bidx ON patients (hospital_id, passport_id_bidx);
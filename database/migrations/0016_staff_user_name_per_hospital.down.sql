@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_staffs_user_name;
+CREATE UNIQUE INDEX idx_staffs_user_name ON staffs (user_name);
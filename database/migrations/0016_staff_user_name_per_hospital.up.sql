@@ -0,0 +1,6 @@
+-- Usernames are unique per hospital: logins resolve the hospital first, and
+-- a name taken in another hospital must not surface as a conflict that
+-- reveals it.
+
+DROP INDEX IF EXISTS idx_staffs_user_name;
+CREATE UNIQUE INDEX idx_staffs_user_name ON staffs (hospital_id, user_name);
@@ -2,7 +2,6 @@ package database
 
 import (
 	"agnos_candidate_assignment/config"
-	"agnos_candidate_assignment/models"
 	"log"
 
 	"gorm.io/driver/postgres"
@@ -23,22 +22,29 @@ func NewPostgresConnection(configuration *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	sqlDB.SetMaxOpenConns(1)
-	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxOpenConns(configuration.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(configuration.DBMaxIdleConns)
 
-	if err := db.AutoMigrate(
-		&models.Hospital{},
-		&models.Staff{},
-		&models.Patient{},
-	); err != nil {
-		log.Printf("auto migrate error: %v", err)
+	if configuration.MigrateOnStart {
+		applied, err := Migrate(db)
+		if err != nil {
+			log.Printf("migration error: %v", err)
+			return nil, err
+		}
+		for _, m := range applied {
+			log.Printf("applied migration %d_%s", m.Version, m.Name)
+		}
+	}
+
+	if err := warnIfBypassingRowSecurity(db); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
 
-// for seeding
+// NewPostgresConnectionNoMigrate opens the database without migrating it,
+// for the admin CLI, which migrates only when asked.
 func NewPostgresConnectionNoMigrate(configuration *config.Config) (*gorm.DB, error) {
 	dsn2 := configuration.DatabaseUrl
 
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// warnIfBypassingRowSecurity logs when the database user skips the
+// hospital row-level security policies (migration 0011), as superusers and
+// BYPASSRLS roles do.
+func warnIfBypassingRowSecurity(db *gorm.DB) error {
+	var bypass bool
+	err := db.Raw("SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&bypass).Error
+	if err != nil {
+		return err
+	}
+	if bypass {
+		log.Printf("warning: database user bypasses row-level security; connect as a role without SUPERUSER or BYPASSRLS to enforce hospital isolation")
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+// Package fieldcrypt encrypts individual database fields with envelope
+// encryption and computes blind indexes for looking them up.
+//
+// Every value is encrypted with AES-256-GCM under its own random data key,
+// and the data key is wrapped with AES-256-GCM under a master key. The
+// sealed form names the master key, so master keys can be rotated: new
+// values use the active key while values under older keys stay readable
+// until they are re-encrypted. The field name is authenticated with the
+// value, so a sealed value cannot be moved to another column.
+//
+// A blind index is an HMAC-SHA256 of the field name and value under a
+// separate index key. Equal values give equal indexes, which makes equality
+// lookups possible without decrypting, but reveals nothing else. The index
+// key never rotates; changing it means recomputing every index.
+package fieldcrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeySize is the length of master, data and index keys.
+const KeySize = 32
+
+// IndexKeyID names the blind index key in a key list.
+const IndexKeyID = "index"
+
+// Prefix starts every sealed value; anything without it is legacy
+// plaintext.
+const Prefix = "enc:v1:"
+
+// indexBytes is how much of the HMAC a blind index keeps: 128 bits, ample
+// to keep collisions out of equality lookups.
+const indexBytes = 16
+
+var (
+	ErrNoKeys      = errors.New("fieldcrypt: no keys configured")
+	ErrNoMasterKey = errors.New("fieldcrypt: no master key")
+	ErrNoIndexKey  = errors.New("fieldcrypt: no index key")
+	ErrUnknownKey  = errors.New("fieldcrypt: value sealed under an unknown master key")
+	ErrMalformed   = errors.New("fieldcrypt: malformed sealed value")
+)
+
+// Keyring holds the master keys and the blind index key.
+type Keyring struct {
+	activeID string
+	masters  map[string]cipher.AEAD
+	index    []byte
+}
+
+// Parse reads a key list: entries of the form id=base64key, separated by
+// commas or newlines, with # starting a comment. The entry with id "index"
+// is the blind index key; of the others, the first is the active master
+// key. Keys are 32 bytes in standard or URL-safe base64.
+func Parse(spec string) (*Keyring, error) {
+	k := &Keyring{masters: map[string]cipher.AEAD{}}
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(spec, ",", "\n")))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, "=")
+		id = strings.TrimSpace(id)
+		if !ok || !validKeyID(id) {
+			return nil, fmt.Errorf("fieldcrypt: key entries must be id=base64key with an id of letters, digits, - or _")
+		}
+		key, err := decodeKey(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+
+		if id == IndexKeyID {
+			if k.index != nil {
+				return nil, fmt.Errorf("fieldcrypt: index key given twice")
+			}
+			k.index = key
+			continue
+		}
+		if _, dup := k.masters[id]; dup {
+			return nil, fmt.Errorf("fieldcrypt: key %q given twice", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.masters[id] = aead
+		if k.activeID == "" {
+			k.activeID = id
+		}
+	}
+	if k.activeID == "" {
+		return nil, ErrNoMasterKey
+	}
+	if k.index == nil {
+		return nil, ErrNoIndexKey
+	}
+	return k, nil
+}
+
+// LoadFile reads a key list in Parse's format from a file.
+func LoadFile(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(b))
+}
+
+// Load reads the key list from path when it is set and from spec otherwise.
+func Load(spec, path string) (*Keyring, error) {
+	switch {
+	case path != "":
+		return LoadFile(path)
+	case strings.TrimSpace(spec) != "":
+		return Parse(spec)
+	}
+	return nil, ErrNoKeys
+}
+
+// GenerateKey returns a new random key in the base64 form Parse accepts.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ActiveKeyID is the master key new values are sealed under.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// Seal encrypts plaintext for the named field under a new data key wrapped
+// by the active master key.
+func (k *Keyring) Seal(field, plaintext string) (string, error) {
+	dek := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.masters[k.activeID], dek, []byte(k.activeID))
+	if err != nil {
+		return "", err
+	}
+	ct, err := seal(data, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return Prefix + k.activeID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ct), nil
+}
+
+// Open decrypts a value sealed for the named field. Values without the
+// sealed prefix are returned as they are, so rows written before encryption
+// stay readable until they are re-encrypted.
+func (k *Keyring) Open(field, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	master, ok := k.masters[parts[0]]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	ct, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dek, err := open(master, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	pt, err := open(data, ct, []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// Current reports whether value is sealed under the active master key, so
+// needs no re-encryption.
+func (k *Keyring) Current(value string) bool {
+	return strings.HasPrefix(value, k.ActivePrefix())
+}
+
+// ActivePrefix starts every value sealed under the active master key.
+func (k *Keyring) ActivePrefix() string {
+	return Prefix + k.activeID + ":"
+}
+
+// IsSealed reports whether value is in sealed form.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// BlindIndex returns the hex blind index of value in the named field.
+// Callers normalize value first so that values meant to match do.
+func (k *Keyring) BlindIndex(field, value string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:indexBytes])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns a random nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	pt, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: decryption failed: %w", err)
+	}
+	return pt, nil
+}
+
+func decodeKey(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil {
+			if len(key) != KeySize {
+				return nil, fmt.Errorf("must be %d bytes, got %d", KeySize, len(key))
+			}
+			return key, nil
+		}
+	}
+	return nil, errors.New("not valid base64")
+}
+
+func validKeyID(id string) bool {
+	if id == "" || len(id) > 32 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves the system admin login; the hospital administration
+// endpoints it unlocks live on HospitalHandler.
+type AdminHandler struct {
+	adminService services.SystemAdminServiceInterface
+}
+
+func NewAdminHandler(adminService services.SystemAdminServiceInterface) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+// Login godoc
+// @Summary      System admin login
+// @Description  Authenticate a system admin and receive a token for the /admin endpoints. Staff tokens are not accepted there. Repeated wrong passwords delay and then lock the username or client IP; such attempts get 429 with Retry-After.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request body loginReq true "Login credentials"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/login [post]
+func (h *AdminHandler) Login(c *gin.Context) {
+	var req loginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, admin, err := h.adminService.Login(req.Username, req.Password, c.ClientIP())
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		writeThrottled(c, throttled)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "admin_id": admin.ID, "username": admin.UserName})
+}
+
+// Logout godoc
+// @Summary      System admin logout
+// @Description  Revoke the calling system admin token
+// @Tags         admin
+// @Security     BearerAuth
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Router       /admin/logout [post]
+func (h *AdminHandler) Logout(c *gin.Context) {
+	claims := middleware.GetSystemAdminClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := h.adminService.Logout(claims.ID, expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService services.AuditServiceInterface
+}
+
+func NewAuditHandler(auditService services.AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// List godoc
+// @Summary      List audit entries
+// @Description  List the hospital's patient access audit entries, newest first
+// @Tags         audit
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id query int false "Only entries by this staff member"
+// @Param        patient_id query int false "Only entries touching this patient"
+// @Param        action query string false "e.g. patient.search, patient.read"
+// @Param        from query string false "RFC 3339 time; entries at or after it"
+// @Param        to query string false "RFC 3339 time; entries before it"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Param        cursor query string false "next_cursor from the previous page"
+// @Param        sort query string false "-id (default, newest first) or id"
+// @Param        total query bool false "Include the number of matching entries"
+// @Security     BearerAuth
+// @Success      200  {object}  repositories.AuditPage
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/audit [get]
+func (h *AuditHandler) List(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+
+	filter := repositories.AuditFilter{Action: c.Query("action")}
+	ids := []struct {
+		name string
+		dst  **uint
+	}{{"staff_id", &filter.StaffID}, {"patient_id", &filter.PatientID}}
+	for _, p := range ids {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be a positive integer"})
+			return
+		}
+		u := uint(id)
+		*p.dst = &u
+	}
+	times := []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}}
+	for _, p := range times {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be an RFC 3339 time"})
+			return
+		}
+		*p.dst = &t
+	}
+
+	page, ok := pageRequestQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.auditService.List(hospitalID, filter, page)
+	if err != nil {
+		writeAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Verify godoc
+// @Summary      Verify the audit chain
+// @Description  Recompute the hospital's audit hash chain and report the first entry that was altered, removed or reordered
+// @Tags         audit
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      200  {object}  services.AuditVerification
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/audit/verify [get]
+func (h *AuditHandler) Verify(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	result, err := h.auditService.Verify(hospitalID)
+	if err != nil {
+		writeAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func writeAuditError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, repositories.ErrInvalidCursor), errors.Is(err, repositories.ErrInvalidSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agnos_candidate_assignment/masking"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FieldPolicyHandler struct {
+	fieldPolicyService services.FieldPolicyServiceInterface
+}
+
+func NewFieldPolicyHandler(fieldPolicyService services.FieldPolicyServiceInterface) *FieldPolicyHandler {
+	return &FieldPolicyHandler{fieldPolicyService: fieldPolicyService}
+}
+
+type replaceFieldPolicyRequest struct {
+	// Rules maps a role name, or "*" for every role, to the action for each
+	// field: full, mask or omit.
+	Rules map[string]masking.Policy `json:"rules" binding:"required"`
+}
+
+// Get godoc
+// @Summary      Get the patient field policy
+// @Description  Show which patient fields each role sees in full, masked or not at all, with the built-in defaults the hospital's rules override
+// @Tags         patients
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      200  {object}  services.FieldPolicy
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/patient-policy [get]
+func (h *FieldPolicyHandler) Get(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	policy, err := h.fieldPolicyService.Get(hospitalID)
+	if err != nil {
+		writeFieldPolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// Replace godoc
+// @Summary      Replace the patient field policy
+// @Description  Set the hospital's patient field rules, replacing all earlier ones. A role's rules override the "*" rules, which override the defaults. Fields: names, national_id, passport_id, phone_number, email, date_of_birth
+// @Tags         patients
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body replaceFieldPolicyRequest true "Rules by role"
+// @Security     BearerAuth
+// @Success      200  {object}  services.FieldPolicy
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/patient-policy [put]
+func (h *FieldPolicyHandler) Replace(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	var req replaceFieldPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, err := h.fieldPolicyService.Replace(hospitalID, req.Rules)
+	if err != nil {
+		writeFieldPolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+func writeFieldPolicyError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
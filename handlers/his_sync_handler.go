@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HISSyncHandler struct {
+	syncService services.HISSyncServiceInterface
+}
+
+func NewHISSyncHandler(syncService services.HISSyncServiceInterface) *HISSyncHandler {
+	return &HISSyncHandler{syncService: syncService}
+}
+
+// Trigger godoc
+// @Summary      Trigger HIS sync
+// @Description  Start a background sync of changed patients from the hospital's HIS
+// @Tags         his
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      202  {object}  models.HISSyncRun
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /{hospital}/his/sync [post]
+func (h *HISSyncHandler) Trigger(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+
+	run, err := h.syncService.Trigger(hospitalID)
+	switch {
+	case errors.Is(err, services.ErrSyncInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrHISNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repositories.ErrHospitalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSyncStopped):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// Status godoc
+// @Summary      Last HIS sync run
+// @Description  Show the most recent HIS sync run for the hospital
+// @Tags         his
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      200  {object}  models.HISSyncRun
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/his/sync [get]
+func (h *HISSyncHandler) Status(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+
+	run, err := h.syncService.LastRun(hospitalID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no sync runs yet"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+func hospitalIDFromContext(c *gin.Context) (uint, bool) {
+	raw, ok := c.Get("hospital_id")
+	if !ok {
+		return 0, false
+	}
+	id, ok := raw.(uint)
+	return id, ok
+}
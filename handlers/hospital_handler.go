@@ -3,7 +3,11 @@ package handlers
 import (
 	"agnos_candidate_assignment/models"
 	"agnos_candidate_assignment/repositories"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,20 +21,52 @@ func NewHospitalHandler(repo repositories.HospitalRepositoryInterface) *Hospital
 }
 
 type createHospitalRequest struct {
-	Name string `json:"name" binding:"required" example:"General Hospital"`
+	Name                 string  `json:"name" binding:"required" example:"General Hospital"`
+	APIURL               *string `json:"api_url" example:"https://hospital-a.api.co.th"`
+	HISAdapter           string  `json:"his_adapter" example:"agnos"`
+	RequireStaffApproval bool    `json:"require_staff_approval" example:"false"`
+	RequireMFA           bool    `json:"require_mfa" example:"false"`
+}
+
+// reservedHospitalNames are path segments already taken by routes under
+// /api, so a hospital with one of these names could not be addressed.
+var reservedHospitalNames = map[string]bool{
+	"admin":   true,
+	"health":  true,
+	"patient": true,
+	"roles":   true,
+}
+
+// checkHospitalName rejects names that would collide with other routes or
+// with the numeric hospital IDs that :hospital also accepts.
+func checkHospitalName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "name must not be blank"
+	}
+	if reservedHospitalNames[strings.ToLower(name)] {
+		return "name " + name + " is reserved"
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return "name must not be a number"
+	}
+	return ""
 }
 
 // Create godoc
 // @Summary      Create a new hospital
-// @Description  Register a new hospital in the system
+// @Description  Register a new hospital in the system (system admin only)
 // @Tags         hospitals
 // @Accept       json
 // @Produce      json
 // @Param        request body createHospitalRequest true "Hospital creation request"
+// @Security     BearerAuth
 // @Success      201  {object}  models.Hospital
 // @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
-// @Router       /hospital [post]
+// @Router       /admin/hospitals [post]
 func (hospitalHandler *HospitalHandler) Create(c *gin.Context) {
 	var req createHospitalRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -38,13 +74,225 @@ func (hospitalHandler *HospitalHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if msg := checkHospitalName(req.Name); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	hospital := &models.Hospital{
-		Name: req.Name,
+		Name:       strings.TrimSpace(req.Name),
+		APIURL:     req.APIURL,
+		HISAdapter: req.HISAdapter,
+
+		RequireStaffApproval: req.RequireStaffApproval,
+		RequireMFA:           req.RequireMFA,
 	}
 
-	if err := hospitalHandler.Repo.Create(hospital); err != nil {
+	err := hospitalHandler.Repo.Create(hospital)
+	if errors.Is(err, repositories.ErrDuplicateKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "hospital name already exists"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create hospital"})
 		return
 	}
 	c.JSON(http.StatusCreated, hospital)
 }
+
+// List godoc
+// @Summary      List hospitals
+// @Description  List every hospital, including deactivated ones (system admin only)
+// @Tags         hospitals
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Hospital
+// @Failure      401  {object}  map[string]string
+// @Router       /admin/hospitals [get]
+func (hospitalHandler *HospitalHandler) List(c *gin.Context) {
+	hospitals, err := hospitalHandler.Repo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list hospitals"})
+		return
+	}
+	c.JSON(http.StatusOK, hospitals)
+}
+
+type renameHospitalRequest struct {
+	Name string `json:"name" binding:"required" example:"General Hospital North"`
+}
+
+// Rename godoc
+// @Summary      Rename a hospital
+// @Description  Change a hospital's name, which is also its URL segment (system admin only)
+// @Tags         hospitals
+// @Accept       json
+// @Produce      json
+// @Param        hospital_id path int true "Hospital ID"
+// @Param        request body renameHospitalRequest true "New name"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Hospital
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id} [patch]
+func (hospitalHandler *HospitalHandler) Rename(c *gin.Context) {
+	id, ok := hospitalIDParam(c)
+	if !ok {
+		return
+	}
+	var req renameHospitalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := checkHospitalName(req.Name); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := hospitalHandler.Repo.Rename(id, strings.TrimSpace(req.Name)); err != nil {
+		writeHospitalError(c, err)
+		return
+	}
+	hospitalHandler.respondWithHospital(c, id)
+}
+
+// Deactivate godoc
+// @Summary      Deactivate a hospital
+// @Description  Suspend a hospital: its staff can no longer log in or use the API, and HIS sync stops. Data is kept (system admin only)
+// @Tags         hospitals
+// @Produce      json
+// @Param        hospital_id path int true "Hospital ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Hospital
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id}/deactivate [post]
+func (hospitalHandler *HospitalHandler) Deactivate(c *gin.Context) {
+	now := time.Now()
+	hospitalHandler.setDeactivatedAt(c, &now)
+}
+
+// Activate godoc
+// @Summary      Reactivate a hospital
+// @Description  Lift a hospital's deactivation (system admin only)
+// @Tags         hospitals
+// @Produce      json
+// @Param        hospital_id path int true "Hospital ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Hospital
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id}/activate [post]
+func (hospitalHandler *HospitalHandler) Activate(c *gin.Context) {
+	hospitalHandler.setDeactivatedAt(c, nil)
+}
+
+func (hospitalHandler *HospitalHandler) setDeactivatedAt(c *gin.Context, at *time.Time) {
+	id, ok := hospitalIDParam(c)
+	if !ok {
+		return
+	}
+	if err := hospitalHandler.Repo.SetDeactivatedAt(id, at); err != nil {
+		writeHospitalError(c, err)
+		return
+	}
+	hospitalHandler.respondWithHospital(c, id)
+}
+
+type hospitalSecurityRequest struct {
+	RequireMFA           *bool `json:"require_mfa" example:"true"`
+	RequireStaffApproval *bool `json:"require_staff_approval" example:"false"`
+}
+
+// UpdateSecurity godoc
+// @Summary      Change hospital security settings
+// @Description  Require TOTP MFA for every staff login, or admin approval of new staff. Omitted settings are unchanged (system admin only)
+// @Tags         hospitals
+// @Accept       json
+// @Produce      json
+// @Param        hospital_id path int true "Hospital ID"
+// @Param        request body hospitalSecurityRequest true "Settings to change"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Hospital
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id}/security [patch]
+func (hospitalHandler *HospitalHandler) UpdateSecurity(c *gin.Context) {
+	id, ok := hospitalIDParam(c)
+	if !ok {
+		return
+	}
+	var req hospitalSecurityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := hospitalHandler.Repo.UpdateSecurity(id, repositories.HospitalSecurity{
+		RequireMFA:           req.RequireMFA,
+		RequireStaffApproval: req.RequireStaffApproval,
+	})
+	if err != nil {
+		writeHospitalError(c, err)
+		return
+	}
+	hospitalHandler.respondWithHospital(c, id)
+}
+
+// Delete godoc
+// @Summary      Delete a hospital
+// @Description  Delete a hospital that has no staff or patients; deactivate hospitals that do (system admin only)
+// @Tags         hospitals
+// @Param        hospital_id path int true "Hospital ID"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id} [delete]
+func (hospitalHandler *HospitalHandler) Delete(c *gin.Context) {
+	id, ok := hospitalIDParam(c)
+	if !ok {
+		return
+	}
+	if err := hospitalHandler.Repo.Delete(id); err != nil {
+		writeHospitalError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (hospitalHandler *HospitalHandler) respondWithHospital(c *gin.Context, id uint) {
+	hospital, err := hospitalHandler.Repo.FindByID(id)
+	if err != nil {
+		writeHospitalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hospital)
+}
+
+func hospitalIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("hospital_id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hospital id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeHospitalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrHospitalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrDuplicateKey):
+		c.JSON(http.StatusConflict, gin.H{"error": "hospital name already exists"})
+	case errors.Is(err, repositories.ErrHospitalInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "; deactivate it instead"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agnos_candidate_assignment/keyset"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keys *keyset.Set
+}
+
+func NewJWKSHandler(keys *keyset.Set) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// Get godoc
+// @Summary      Token signing keys
+// @Description  Public keys that verify API tokens, as a JSON Web Key Set. Keys retired by rotation stay listed until the tokens they signed have expired.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  keyset.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) Get(c *gin.Context) {
+	// Short enough that verifiers see a rotated key well before it signs
+	// anything they could not check.
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
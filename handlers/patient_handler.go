@@ -1,20 +1,29 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"agnos_candidate_assignment/masking"
 	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
 	"agnos_candidate_assignment/services"
+	"agnos_candidate_assignment/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PatientHandler struct {
 	patientService services.PatientServiceInterface
+	fieldPolicies  services.FieldPolicyServiceInterface
 }
 
-func NewPatientHandler(patientService services.PatientServiceInterface) *PatientHandler {
-	return &PatientHandler{patientService: patientService}
+func NewPatientHandler(patientService services.PatientServiceInterface, fieldPolicies services.FieldPolicyServiceInterface) *PatientHandler {
+	return &PatientHandler{patientService: patientService, fieldPolicies: fieldPolicies}
 }
 
 // Search godoc
@@ -23,6 +32,7 @@ func NewPatientHandler(patientService services.PatientServiceInterface) *Patient
 // @Tags         patients
 // @Accept       json
 // @Produce      json
+// @Param        patient_hn query string false "Hospital number"
 // @Param        national_id query string false "National ID"
 // @Param        passport_id query string false "Passport ID"
 // @Param        first_name query string false "First name (any language)"
@@ -34,11 +44,22 @@ func NewPatientHandler(patientService services.PatientServiceInterface) *Patient
 // @Param        first_name_en query string false "First name (English)"
 // @Param        middle_name_en query string false "Middle name (English)"
 // @Param        last_name_en query string false "Last name (English)"
-// @Param        date_of_birth query string false "Date of birth"
+// @Param        date_of_birth query string false "Date of birth: YYYY-MM-DD, DD/MM/YYYY or a year; BE years (e.g. 2530) are converted"
+// @Param        dob_from query string false "Born on or after (same formats; a year means 1 January)"
+// @Param        dob_to query string false "Born on or before (same formats; a year means 31 December)"
+// @Param        age_min query int false "Minimum age in whole years"
+// @Param        age_max query int false "Maximum age in whole years"
 // @Param        phone_number query string false "Phone number"
 // @Param        email query string false "Email"
+// @Param        gender query string false "M or F"
+// @Param        match query string false "Name matching: exact, prefix, contains or fuzzy (trigram)" default(exact)
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Param        cursor query string false "next_cursor from the previous page"
+// @Param        sort query string false "id, -id, updated_at, -updated_at, or relevance (default for non-exact name matches)"
+// @Param        total query bool false "Include the total match count"
 // @Security     BearerAuth
-// @Success      200  {object}  map[string]interface{}
+// @Success      200  {object}  repositories.PatientPage
+// @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /patient/search [get]
@@ -48,71 +69,302 @@ func (patientHandler *PatientHandler) Search(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
 		return
 	}
-	hospitalID := claims.HospitalID
-	filters := map[string]any{}
-	if v := c.Query("national_id"); v != "" {
-		filters["national_id"] = v
+	criteria := repositories.PatientSearchCriteria{
+		PatientHN:    c.Query("patient_hn"),
+		NationalID:   c.Query("national_id"),
+		PassportID:   c.Query("passport_id"),
+		FirstName:    c.Query("first_name"),
+		MiddleName:   c.Query("middle_name"),
+		LastName:     c.Query("last_name"),
+		FirstNameTH:  c.Query("first_name_th"),
+		MiddleNameTH: c.Query("middle_name_th"),
+		LastNameTH:   c.Query("last_name_th"),
+		FirstNameEN:  c.Query("first_name_en"),
+		MiddleNameEN: c.Query("middle_name_en"),
+		LastNameEN:   c.Query("last_name_en"),
+		PhoneNumber:  c.Query("phone_number"),
+		Email:        c.Query("email"),
+	}
+	gender, err := searchGender(c.Query("gender"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	criteria.Gender = gender
+	if err := setSearchDates(&criteria, c.Query("date_of_birth"), c.Query("dob_from"), c.Query("dob_to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ages := []struct {
+		name string
+		dst  **int
+	}{{"age_min", &criteria.AgeMin}, {"age_max", &criteria.AgeMax}}
+	for _, a := range ages {
+		v := c.Query(a.name)
+		if v == "" {
+			continue
+		}
+		age, err := strconv.Atoi(v)
+		if err != nil || age < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": a.name + " must be a non-negative integer"})
+			return
+		}
+		*a.dst = &age
+	}
+
+	match, err := repositories.ParseMatchMode(c.Query("match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match must be one of exact, prefix, contains, fuzzy"})
+		return
+	}
+	criteria.Match = match
+
+	page, ok := pageRequestQuery(c)
+	if !ok {
+		return
+	}
+
+	results, err := patientHandler.patientService.Search(claims.HospitalID, criteria, page)
+	if err != nil {
+		writePatientError(c, err)
+		return
+	}
+	auditPatientPage(c, results)
+	patientHandler.writePatientPage(c, results)
+}
+
+// maxSearchDepth bounds how deeply any_of/all_of groups may nest,
+// maxSearchGroupSize how many groups one any_of or all_of may hold, and
+// maxSearchGroups how many groups the whole search may hold.
+const (
+	maxSearchDepth     = 4
+	maxSearchGroupSize = 10
+	maxSearchGroups    = 50
+)
+
+// searchGender checks a gender filter, which may be empty.
+func searchGender(s string) (models.Gender, error) {
+	switch g := models.Gender(s); g {
+	case "", models.Male, models.Female:
+		return g, nil
 	}
-	if v := c.Query("passport_id"); v != "" {
-		filters["passport_id"] = v
+	return "", errors.New("gender must be M or F")
+}
+
+// patientSearchRequest is the JSON form of repositories.PatientSearchCriteria,
+// with dates as strings in any format setSearchDates accepts.
+type patientSearchRequest struct {
+	PatientHN    string                 `json:"patient_hn"`
+	NationalID   string                 `json:"national_id"`
+	PassportID   string                 `json:"passport_id"`
+	FirstName    string                 `json:"first_name" example:"Somchai"`
+	MiddleName   string                 `json:"middle_name"`
+	LastName     string                 `json:"last_name"`
+	FirstNameTH  string                 `json:"first_name_th"`
+	MiddleNameTH string                 `json:"middle_name_th"`
+	LastNameTH   string                 `json:"last_name_th"`
+	FirstNameEN  string                 `json:"first_name_en"`
+	MiddleNameEN string                 `json:"middle_name_en"`
+	LastNameEN   string                 `json:"last_name_en"`
+	PhoneNumber  string                 `json:"phone_number"`
+	Email        string                 `json:"email"`
+	Gender       string                 `json:"gender" example:"M"`
+	DateOfBirth  string                 `json:"date_of_birth" example:"12/04/2528"`
+	DOBFrom      string                 `json:"dob_from" example:"1980-01-01"`
+	DOBTo        string                 `json:"dob_to" example:"2532"`
+	AgeMin       *int                   `json:"age_min"`
+	AgeMax       *int                   `json:"age_max"`
+	AnyOf        []patientSearchRequest `json:"any_of"`
+	AllOf        []patientSearchRequest `json:"all_of"`
+	Match        string                 `json:"match" example:"exact"`
+}
+
+func (req *patientSearchRequest) criteria(depth int, groups *int) (repositories.PatientSearchCriteria, error) {
+	if depth > maxSearchDepth {
+		return repositories.PatientSearchCriteria{}, fmt.Errorf("search groups nest at most %d levels deep", maxSearchDepth)
 	}
-	if v := c.Query("first_name"); v != "" {
-		filters["first_name"] = v
+	if len(req.AnyOf) > maxSearchGroupSize || len(req.AllOf) > maxSearchGroupSize {
+		return repositories.PatientSearchCriteria{}, fmt.Errorf("any_of and all_of hold at most %d groups each", maxSearchGroupSize)
 	}
-	if v := c.Query("middle_name"); v != "" {
-		filters["middle_name"] = v
+	if *groups += len(req.AnyOf) + len(req.AllOf); *groups > maxSearchGroups {
+		return repositories.PatientSearchCriteria{}, fmt.Errorf("a search holds at most %d groups", maxSearchGroups)
 	}
-	if v := c.Query("last_name"); v != "" {
-		filters["last_name"] = v
+	gender, err := searchGender(req.Gender)
+	if err != nil {
+		return repositories.PatientSearchCriteria{}, err
 	}
-	if v := c.Query("first_name_th"); v != "" {
-		filters["first_name_th"] = v
+	match, err := repositories.ParseMatchMode(req.Match)
+	if err != nil {
+		return repositories.PatientSearchCriteria{}, errors.New("match must be one of exact, prefix, contains, fuzzy")
 	}
-	if v := c.Query("middle_name_th"); v != "" {
-		filters["middle_name_th"] = v
+
+	criteria := repositories.PatientSearchCriteria{
+		PatientHN:    req.PatientHN,
+		NationalID:   req.NationalID,
+		PassportID:   req.PassportID,
+		FirstName:    req.FirstName,
+		MiddleName:   req.MiddleName,
+		LastName:     req.LastName,
+		FirstNameTH:  req.FirstNameTH,
+		MiddleNameTH: req.MiddleNameTH,
+		LastNameTH:   req.LastNameTH,
+		FirstNameEN:  req.FirstNameEN,
+		MiddleNameEN: req.MiddleNameEN,
+		LastNameEN:   req.LastNameEN,
+		PhoneNumber:  req.PhoneNumber,
+		Email:        req.Email,
+		Gender:       gender,
+		AgeMin:       req.AgeMin,
+		AgeMax:       req.AgeMax,
+		Match:        match,
 	}
-	if v := c.Query("last_name_th"); v != "" {
-		filters["last_name_th"] = v
+
+	if err := setSearchDates(&criteria, req.DateOfBirth, req.DOBFrom, req.DOBTo); err != nil {
+		return criteria, err
 	}
-	if v := c.Query("first_name_en"); v != "" {
-		filters["first_name_en"] = v
+
+	for i := range req.AnyOf {
+		group, err := req.AnyOf[i].criteria(depth+1, groups)
+		if err != nil {
+			return criteria, err
+		}
+		criteria.AnyOf = append(criteria.AnyOf, group)
 	}
-	if v := c.Query("middle_name_en"); v != "" {
-		filters["middle_name_en"] = v
+	for i := range req.AllOf {
+		group, err := req.AllOf[i].criteria(depth+1, groups)
+		if err != nil {
+			return criteria, err
+		}
+		criteria.AllOf = append(criteria.AllOf, group)
 	}
-	if v := c.Query("last_name_en"); v != "" {
-		filters["last_name_en"] = v
+	return criteria, nil
+}
+
+// SearchAdvanced godoc
+// @Summary      Search patients with grouped criteria
+// @Description  Search with a JSON body that supports date ranges, age bands and nested any_of (OR) / all_of (AND) groups. Top-level fields must all match. Paging uses the same query parameters as GET /patient/search.
+// @Tags         patients
+// @Accept       json
+// @Produce      json
+// @Param        request body patientSearchRequest true "Search criteria"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Param        cursor query string false "next_cursor from the previous page"
+// @Param        sort query string false "id, -id, updated_at, -updated_at, or relevance (default for non-exact name matches)"
+// @Param        total query bool false "Include the total match count"
+// @Security     BearerAuth
+// @Success      200  {object}  repositories.PatientPage
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /patient/search [post]
+func (patientHandler *PatientHandler) SearchAdvanced(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return
 	}
-	if v := c.Query("date_of_birth"); v != "" {
-		filters["date_of_birth"] = v
+
+	var req patientSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if v := c.Query("phone_number"); v != "" {
-		filters["phone_number"] = v
+	middleware.SetAuditFilters(c, req)
+	groups := 0
+	criteria, err := req.criteria(1, &groups)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if v := c.Query("email"); v != "" {
-		filters["email"] = v
+
+	page, ok := pageRequestQuery(c)
+	if !ok {
+		return
 	}
 
-	results, err := patientHandler.patientService.Search(hospitalID, filters)
+	results, err := patientHandler.patientService.Search(claims.HospitalID, criteria, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writePatientError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"patients": results})
+	auditPatientPage(c, results)
+	patientHandler.writePatientPage(c, results)
+}
+
+// setSearchDates parses the date filters of a search. Each accepts ISO,
+// DD/MM/YYYY and Buddhist Era years (see validation.ParseDateSpan); a bare
+// year in date_of_birth matches anyone born that year, and a bare year in
+// dob_from or dob_to extends to the start or end of it.
+func setSearchDates(criteria *repositories.PatientSearchCriteria, dob, from, to string) error {
+	if from != "" {
+		start, _, err := validation.ParseDateSpan("dob_from", from)
+		if err != nil {
+			return err
+		}
+		criteria.DOBFrom = &start
+	}
+	if to != "" {
+		_, end, err := validation.ParseDateSpan("dob_to", to)
+		if err != nil {
+			return err
+		}
+		criteria.DOBTo = &end
+	}
+	if dob == "" {
+		return nil
+	}
+
+	start, end, err := validation.ParseDateSpan("date_of_birth", dob)
+	if err != nil {
+		return err
+	}
+	if start.Equal(end) {
+		criteria.DateOfBirth = &start
+		return nil
+	}
+	// Narrow any explicit range to the year rather than replacing it.
+	if criteria.DOBFrom == nil || start.After(*criteria.DOBFrom) {
+		criteria.DOBFrom = &start
+	}
+	if criteria.DOBTo == nil || end.Before(*criteria.DOBTo) {
+		criteria.DOBTo = &end
+	}
+	return nil
+}
+
+func pageRequestQuery(c *gin.Context) (repositories.PageRequest, bool) {
+	page := repositories.PageRequest{
+		Cursor:       c.Query("cursor"),
+		Sort:         c.Query("sort"),
+		IncludeTotal: c.Query("total") == "true",
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return page, false
+		}
+		page.Limit = limit
+	}
+	return page, true
 }
 
 // GetByID godoc
 // @Summary      Get patient by ID
-// @Description  Retrieve patient by national ID or passport ID (public endpoint)
+// @Description  Retrieve a patient of the staff's hospital by national ID or passport ID
 // @Tags         patients
 // @Accept       json
 // @Produce      json
 // @Param        hospital path string true "Hospital name"
-// @Param        id path string true "National ID or Passport ID"
+// @Param        id path string true "13-digit Thai national ID (dashes allowed) or passport number"
+// @Security     BearerAuth
 // @Success      200  {object}  models.Patient
 // @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
-// @Router       /:hospital/patient/search/{id} [get]
+// @Failure      500  {object}  map[string]string
+// @Router       /{hospital}/patient/search/{id} [get]
 func (h *PatientHandler) GetByID(c *gin.Context) {
 	raw, ok := c.Get("hospital_id")
 	if !ok {
@@ -123,8 +375,348 @@ func (h *PatientHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	p, err := h.patientService.GetByNationalOrPassport(hospitalID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, p.ID)
+	h.writePatient(c, http.StatusOK, p)
+}
+
+// Verify godoc
+// @Summary      Verify a patient record
+// @Description  Public check that a hospital holds a record for a national ID or passport number. Only masked names, the birth year and masked contact details are returned. Rate limited per client; disabled unless PUBLIC_PATIENT_VERIFY is set.
+// @Tags         patients
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        id path string true "13-digit Thai national ID (dashes allowed) or passport number"
+// @Success      200  {object}  services.PatientVerification
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Router       /{hospital}/patient/verify/{id} [get]
+func (h *PatientHandler) Verify(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	v, err := h.patientService.Verify(hospitalID, c.Param("id"))
+	if err != nil {
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, v.PatientID)
+	c.JSON(http.StatusOK, v)
+}
+
+type createPatientRequest struct {
+	FirstNameTH  *string `json:"first_name_th" example:"สมชาย"`
+	MiddleNameTH *string `json:"middle_name_th"`
+	LastNameTH   *string `json:"last_name_th" example:"ใจดี"`
+	FirstNameEN  *string `json:"first_name_en" example:"Somchai"`
+	MiddleNameEN *string `json:"middle_name_en"`
+	LastNameEN   *string `json:"last_name_en" example:"Jaidee"`
+	DateOfBirth  string  `json:"date_of_birth" binding:"required" example:"1985-04-12"`
+	PatientHN    string  `json:"patient_hn" binding:"required" example:"HN00001"`
+	NationalID   *string `json:"national_id" example:"1100700000001"`
+	PassportID   *string `json:"passport_id" example:"AA1234567"`
+	PhoneNumber  *string `json:"phone_number" example:"0812345678"`
+	Email        *string `json:"email" example:"somchai@example.com"`
+	Gender       string  `json:"gender" binding:"required" example:"M"`
+}
+
+type updatePatientRequest struct {
+	FirstNameTH  *string `json:"first_name_th"`
+	MiddleNameTH *string `json:"middle_name_th"`
+	LastNameTH   *string `json:"last_name_th"`
+	FirstNameEN  *string `json:"first_name_en"`
+	MiddleNameEN *string `json:"middle_name_en"`
+	LastNameEN   *string `json:"last_name_en"`
+	DateOfBirth  *string `json:"date_of_birth" example:"1985-04-12"`
+	PatientHN    *string `json:"patient_hn"`
+	NationalID   *string `json:"national_id"`
+	PassportID   *string `json:"passport_id"`
+	PhoneNumber  *string `json:"phone_number"`
+	Email        *string `json:"email"`
+	Gender       *string `json:"gender"`
+}
+
+// Create godoc
+// @Summary      Create patient
+// @Description  Create a patient in the authenticated staff's hospital
+// @Tags         patients
+// @Accept       json
+// @Produce      json
+// @Param        request body createPatientRequest true "Patient"
+// @Security     BearerAuth
+// @Success      201  {object}  models.Patient
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /patient [post]
+func (patientHandler *PatientHandler) Create(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return
+	}
+
+	var req createPatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dob, err := time.Parse("2006-01-02", req.DateOfBirth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date_of_birth must be YYYY-MM-DD"})
+		return
+	}
+
+	p := &models.Patient{
+		FirstNameTH:  req.FirstNameTH,
+		MiddleNameTH: req.MiddleNameTH,
+		LastNameTH:   req.LastNameTH,
+		FirstNameEN:  req.FirstNameEN,
+		MiddleNameEN: req.MiddleNameEN,
+		LastNameEN:   req.LastNameEN,
+		DateOfBirth:  dob,
+		PatientHN:    req.PatientHN,
+		NationalID:   req.NationalID,
+		PassportID:   req.PassportID,
+		PhoneNumber:  req.PhoneNumber,
+		Email:        req.Email,
+		Gender:       models.Gender(req.Gender),
+	}
+	if err := patientHandler.patientService.Create(claims.HospitalID, p); err != nil {
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, p.ID)
+	patientHandler.writePatient(c, http.StatusCreated, p)
+}
+
+// Get godoc
+// @Summary      Get patient
+// @Description  Get a patient of the authenticated staff's hospital by internal ID
+// @Tags         patients
+// @Produce      json
+// @Param        patient_id path int true "Patient ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /patient/{patient_id} [get]
+func (patientHandler *PatientHandler) Get(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return
+	}
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	p, err := patientHandler.patientService.Get(claims.HospitalID, id)
+	if err != nil {
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, p.ID)
+	patientHandler.writePatient(c, http.StatusOK, p)
+}
+
+// Update godoc
+// @Summary      Update patient
+// @Description  Partially update a patient; omitted fields are unchanged and "" clears optional fields
+// @Tags         patients
+// @Accept       json
+// @Produce      json
+// @Param        patient_id path int true "Patient ID"
+// @Param        request body updatePatientRequest true "Fields to change"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Patient
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /patient/{patient_id} [patch]
+func (patientHandler *PatientHandler) Update(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return
+	}
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req updatePatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upd := services.PatientUpdate{
+		FirstNameTH:  req.FirstNameTH,
+		MiddleNameTH: req.MiddleNameTH,
+		LastNameTH:   req.LastNameTH,
+		FirstNameEN:  req.FirstNameEN,
+		MiddleNameEN: req.MiddleNameEN,
+		LastNameEN:   req.LastNameEN,
+		PatientHN:    req.PatientHN,
+		NationalID:   req.NationalID,
+		PassportID:   req.PassportID,
+		PhoneNumber:  req.PhoneNumber,
+		Email:        req.Email,
+	}
+	if req.DateOfBirth != nil {
+		dob, err := time.Parse("2006-01-02", *req.DateOfBirth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_of_birth must be YYYY-MM-DD"})
+			return
+		}
+		upd.DateOfBirth = &dob
+	}
+	if req.Gender != nil {
+		g := models.Gender(*req.Gender)
+		upd.Gender = &g
+	}
+
+	p, err := patientHandler.patientService.Update(claims.HospitalID, id, upd)
+	if err != nil {
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, p.ID)
+	patientHandler.writePatient(c, http.StatusOK, p)
+}
+
+// Delete godoc
+// @Summary      Delete patient
+// @Description  Delete a patient of the authenticated staff's hospital
+// @Tags         patients
+// @Param        patient_id path int true "Patient ID"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /patient/{patient_id} [delete]
+func (patientHandler *PatientHandler) Delete(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return
+	}
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := patientHandler.patientService.Delete(claims.HospitalID, id); err != nil {
+		writePatientError(c, err)
+		return
+	}
+	middleware.SetAuditPatients(c, id)
+	c.Status(http.StatusNoContent)
+}
+
+// writePatient and writePatientPage send patients as the hospital's field
+// policy lets the caller's role see them. Every patient response goes
+// through them, so search, lookup and any export agree on what is shown.
+func (h *PatientHandler) writePatient(c *gin.Context, status int, p *models.Patient) {
+	policy, ok := h.fieldPolicy(c)
+	if !ok {
+		return
+	}
+	if policy.Full() {
+		c.JSON(status, p)
+		return
+	}
+	doc, err := policy.Shape(p)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, doc)
+}
+
+func (h *PatientHandler) writePatientPage(c *gin.Context, page *repositories.PatientPage) {
+	policy, ok := h.fieldPolicy(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, p)
+	if policy.Full() {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+	shaped := shapedPatientPage{Patients: make([]map[string]interface{}, len(page.Patients)), NextCursor: page.NextCursor, Total: page.Total}
+	for i := range page.Patients {
+		doc, err := policy.Shape(&page.Patients[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		shaped.Patients[i] = doc
+	}
+	c.JSON(http.StatusOK, shaped)
+}
+
+// shapedPatientPage is repositories.PatientPage with each patient shaped by
+// a field policy.
+type shapedPatientPage struct {
+	Patients   []map[string]interface{} `json:"patients"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	Total      *int64                   `json:"total,omitempty"`
+}
+
+func (h *PatientHandler) fieldPolicy(c *gin.Context) (masking.Policy, bool) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing staff claims"})
+		return nil, false
+	}
+	policy, err := h.fieldPolicies.PolicyFor(claims.HospitalID, claims.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return policy, true
+}
+
+// auditPatientPage notes the patients on a search page for the audit log.
+func auditPatientPage(c *gin.Context, page *repositories.PatientPage) {
+	ids := make([]uint, len(page.Patients))
+	for i := range page.Patients {
+		ids[i] = page.Patients[i].ID
+	}
+	middleware.SetAuditPatients(c, ids...)
+}
+
+func patientIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("patient_id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writePatientError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	var conflictErr *services.ConflictError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, gin.H{"error": conflictErr.Error()})
+	case errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService services.RoleServiceInterface
+}
+
+func NewRoleHandler(roleService services.RoleServiceInterface) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// List godoc
+// @Summary      List roles
+// @Description  List the roles staff can hold and the permissions each grants
+// @Tags         roles
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Role
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /roles [get]
+func (h *RoleHandler) List(c *gin.Context) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+type assignRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"nurse"`
+}
+
+// Assign godoc
+// @Summary      Assign a staff role
+// @Description  Change the role of a staff member of the hospital; it applies from their next login
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Param        request body assignRoleRequest true "Role"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/role [put]
+func (h *RoleHandler) Assign(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	staffID, err := strconv.ParseUint(c.Param("staff_id"), 10, 64)
+	if err != nil || staffID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff id"})
+		return
+	}
+
+	var req assignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staff, err := h.roleService.AssignRole(hospitalID, uint(staffID), req.Role)
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaffAdminHandler serves the staff directory and the staff management
+// endpoints used by hospital admins.
+type StaffAdminHandler struct {
+	staffService services.StaffServiceInterface
+}
+
+func NewStaffAdminHandler(staffService services.StaffServiceInterface) *StaffAdminHandler {
+	return &StaffAdminHandler{staffService: staffService}
+}
+
+type inviteRequest struct {
+	Role           string `json:"role" example:"nurse"`
+	ExpiresInHours int    `json:"expires_in_hours" example:"72"`
+}
+
+// Invite godoc
+// @Summary      Invite a staff member
+// @Description  Issue a single-use registration token for the hospital. The token is returned only in this response.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body inviteRequest false "Role for the new account and expiry (default 72 hours)"
+// @Security     BearerAuth
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/staff/invitations [post]
+func (h *StaffAdminHandler) Invite(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	claims := middleware.GetStaffClaims(c)
+	if !ok || claims == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+
+	var req inviteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	token, inv, err := h.staffService.Invite(hospitalID, claims.StaffID, req.Role, time.Duration(req.ExpiresInHours)*time.Hour)
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := ""
+	if inv.Role != nil {
+		role = inv.Role.Name
+	}
+	c.JSON(http.StatusCreated, gin.H{"invitation_id": inv.ID, "token": token, "role": role, "expires_at": inv.ExpiresAt})
+}
+
+type adminInvitationRequest struct {
+	ExpiresInHours int `json:"expires_in_hours" example:"72"`
+}
+
+// InviteAdmin godoc
+// @Summary      Invite a hospital admin
+// @Description  Issue a single-use token to register as an admin of the hospital, such as its first one. The account is active at once, even when the hospital requires approval. The token is returned only in this response (system admin only)
+// @Tags         hospitals
+// @Accept       json
+// @Produce      json
+// @Param        hospital_id path int true "Hospital ID"
+// @Param        request body adminInvitationRequest false "Expiry (default 72 hours)"
+// @Security     BearerAuth
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/hospitals/{hospital_id}/invitations [post]
+func (h *StaffAdminHandler) InviteAdmin(c *gin.Context) {
+	hospitalID, ok := hospitalIDParam(c)
+	if !ok {
+		return
+	}
+	claims := middleware.GetSystemAdminClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing system admin claims"})
+		return
+	}
+
+	var req adminInvitationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	token, inv, err := h.staffService.InviteAdmin(hospitalID, claims.AdminID, time.Duration(req.ExpiresInHours)*time.Hour)
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repositories.ErrHospitalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invitation_id": inv.ID, "token": token, "role": models.RoleAdmin, "expires_at": inv.ExpiresAt})
+}
+
+// Approve godoc
+// @Summary      Approve a pending staff member
+// @Description  Activate a staff account that registered while the hospital requires approval
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/approve [post]
+func (h *StaffAdminHandler) Approve(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	staffID, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.Approve(hospitalID, staffID)
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrStaffNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// Lockout godoc
+// @Summary      Show a staff member's login lockout
+// @Description  Report the staff member's consecutive failed logins and whether logins are locked
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      200  {object}  services.Lockout
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/lockout [get]
+func (h *StaffAdminHandler) Lockout(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	staffID, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	lockout, err := h.staffService.Lockout(hospitalID, staffID)
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lockout)
+}
+
+// Unlock godoc
+// @Summary      Clear a staff member's login lockout
+// @Description  Reset the staff member's failed logins and lift the lock on their account
+// @Tags         staff
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/lockout [delete]
+func (h *StaffAdminHandler) Unlock(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	staffID, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.staffService.Unlock(hospitalID, staffID)
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// IssuePasswordReset godoc
+// @Summary      Issue a password reset token
+// @Description  Create a single-use token, valid for 24 hours, with which the staff member sets a new password at /staff/password/reset. Earlier unused tokens stop working. The token is returned only in this response.
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/password-reset [post]
+func (h *StaffAdminHandler) IssuePasswordReset(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	claims := middleware.GetStaffClaims(c)
+	if !ok || claims == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	staffID, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	token, reset, err := h.staffService.IssuePasswordReset(hospitalID, claims.StaffID, staffID)
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"staff_id": reset.StaffID, "token": token, "expires_at": reset.ExpiresAt})
+}
+
+type updateStaffProfileRequest struct {
+	DisplayName   *string `json:"display_name" example:"Dr. Somchai Jaidee"`
+	LicenseNumber *string `json:"license_number" example:"MD-12345"`
+	Department    *string `json:"department" example:"Cardiology"`
+}
+
+// Me godoc
+// @Summary      Get own profile
+// @Description  Return the calling staff member's account and profile
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      401  {object}  map[string]string
+// @Router       /{hospital}/staff/me [get]
+func (h *StaffAdminHandler) Me(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	staff, err := h.staffService.Get(claims.HospitalID, claims.StaffID)
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// List godoc
+// @Summary      List staff
+// @Description  List the hospital's staff, one keyset page at a time. Deleted staff are not listed.
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        status query string false "active, pending or disabled"
+// @Param        role query string false "Role name"
+// @Param        q query string false "Matches user names and display names"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Param        cursor query string false "next_cursor from the previous page"
+// @Param        sort query string false "id, -id, updated_at or -updated_at"
+// @Param        total query bool false "Include the number of matching staff"
+// @Security     BearerAuth
+// @Success      200  {object}  repositories.StaffPage
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /{hospital}/staff [get]
+func (h *StaffAdminHandler) List(c *gin.Context) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return
+	}
+	page, ok := pageRequestQuery(c)
+	if !ok {
+		return
+	}
+	filter := repositories.StaffFilter{
+		Status: c.Query("status"),
+		Role:   c.Query("role"),
+		Query:  strings.TrimSpace(c.Query("q")),
+	}
+
+	result, err := h.staffService.List(hospitalID, filter, page)
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Get godoc
+// @Summary      Get a staff member
+// @Description  Return a staff member's account and profile
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id} [get]
+func (h *StaffAdminHandler) Get(c *gin.Context) {
+	hospitalID, staffID, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+	staff, err := h.staffService.Get(hospitalID, staffID)
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// UpdateProfile godoc
+// @Summary      Edit a staff profile
+// @Description  Change a staff member's display name, license number or department. Omitted fields are unchanged; an empty string clears one.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Param        request body updateStaffProfileRequest true "Profile fields"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id} [patch]
+func (h *StaffAdminHandler) UpdateProfile(c *gin.Context) {
+	hospitalID, staffID, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+	var req updateStaffProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staff, err := h.staffService.UpdateProfile(hospitalID, staffID, repositories.StaffProfile{
+		DisplayName:   req.DisplayName,
+		LicenseNumber: req.LicenseNumber,
+		Department:    req.Department,
+	})
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// Disable godoc
+// @Summary      Disable a staff member
+// @Description  Block the staff member from logging in and end their sessions immediately. Admins cannot disable themselves or the hospital's last admin.
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/disable [post]
+func (h *StaffAdminHandler) Disable(c *gin.Context) {
+	hospitalID, staffID, ok := staffTarget(c)
+	claims := middleware.GetStaffClaims(c)
+	if !ok {
+		return
+	}
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	staff, err := h.staffService.Disable(hospitalID, claims.StaffID, staffID)
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// Enable godoc
+// @Summary      Re-enable a staff member
+// @Description  Let a disabled staff member log in again
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      200  {object}  models.Staff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id}/enable [post]
+func (h *StaffAdminHandler) Enable(c *gin.Context) {
+	hospitalID, staffID, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+	staff, err := h.staffService.Enable(hospitalID, staffID)
+	if err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// Delete godoc
+// @Summary      Delete a staff member
+// @Description  Soft-delete a staff member: the account is removed from the API and its sessions end, but the record and its audit history are kept. Admins cannot delete themselves or the hospital's last admin.
+// @Tags         staff
+// @Param        hospital path string true "Hospital name"
+// @Param        staff_id path int true "Staff ID"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /{hospital}/staff/{staff_id} [delete]
+func (h *StaffAdminHandler) Delete(c *gin.Context) {
+	hospitalID, staffID, ok := staffTarget(c)
+	claims := middleware.GetStaffClaims(c)
+	if !ok {
+		return
+	}
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	if err := h.staffService.Delete(hospitalID, claims.StaffID, staffID); err != nil {
+		writeStaffError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// staffTarget reads the hospital from the context and the staff ID from the
+// path, answering 400 if either is missing.
+func staffTarget(c *gin.Context) (uint, uint, bool) {
+	hospitalID, ok := hospitalIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital context missing"})
+		return 0, 0, false
+	}
+	staffID, ok := staffIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	return hospitalID, staffID, true
+}
+
+func writeStaffError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, repositories.ErrInvalidCursor), errors.Is(err, repositories.ErrInvalidSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotModifySelf), errors.Is(err, services.ErrLastAdmin), errors.Is(err, services.ErrStaffNotDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func staffIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("staff_id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff id"})
+		return 0, false
+	}
+	return uint(id), true
+}
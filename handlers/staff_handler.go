@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
 	"agnos_candidate_assignment/services"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,13 +23,14 @@ func NewStaffHandler(authser services.AuthServiceInterface) *StaffHandler {
 }
 
 type registerReq struct {
-	Username string `json:"username" binding:"required" example:"admin"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Username        string `json:"username" binding:"required" example:"admin"`
+	Password        string `json:"password" binding:"required" example:"password123"`
+	InvitationToken string `json:"invitation_token" example:"q7F3...Zk"`
 }
 
 // Register godoc
 // @Summary      Register a new staff member
-// @Description  Create a new staff account for a hospital
+// @Description  Create a staff account with an invitation. A hospital's first admin registers with an invitation issued by a system admin, later staff with one issued by a hospital admin. The password must satisfy the password policy.
 // @Tags         staff
 // @Accept       json
 // @Produce      json
@@ -30,6 +38,9 @@ type registerReq struct {
 // @Param        request body registerReq true "Staff registration request"
 // @Success      201  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /:hospital/staff/create [post]
 func (staffHandler *StaffHandler) Register(c *gin.Context) {
@@ -41,12 +52,29 @@ func (staffHandler *StaffHandler) Register(c *gin.Context) {
 		return
 	}
 
-	staff, err := staffHandler.authService.Register(hospital, req.Username, req.Password)
+	staff, err := staffHandler.authService.Register(hospital, req.Username, req.Password, req.InvitationToken)
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInvitationRequired) || errors.Is(err, repositories.ErrInvitationInvalid) || errors.Is(err, services.ErrHospitalInactive) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repositories.ErrHospitalNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"staff_id": staff.ID, "username": staff.UserName})
+	c.JSON(http.StatusCreated, gin.H{"staff_id": staff.ID, "username": staff.UserName, "status": staff.Status})
 }
 
 type loginReq struct {
@@ -56,7 +84,7 @@ type loginReq struct {
 
 // Login godoc
 // @Summary      Staff login
-// @Description  Authenticate staff and receive JWT token
+// @Description  Authenticate staff and receive a short-lived access token and a refresh token. When MFA is enabled for the account, or required by the hospital, the response instead carries mfa_required and an mfa_token to finish at /staff/login/mfa. Repeated wrong passwords delay and then lock the account or client IP; such attempts get 429 with Retry-After.
 // @Tags         staff
 // @Accept       json
 // @Produce      json
@@ -65,6 +93,9 @@ type loginReq struct {
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
 // @Router       /:hospital/staff/login [post]
 func (staffhandler *StaffHandler) Login(c *gin.Context) {
 	hospital := c.Param("hospital")
@@ -74,11 +105,139 @@ func (staffhandler *StaffHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, staff, err := staffhandler.authService.Login(hospital, req.Username, req.Password)
+	result, err := staffhandler.authService.Login(hospital, req.Username, req.Password, c.ClientIP())
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		writeThrottled(c, throttled)
+		return
+	}
+	if errors.Is(err, services.ErrStaffPending) || errors.Is(err, services.ErrStaffDisabled) || errors.Is(err, services.ErrHospitalInactive) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repositories.ErrHospitalNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "staff_id": staff.ID, "username": staff.UserName, "hospital_id": staff.HospitalID})
+	if result.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":        true,
+			"mfa_token":           result.MFAToken,
+			"enrollment_required": result.EnrollmentRequired,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(result.Tokens, result.Staff))
+}
+
+func writeThrottled(c *gin.Context, err *services.LoginThrottledError) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+}
+
+func tokenResponse(tokens *services.TokenPair, staff *models.Staff) gin.H {
+	role := ""
+	if staff.Role != nil {
+		role = staff.Role.Name
+	}
+	return gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+		"staff_id":      staff.ID,
+		"username":      staff.UserName,
+		"hospital_id":   staff.HospitalID,
+		"role":          role,
+	}
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh godoc
+// @Summary      Refresh staff tokens
+// @Description  Exchange a refresh token for a new access token and refresh token. Each refresh token works once; reusing one revokes every token from the same login.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body refreshReq true "Refresh token"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /:hospital/staff/refresh [post]
+func (staffhandler *StaffHandler) Refresh(c *gin.Context) {
+	var req refreshReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, staff, err := staffhandler.authService.Refresh(c.Param("hospital"), req.RefreshToken)
+	switch {
+	case errors.Is(err, services.ErrInvalidRefreshToken), errors.Is(err, services.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrHospitalInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(tokens, staff))
+}
+
+type logoutReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout godoc
+// @Summary      Staff logout
+// @Description  Revoke the calling access token and, if given, the refresh token of the same login
+// @Tags         staff
+// @Accept       json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body logoutReq false "Refresh token to revoke"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Router       /:hospital/staff/logout [post]
+func (staffhandler *StaffHandler) Logout(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+
+	var req logoutReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	err := staffhandler.authService.Logout(claims.StaffID, claims.ID, expiresAt, req.RefreshToken)
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mfaLoginReq struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+type mfaChallengeReq struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type mfaCodeReq struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// LoginMFA godoc
+// @Summary      Finish an MFA login
+// @Description  Exchange the mfa_token from /staff/login and a TOTP or recovery code for tokens. For an enrollment challenge the code must come from the secret issued by /staff/login/mfa/enroll, and the response includes the recovery codes, shown only this once.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body mfaLoginReq true "Challenge and code"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Router       /:hospital/staff/login/mfa [post]
+func (staffhandler *StaffHandler) LoginMFA(c *gin.Context) {
+	var req mfaLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := staffhandler.authService.CompleteMFALogin(c.Param("hospital"), req.MFAToken, req.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	resp := tokenResponse(result.Tokens, result.Staff)
+	if len(result.RecoveryCodes) > 0 {
+		resp["recovery_codes"] = result.RecoveryCodes
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// LoginMFAEnroll godoc
+// @Summary      Enroll in MFA during login
+// @Description  For a login held back because the hospital requires MFA: issue a TOTP secret and its otpauth:// URI, to show as a QR code. Finish the login with a code from it at /staff/login/mfa.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body mfaChallengeReq true "Enrollment challenge"
+// @Success      200  {object}  services.MFAEnrollment
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /:hospital/staff/login/mfa/enroll [post]
+func (staffhandler *StaffHandler) LoginMFAEnroll(c *gin.Context) {
+	var req mfaChallengeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := staffhandler.authService.StartMFAEnrollmentWithChallenge(c.Param("hospital"), req.MFAToken)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// StartMFAEnrollment godoc
+// @Summary      Start MFA enrollment
+// @Description  Issue a TOTP secret and its otpauth:// URI for the calling staff member. MFA is enabled once a code is confirmed at /staff/mfa/verify.
+// @Tags         staff
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Security     BearerAuth
+// @Success      200  {object}  services.MFAEnrollment
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /:hospital/staff/mfa/enroll [post]
+func (staffhandler *StaffHandler) StartMFAEnrollment(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+
+	enrollment, err := staffhandler.authService.StartMFAEnrollment(claims.HospitalID, claims.StaffID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFAEnrollment godoc
+// @Summary      Confirm MFA enrollment
+// @Description  Enable MFA with a code from the secret issued by /staff/mfa/enroll. Returns the recovery codes, shown only this once.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body mfaCodeReq true "TOTP code"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /:hospital/staff/mfa/verify [post]
+func (staffhandler *StaffHandler) ConfirmMFAEnrollment(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := staffhandler.authService.ConfirmMFAEnrollment(claims.HospitalID, claims.StaffID, req.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mfa_enabled": true, "recovery_codes": codes})
+}
+
+// DisableMFA godoc
+// @Summary      Disable MFA
+// @Description  Turn off MFA for the calling staff member after checking a current TOTP or recovery code. Not allowed when the hospital requires MFA.
+// @Tags         staff
+// @Accept       json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body mfaCodeReq true "TOTP or recovery code"
+// @Security     BearerAuth
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /:hospital/staff/mfa [delete]
+func (staffhandler *StaffHandler) DisableMFA(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := staffhandler.authService.DisableMFA(claims.HospitalID, claims.StaffID, req.Code); err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeMFAError(c *gin.Context, err error) {
+	var throttled *services.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		writeThrottled(c, throttled)
+	case errors.Is(err, services.ErrMFAChallengeInvalid), errors.Is(err, services.ErrMFACodeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequired), errors.Is(err, services.ErrHospitalInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnrolling):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type changePasswordReq struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required" example:"correct-Horse-battery-9"`
+}
+
+type resetPasswordReq struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required" example:"correct-Horse-battery-9"`
+}
+
+// ChangePassword godoc
+// @Summary      Change own password
+// @Description  Replace the caller's password after checking the current one. All existing sessions end, including the calling one; the response carries a new token pair.
+// @Tags         staff
+// @Accept       json
+// @Produce      json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body changePasswordReq true "Current and new password"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Router       /:hospital/staff/me/password [put]
+func (staffhandler *StaffHandler) ChangePassword(c *gin.Context) {
+	claims := middleware.GetStaffClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+		return
+	}
+	var req changePasswordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, staff, err := staffhandler.authService.ChangePassword(claims.HospitalID, claims.StaffID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		writePasswordError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(tokens, staff))
+}
+
+// ResetPassword godoc
+// @Summary      Reset password with a token
+// @Description  Set a new password with a reset token issued by a hospital admin. The token works once; all existing sessions end.
+// @Tags         staff
+// @Accept       json
+// @Param        hospital path string true "Hospital name"
+// @Param        request body resetPasswordReq true "Reset token and new password"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /:hospital/staff/password/reset [post]
+func (staffhandler *StaffHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := staffhandler.authService.ResetPassword(c.Param("hospital"), req.Token, req.NewPassword); err != nil {
+		writePasswordError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writePasswordError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	var throttled *services.LoginThrottledError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &throttled):
+		writeThrottled(c, throttled)
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, repositories.ErrResetTokenInvalid):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
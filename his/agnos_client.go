@@ -0,0 +1,116 @@
+package his
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"agnos_candidate_assignment/models"
+)
+
+// AgnosClient talks to the reference HIS API:
+//
+//	GET {api_url}/patient/search/{id}   -> PatientRecord
+//	GET {api_url}/patient/search?...    -> {"patients": [PatientRecord]}
+//	GET {api_url}/patient/changes?updated_since=RFC3339
+//	                                    -> {"patients": [PatientRecord], "cursor": RFC3339}
+type AgnosClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewAgnosClient(baseURL string, httpClient *http.Client) HISClient {
+	return &AgnosClient{baseURL: baseURL, httpClient: httpClient}
+}
+
+func (client *AgnosClient) GetPatient(id string) (*models.Patient, error) {
+	var rec PatientRecord
+	if err := client.getJSON("/patient/search/"+url.PathEscape(id), &rec); err != nil {
+		return nil, err
+	}
+	return rec.Normalize()
+}
+
+func (client *AgnosClient) SearchPatients(filters map[string]interface{}) ([]models.Patient, error) {
+	q := url.Values{}
+	for k, v := range filters {
+		q.Set(k, fmt.Sprint(v))
+	}
+
+	var body struct {
+		Patients []PatientRecord `json:"patients"`
+	}
+	if err := client.getJSON("/patient/search?"+q.Encode(), &body); err != nil {
+		return nil, err
+	}
+
+	return normalizeAll(body.Patients), nil
+}
+
+func (client *AgnosClient) ListChangedPatients(since time.Time) (*ChangeSet, error) {
+	q := url.Values{}
+	if !since.IsZero() {
+		q.Set("updated_since", since.UTC().Format(time.RFC3339Nano))
+	}
+
+	var body struct {
+		Patients []PatientRecord `json:"patients"`
+		Cursor   string          `json:"cursor"`
+	}
+	if err := client.getJSON("/patient/changes?"+q.Encode(), &body); err != nil {
+		return nil, err
+	}
+
+	patients := normalizeAll(body.Patients)
+	cursor := since
+	if t, err := time.Parse(time.RFC3339Nano, body.Cursor); err == nil {
+		cursor = t
+	} else {
+		for _, rec := range body.Patients {
+			if t, err := time.Parse(time.RFC3339Nano, rec.UpdatedAt); err == nil && t.After(cursor) {
+				cursor = t
+			}
+		}
+	}
+
+	return &ChangeSet{
+		Patients: patients,
+		Skipped:  len(body.Patients) - len(patients),
+		Cursor:   cursor,
+	}, nil
+}
+
+func (client *AgnosClient) getJSON(path string, out interface{}) error {
+	resp, err := client.httpClient.Get(client.baseURL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("HIS responded with status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// normalizeAll drops records that cannot be normalized instead of failing
+// the whole batch.
+func normalizeAll(records []PatientRecord) []models.Patient {
+	patients := make([]models.Patient, 0, len(records))
+	for _, rec := range records {
+		p, err := rec.Normalize()
+		if err != nil {
+			log.Printf("skip HIS record %q: %v", rec.PatientHN, err)
+			continue
+		}
+		patients = append(patients, *p)
+	}
+	return patients
+}
@@ -0,0 +1,118 @@
+package his
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/models"
+)
+
+var (
+	ErrNotFound       = errors.New("patient not found in HIS")
+	ErrNotConfigured  = errors.New("hospital has no HIS configured")
+	ErrUnknownAdapter = errors.New("unknown HIS adapter")
+)
+
+// HISClient is implemented by every hospital information system adapter.
+// Adapters return patients already normalized into models.Patient; the
+// caller is responsible for setting HospitalID and persisting them.
+type HISClient interface {
+	GetPatient(id string) (*models.Patient, error)
+	SearchPatients(filters map[string]interface{}) ([]models.Patient, error)
+	ListChangedPatients(since time.Time) (*ChangeSet, error)
+}
+
+// ChangeSet is a batch of patients changed since a cursor. Cursor is the
+// value to pass as `since` on the next call.
+type ChangeSet struct {
+	Patients []models.Patient
+	Skipped  int
+	Cursor   time.Time
+}
+
+// PatientRecord is the wire format of a patient as served by an HIS.
+type PatientRecord struct {
+	FirstNameTH  string `json:"first_name_th"`
+	MiddleNameTH string `json:"middle_name_th"`
+	LastNameTH   string `json:"last_name_th"`
+	FirstNameEN  string `json:"first_name_en"`
+	MiddleNameEN string `json:"middle_name_en"`
+	LastNameEN   string `json:"last_name_en"`
+	DateOfBirth  string `json:"date_of_birth"`
+	PatientHN    string `json:"patient_hn"`
+	NationalID   string `json:"national_id"`
+	PassportID   string `json:"passport_id"`
+	PhoneNumber  string `json:"phone_number"`
+	Email        string `json:"email"`
+	Gender       string `json:"gender"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+// Normalize converts an HIS record into a models.Patient, rejecting records
+// that miss the fields our schema requires.
+func (rec PatientRecord) Normalize() (*models.Patient, error) {
+	hn := strings.TrimSpace(rec.PatientHN)
+	if hn == "" {
+		return nil, errors.New("HIS record missing patient_hn")
+	}
+
+	dob, err := parseDate(rec.DateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+
+	gender, err := parseGender(rec.Gender)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Patient{
+		FirstNameTH:  optional(rec.FirstNameTH),
+		MiddleNameTH: optional(rec.MiddleNameTH),
+		LastNameTH:   optional(rec.LastNameTH),
+		FirstNameEN:  optional(rec.FirstNameEN),
+		MiddleNameEN: optional(rec.MiddleNameEN),
+		LastNameEN:   optional(rec.LastNameEN),
+		DateOfBirth:  dob,
+		PatientHN:    hn,
+		NationalID:   optional(rec.NationalID),
+		PassportID:   optional(rec.PassportID),
+		PhoneNumber:  optional(rec.PhoneNumber),
+		Email:        optional(rec.Email),
+		Gender:       gender,
+	}, nil
+}
+
+func optional(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errors.New("HIS record missing date_of_birth")
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("HIS record has invalid date_of_birth: " + s)
+	}
+	return t.UTC().Truncate(24 * time.Hour), nil
+}
+
+func parseGender(s string) (models.Gender, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "M", "MALE":
+		return models.Male, nil
+	case "F", "FEMALE":
+		return models.Female, nil
+	}
+	return "", errors.New("HIS record has invalid gender: " + s)
+}
@@ -0,0 +1,160 @@
+// Package histest provides an in-memory stand-in HIS that speaks the same
+// HTTP API as the reference adapter, for tests and local development.
+package histest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"agnos_candidate_assignment/his"
+)
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	patients []his.PatientRecord
+	requests int
+}
+
+// NewServer starts a stand-in HIS serving the given patients. Close it when
+// done, like any httptest.Server.
+func NewServer(patients ...his.PatientRecord) *Server {
+	s := &Server{patients: patients}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/patient/search/", s.handleGet)
+	mux.HandleFunc("/patient/search", s.handleSearch)
+	mux.HandleFunc("/patient/changes", s.handleChanges)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Add makes another patient visible to subsequent requests.
+func (s *Server) Add(rec his.PatientRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patients = append(s.patients, rec)
+}
+
+// Requests reports how many API calls the server has answered.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/patient/search/")
+
+	s.mu.Lock()
+	s.requests++
+	defer s.mu.Unlock()
+
+	for _, p := range s.patients {
+		if id != "" && (p.NationalID == id || p.PassportID == id) {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "patient not found"})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	s.requests++
+	defer s.mu.Unlock()
+
+	results := []his.PatientRecord{}
+	for _, p := range s.patients {
+		if matches(p, q) {
+			results = append(results, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"patients": results})
+}
+
+// handleChanges returns records whose UpdatedAt is strictly after
+// updated_since. Records without UpdatedAt are always returned.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("updated_since"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid updated_since"})
+			return
+		}
+		since = t
+	}
+
+	s.mu.Lock()
+	s.requests++
+	defer s.mu.Unlock()
+
+	results := []his.PatientRecord{}
+	cursor := since
+	for _, p := range s.patients {
+		updated, err := time.Parse(time.RFC3339Nano, p.UpdatedAt)
+		if err == nil && !updated.After(since) {
+			continue
+		}
+		if err == nil && updated.After(cursor) {
+			cursor = updated
+		}
+		results = append(results, p)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"patients": results,
+		"cursor":   cursor.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func matches(p his.PatientRecord, q map[string][]string) bool {
+	fields := map[string][]string{
+		"patient_hn":     {p.PatientHN},
+		"national_id":    {p.NationalID},
+		"passport_id":    {p.PassportID},
+		"first_name":     {p.FirstNameTH, p.FirstNameEN},
+		"middle_name":    {p.MiddleNameTH, p.MiddleNameEN},
+		"last_name":      {p.LastNameTH, p.LastNameEN},
+		"first_name_th":  {p.FirstNameTH},
+		"middle_name_th": {p.MiddleNameTH},
+		"last_name_th":   {p.LastNameTH},
+		"first_name_en":  {p.FirstNameEN},
+		"middle_name_en": {p.MiddleNameEN},
+		"last_name_en":   {p.LastNameEN},
+		"date_of_birth":  {p.DateOfBirth},
+		"phone_number":   {p.PhoneNumber},
+		"email":          {p.Email},
+		"gender":         {p.Gender},
+	}
+
+	for key, values := range q {
+		candidates, ok := fields[key]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		found := false
+		for _, c := range candidates {
+			if c != "" && c == values[0] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,61 @@
+package his
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"agnos_candidate_assignment/models"
+)
+
+// DefaultAdapter is used for hospitals that have an api_url but no explicit
+// adapter type.
+const DefaultAdapter = "agnos"
+
+// Factory builds an HISClient for a hospital's base URL.
+type Factory func(baseURL string, httpClient *http.Client) HISClient
+
+// Registry maps adapter types (models.Hospital.HISAdapter) to factories.
+type Registry struct {
+	mu         sync.RWMutex
+	factories  map[string]Factory
+	httpClient *http.Client
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	r := &Registry{
+		factories:  map[string]Factory{},
+		httpClient: &http.Client{Timeout: timeout},
+	}
+	r.Register(DefaultAdapter, NewAgnosClient)
+	return r
+}
+
+func (r *Registry) Register(adapter string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[strings.ToLower(adapter)] = factory
+}
+
+// ClientFor returns the HIS client configured for the hospital, or
+// ErrNotConfigured if the hospital has no api_url.
+func (r *Registry) ClientFor(h *models.Hospital) (HISClient, error) {
+	if h == nil || h.APIURL == nil || strings.TrimSpace(*h.APIURL) == "" {
+		return nil, ErrNotConfigured
+	}
+
+	adapter := strings.ToLower(strings.TrimSpace(h.HISAdapter))
+	if adapter == "" {
+		adapter = DefaultAdapter
+	}
+
+	r.mu.RLock()
+	factory, ok := r.factories[adapter]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownAdapter
+	}
+
+	return factory(strings.TrimRight(*h.APIURL, "/"), r.httpClient), nil
+}
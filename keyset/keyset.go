@@ -0,0 +1,223 @@
+// Package keyset holds the asymmetric keys that sign and verify API tokens.
+//
+// Exactly one key signs at a time. Keys it replaced stay in the set, and in
+// the published JWKS, until every token they signed has expired, so rotation
+// never invalidates a live session. Each key is named by its kid, which
+// tokens carry in their header.
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Supported lists every signing algorithm a key can use.
+var Supported = []string{RS256, EdDSA}
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing key. Activated is when it started signing; a key that
+// has been superseded keeps verifying for the set's retention after its
+// successor's activation.
+type Key struct {
+	ID        string
+	Alg       string
+	Private   crypto.Signer
+	Activated time.Time
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Alg == RS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// NewKey wraps a private key, deriving the algorithm from its type.
+func NewKey(id string, private crypto.Signer, activated time.Time) (*Key, error) {
+	switch p := private.(type) {
+	case *rsa.PrivateKey:
+		if p.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("key %s: RSA keys must be at least 2048 bits", id)
+		}
+		return &Key{ID: id, Alg: RS256, Private: private, Activated: activated}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Alg: EdDSA, Private: private, Activated: activated}, nil
+	}
+	return nil, fmt.Errorf("key %s: unsupported key type %T", id, private)
+}
+
+// Set is a concurrency-safe keyset. The most recently activated key signs;
+// a key with a future activation is published for verification but does not
+// sign yet.
+type Set struct {
+	mu        sync.RWMutex
+	keys      []*Key
+	retention time.Duration
+}
+
+// NewSet returns an empty set that keeps superseded keys for retention, which
+// must cover the longest token lifetime.
+func NewSet(retention time.Duration) *Set {
+	return &Set{retention: retention}
+}
+
+// Replace swaps in keys, dropping any superseded for longer than the
+// retention as of now, and returns the IDs it dropped.
+func (s *Set) Replace(keys []*Key, now time.Time) []string {
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Activated.Before(sorted[j].Activated) })
+
+	var kept []*Key
+	var dropped []string
+	for i, k := range sorted {
+		if i+1 < len(sorted) && !sorted[i+1].Activated.Add(s.retention).After(now) {
+			dropped = append(dropped, k.ID)
+			continue
+		}
+		kept = append(kept, k)
+	}
+
+	s.mu.Lock()
+	s.keys = kept
+	s.mu.Unlock()
+	return dropped
+}
+
+// Keys returns the keys in activation order.
+func (s *Set) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Key(nil), s.keys...)
+}
+
+// Active returns the signing key, or nil for an empty set.
+func (s *Set) Active() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.keys) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := len(s.keys) - 1; i > 0; i-- {
+		if !s.keys[i].Activated.After(now) {
+			return s.keys[i]
+		}
+	}
+	return s.keys[0]
+}
+
+// Algorithms returns the distinct algorithms of the keys in the set, which
+// is what verification is pinned to: the configured one, plus the previous
+// one while keys signed with it are retained after a change of JWT_ALG.
+func (s *Set) Algorithms() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var algs []string
+	for _, k := range s.keys {
+		if !contains(algs, k.Alg) {
+			algs = append(algs, k.Alg)
+		}
+	}
+	return algs
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Set) lookup(kid string) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	k := s.Active()
+	if k == nil {
+		return "", errors.New("keyset is empty")
+	}
+	token := jwt.NewWithClaims(k.method(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Private)
+}
+
+// Keyfunc resolves a token's verification key from its kid. The token's alg
+// must be the key's own, so a key can never be used with another algorithm.
+func (s *Set) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k := s.lookup(kid)
+	if k == nil {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.Alg {
+		return nil, fmt.Errorf("key %s does not sign %s", kid, token.Method.Alg())
+	}
+	return k.Private.Public(), nil
+}
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every key that can still verify.
+func (s *Set) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.Keys() {
+		jwk := JWK{Kid: k.ID, Alg: k.Alg, Use: "sig"}
+		switch pub := k.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
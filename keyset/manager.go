@@ -0,0 +1,245 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manager keeps a Set in step with a directory of PEM private keys, one per
+// file named <kid>.pem, whose modification time is its activation time. It
+// generates a key when there is none or the active one is older than the
+// rotation interval, and deletes files of keys that can no longer verify.
+//
+// A rotated key is activated lead after it is written, so instances sharing
+// the directory, which pick up each other's keys on their next tick, and
+// JWKS consumers know it before it signs anything. With no directory the
+// keys live only in memory and every restart invalidates issued tokens.
+type Manager struct {
+	Set         *Set
+	dir         string
+	alg         string
+	rotateEvery time.Duration
+	lead        time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewManager(set *Set, dir, alg string, rotateEvery, lead time.Duration) (*Manager, error) {
+	if alg != RS256 && alg != EdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q (want %s or %s)", alg, RS256, EdDSA)
+	}
+	return &Manager{Set: set, dir: dir, alg: alg, rotateEvery: rotateEvery, lead: lead}, nil
+}
+
+// Tick reloads the keys, rotates if due and prunes expired keys.
+func (m *Manager) Tick(now time.Time) error {
+	keys := m.Set.Keys()
+	if m.dir != "" {
+		var err error
+		if keys, err = readDir(m.dir); err != nil {
+			return err
+		}
+	}
+
+	if len(keys) == 0 || m.rotationDue(keys, now) {
+		activated := now
+		if len(keys) > 0 {
+			activated = now.Add(m.lead)
+		}
+		k, err := m.generate(activated)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	for _, kid := range m.Set.Replace(keys, now) {
+		if m.dir == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, kid+".pem")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) rotationDue(keys []*Key, now time.Time) bool {
+	if m.rotateEvery <= 0 {
+		return false
+	}
+	newest := keys[0].Activated
+	for _, k := range keys[1:] {
+		if k.Activated.After(newest) {
+			newest = k.Activated
+		}
+	}
+	return now.Sub(newest) >= m.rotateEvery
+}
+
+func (m *Manager) generate(activated time.Time) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	if m.alg == RS256 {
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kid := activated.UTC().Format("20060102T150405Z")
+	if m.dir != "" {
+		if err := writeKey(m.dir, kid, private, activated); err != nil {
+			return nil, err
+		}
+	}
+	return NewKey(kid, private, activated)
+}
+
+// Start calls Tick every interval until Stop. A non-positive interval
+// disables it.
+func (m *Manager) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := m.Tick(now); err != nil {
+					log.Printf("signing key rotation: %v", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// Load reads the keys in dir into a set without generating, rotating or
+// deleting any, for tools that sign with the keys an API instance manages.
+// The set holds only the keys that can still sign, so it is not meant for
+// verification.
+func Load(dir string, now time.Time) (*Set, error) {
+	keys, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys in %s", dir)
+	}
+	set := NewSet(0)
+	set.Replace(keys, now)
+	return set, nil
+}
+
+func readDir(dir string) ([]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pem" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		private, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		k, err := NewKey(strings.TrimSuffix(e.Name(), ".pem"), private, info.ModTime())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// writeKey writes the key through a temporary file so other instances never
+// read a partial one.
+func writeKey(dir, kid string, private crypto.Signer, activated time.Time) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := pem.Encode(tmp, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), activated, activated); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, kid+".pem"))
+}
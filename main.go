@@ -3,14 +3,21 @@ package main
 import (
 	"agnos_candidate_assignment/config"
 	"agnos_candidate_assignment/database"
+	"agnos_candidate_assignment/fieldcrypt"
 	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/keyset"
 	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
 	"agnos_candidate_assignment/repositories"
 	"agnos_candidate_assignment/services"
+	"agnos_candidate_assignment/throttle"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	_ "agnos_candidate_assignment/docs" // swagger docs
 
@@ -18,6 +25,7 @@ import (
 	"github.com/joho/godotenv"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // @title           Agnos Hospital API
@@ -49,6 +57,33 @@ func main() {
 		gin.DefaultErrorWriter = io.Discard
 	}
 
+	retention := conf.AccessTokenTTL
+	if services.SystemAdminTokenTTL > retention {
+		retention = services.SystemAdminTokenTTL
+	}
+	keys := keyset.NewSet(retention + time.Minute)
+	if conf.JWTKeysDir != "" {
+		if err := os.MkdirAll(conf.JWTKeysDir, 0o700); err != nil {
+			log.Fatalf("Failed to create key directory: %v", err)
+		}
+	} else {
+		log.Printf("JWT_KEYS_DIR not set; signing with an in-memory key, tokens will not survive a restart")
+	}
+	keyManager, err := keyset.NewManager(keys, conf.JWTKeysDir, conf.JWTAlgorithm, conf.JWTKeyRotation, 2*time.Minute)
+	if err != nil {
+		log.Fatalf("Invalid signing key config: %v", err)
+	}
+	if err := keyManager.Tick(time.Now()); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	keyManager.Start(time.Minute)
+	defer keyManager.Stop()
+
+	fieldKeys, err := fieldcrypt.Load(conf.FieldKeys, conf.FieldKeysFile)
+	if err != nil {
+		log.Fatalf("Failed to load field encryption keys (FIELD_KEYS or FIELD_KEYS_FILE): %v", err)
+	}
+
 	db, err := database.NewPostgresConnection(conf)
 
 	if err != nil {
@@ -57,23 +92,90 @@ func main() {
 
 	hospitalRepo := repositories.NewHospitalRepository(db)
 	staffRepo := repositories.NewStaffRepository(db)
-	patientRepo := repositories.NewPatientRepository(db)
+	patientRepo := repositories.NewPatientRepository(db, fieldKeys)
+	hisSyncRepo := repositories.NewHISSyncRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	systemAdminRepo := repositories.NewSystemAdminRepository(db)
+	tokenRepo := repositories.NewTokenRepository(db)
+	mfaRepo := repositories.NewMFARepository(db, fieldKeys)
+	passwordRepo := repositories.NewPasswordRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	fieldPolicyRepo := repositories.NewFieldPolicyRepository(db)
+
+	if err := roleRepo.EnsureDefaults(models.DefaultRoles); err != nil {
+		log.Fatalf("Failed to create default roles: %v", err)
+	}
+
+	if n, err := tokenRepo.PurgeExpired(time.Now()); err != nil {
+		log.Printf("expired token purge failed: %v", err)
+	} else if n > 0 {
+		log.Printf("purged %d expired tokens", n)
+	}
 
-	authService := services.NewAuthService(staffRepo, hospitalRepo, conf)
-	patientService := services.NewPatientService(patientRepo)
+	if n, err := patientRepo.EncryptLegacy(500); err != nil {
+		log.Fatalf("Failed to encrypt legacy patient rows: %v", err)
+	} else if n > 0 {
+		log.Printf("encrypted and indexed %d legacy patient rows", n)
+	}
+	if n, err := mfaRepo.EncryptLegacy(); err != nil {
+		log.Fatalf("Failed to encrypt legacy TOTP secrets: %v", err)
+	} else if n > 0 {
+		log.Printf("encrypted %d legacy TOTP secrets", n)
+	}
+
+	if conf.BcryptCost < bcrypt.MinCost || conf.BcryptCost > bcrypt.MaxCost {
+		log.Fatalf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	passwordPolicy := &password.Policy{MinLength: conf.PasswordMinLength, MinClasses: conf.PasswordMinClasses}
+	if conf.PasswordBreachedFile != "" {
+		n, err := passwordPolicy.LoadBreached(conf.PasswordBreachedFile)
+		if err != nil {
+			log.Fatalf("Failed to load breached password list: %v", err)
+		}
+		log.Printf("loaded %d breached passwords", n)
+	}
+
+	hisRegistry := his.NewRegistry(conf.HISTimeout)
+
+	authService := services.NewAuthService(staffRepo, hospitalRepo, invitationRepo, tokenRepo, mfaRepo, passwordRepo, passwordPolicy, keys, conf)
+	roleService := services.NewRoleService(staffRepo, roleRepo)
+	staffService := services.NewStaffService(staffRepo, roleRepo, invitationRepo, passwordRepo)
+	systemAdminService := services.NewSystemAdminService(systemAdminRepo, tokenRepo, keys, conf)
+	patientService := services.NewPatientService(patientRepo, hospitalRepo, hisRegistry)
+	hisSyncService := services.NewHISSyncService(hospitalRepo, patientRepo, hisSyncRepo, hisRegistry)
+	auditService := services.NewAuditService(auditRepo)
+	fieldPolicyService := services.NewFieldPolicyService(fieldPolicyRepo, roleRepo)
 
 	hospitalHandler := handlers.NewHospitalHandler(hospitalRepo)
 	staffHandler := handlers.NewStaffHandler(authService)
-	patientHandler := handlers.NewPatientHandler(patientService)
+	patientHandler := handlers.NewPatientHandler(patientService, fieldPolicyService)
+	hisSyncHandler := handlers.NewHISSyncHandler(hisSyncService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	staffAdminHandler := handlers.NewStaffAdminHandler(staffService)
+	adminHandler := handlers.NewAdminHandler(systemAdminService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	fieldPolicyHandler := handlers.NewFieldPolicyHandler(fieldPolicyService)
+
+	if created, err := systemAdminService.EnsureBootstrap(); err != nil {
+		log.Fatalf("Failed to create system admin: %v", err)
+	} else if created {
+		log.Printf("created system admin %q", conf.SystemAdminUsername)
+	}
 
 	gin.SetMode(conf.GinMode)
 
 	router := gin.New()
-	router.Use(gin.RecoveryWithWriter(io.Discard))
+	router.Use(gin.RecoveryWithWriter(io.Discard), middleware.RequestID())
+	if err := router.SetTrustedProxies(conf.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Swagger route
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	router.GET("/.well-known/jwks.json", handlers.NewJWKSHandler(keys).Get)
+
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
@@ -82,30 +184,83 @@ func main() {
 	api.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
-	api.POST("/hospital", hospitalHandler.Create)
 
-	authMiddleWare := middleware.JWTAuth(conf, staffRepo)
+	adminAuth := middleware.SystemAdminAuth(conf, keys, systemAdminRepo, tokenRepo)
+
+	admin := api.Group("/admin")
+	{
+		admin.POST("/login", adminHandler.Login)
+		admin.POST("/logout", adminAuth, adminHandler.Logout)
+		admin.GET("/hospitals", adminAuth, hospitalHandler.List)
+		admin.POST("/hospitals", adminAuth, hospitalHandler.Create)
+		admin.PATCH("/hospitals/:hospital_id", adminAuth, hospitalHandler.Rename)
+		admin.POST("/hospitals/:hospital_id/deactivate", adminAuth, hospitalHandler.Deactivate)
+		admin.POST("/hospitals/:hospital_id/activate", adminAuth, hospitalHandler.Activate)
+		admin.PATCH("/hospitals/:hospital_id/security", adminAuth, hospitalHandler.UpdateSecurity)
+		admin.DELETE("/hospitals/:hospital_id", adminAuth, hospitalHandler.Delete)
+		admin.POST("/hospitals/:hospital_id/invitations", adminAuth, staffAdminHandler.InviteAdmin)
+	}
+
+	authMiddleWare := middleware.JWTAuth(conf, keys, staffRepo, tokenRepo)
 
 	hospitalGroup := api.Group(":hospital")
+	hospitalGroup.Use(middleware.RequireActiveHospital(hospitalRepo))
 	{
 		hospitalGroup.POST("/staff/create", staffHandler.Register)
 		hospitalGroup.POST("/staff/login", staffHandler.Login)
+		hospitalGroup.POST("/staff/login/mfa", staffHandler.LoginMFA)
+		hospitalGroup.POST("/staff/login/mfa/enroll", staffHandler.LoginMFAEnroll)
+		hospitalGroup.POST("/staff/refresh", staffHandler.Refresh)
+		hospitalGroup.POST("/staff/password/reset", staffHandler.ResetPassword)
+		hospitalGroup.POST("/staff/logout", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffHandler.Logout)
+		hospitalGroup.POST("/staff/mfa/enroll", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffHandler.StartMFAEnrollment)
+		hospitalGroup.POST("/staff/mfa/verify", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffHandler.ConfirmMFAEnrollment)
+		hospitalGroup.DELETE("/staff/mfa", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffHandler.DisableMFA)
+		hospitalGroup.PUT("/staff/me/password", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffHandler.ChangePassword)
+		hospitalGroup.GET("/staff/me", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), staffAdminHandler.Me)
 
-		hospitalGroup.GET("/patient/search/:id", func(c *gin.Context) {
-			name := c.Param("hospital")
-			hosp, err := hospitalRepo.FindByName(name)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "hospital not found"})
-				return
+		hospitalGroup.GET("/patient/search/:id", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermPatientRead), middleware.AuditAccess(auditService, models.AuditPatientLookup), patientHandler.GetByID)
+		if conf.PublicPatientVerify {
+			if conf.PublicVerifyPerMinute < 1 {
+				log.Fatalf("PUBLIC_VERIFY_PER_MINUTE must be at least 1")
 			}
-			c.Set("hospital_id", hosp.ID)
-			patientHandler.GetByID(c)
-		})
+			verifyLimiter := throttle.NewLimiter(conf.PublicVerifyPerMinute, time.Minute)
+			hospitalGroup.GET("/patient/verify/:id", middleware.RateLimit(verifyLimiter), middleware.ResolveHospital(hospitalRepo), middleware.AuditAccess(auditService, models.AuditPatientVerify), patientHandler.Verify)
+		}
+
+		hospitalGroup.POST("/his/sync", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermHISSync), hisSyncHandler.Trigger)
+		hospitalGroup.GET("/his/sync", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermHISSync), hisSyncHandler.Status)
+		hospitalGroup.PUT("/staff/:staff_id/role", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), roleHandler.Assign)
+		hospitalGroup.POST("/staff/invitations", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Invite)
+		hospitalGroup.POST("/staff/:staff_id/approve", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Approve)
+		hospitalGroup.GET("/staff/:staff_id/lockout", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Lockout)
+		hospitalGroup.DELETE("/staff/:staff_id/lockout", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Unlock)
+		hospitalGroup.POST("/staff/:staff_id/password-reset", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.IssuePasswordReset)
+		hospitalGroup.GET("/staff", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.List)
+		hospitalGroup.GET("/staff/:staff_id", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Get)
+		hospitalGroup.PATCH("/staff/:staff_id", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.UpdateProfile)
+		hospitalGroup.POST("/staff/:staff_id/disable", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Disable)
+		hospitalGroup.POST("/staff/:staff_id/enable", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Enable)
+		hospitalGroup.DELETE("/staff/:staff_id", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), staffAdminHandler.Delete)
+		hospitalGroup.GET("/patient-policy", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), fieldPolicyHandler.Get)
+		hospitalGroup.PUT("/patient-policy", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermStaffManage), fieldPolicyHandler.Replace)
+		hospitalGroup.GET("/audit", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermAuditRead), auditHandler.List)
+		hospitalGroup.GET("/audit/verify", authMiddleWare, middleware.RequireHospitalMatch(hospitalRepo), middleware.RequirePermission(models.PermAuditRead), auditHandler.Verify)
 	}
 
-	api.GET("/patient/search", authMiddleWare, func(c *gin.Context) {
+	api.GET("/roles", authMiddleWare, middleware.RequirePermission(models.PermStaffManage), roleHandler.List)
+
+	api.GET("/patient/search", authMiddleWare, middleware.RequirePermission(models.PermPatientRead), middleware.AuditAccess(auditService, models.AuditPatientSearch), func(c *gin.Context) {
 		patientHandler.Search(c)
 	})
+	api.POST("/patient/search", authMiddleWare, middleware.RequirePermission(models.PermPatientRead), middleware.AuditAccess(auditService, models.AuditPatientSearch), patientHandler.SearchAdvanced)
+	api.POST("/patient", authMiddleWare, middleware.RequirePermission(models.PermPatientWrite), middleware.AuditAccess(auditService, models.AuditPatientCreate), patientHandler.Create)
+	api.GET("/patient/:patient_id", authMiddleWare, middleware.RequirePermission(models.PermPatientRead), middleware.AuditAccess(auditService, models.AuditPatientRead), patientHandler.Get)
+	api.PATCH("/patient/:patient_id", authMiddleWare, middleware.RequirePermission(models.PermPatientWrite), middleware.AuditAccess(auditService, models.AuditPatientUpdate), patientHandler.Update)
+	api.DELETE("/patient/:patient_id", authMiddleWare, middleware.RequirePermission(models.PermPatientDelete), middleware.AuditAccess(auditService, models.AuditPatientDelete), patientHandler.Delete)
+
+	hisSyncService.Start(conf.HISSyncInterval)
+	defer hisSyncService.Stop()
 
 	log.Printf("Starting server on port %s", conf.ServerPort)
 
@@ -0,0 +1,114 @@
+// Package masking hides most of a personal identifier while leaving enough
+// for its owner to recognise it, e.g. "S******" for a name or "******5678"
+// for a phone number.
+package masking
+
+import "strings"
+
+const maskRune = '*'
+
+// Name keeps the first character of each word.
+func Name(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = keepFirst(w, 1)
+	}
+	return strings.Join(words, " ")
+}
+
+// Phone keeps the last four digits; other digits are masked and separators
+// dropped.
+func Phone(s string) string {
+	return keepLastDigits(s, 4)
+}
+
+// ID keeps the last four characters of a national ID or passport number.
+func ID(s string) string {
+	s = strings.ReplaceAll(s, "-", "")
+	r := []rune(s)
+	for i := 0; i < len(r)-4; i++ {
+		r[i] = maskRune
+	}
+	return string(r)
+}
+
+// NationalID formats a 13-digit Thai national ID in its usual groups,
+// keeping the first five digits and the check digit:
+// "1234567890123" becomes "1-2345-XXXXX-XX-3". Anything else is masked with
+// ID.
+func NationalID(s string) string {
+	d := []rune(strings.ReplaceAll(s, "-", ""))
+	if len(d) != 13 || !allDigits(d) {
+		return ID(s)
+	}
+	return string(d[0]) + "-" + string(d[1:5]) + "-XXXXX-XX-" + string(d[12])
+}
+
+// GroupedPhone formats a 10-digit Thai mobile number as 3-3-4 digits,
+// keeping the first two and last four: "0812340001" becomes
+// "08X-XXX-0001". Other numbers are masked with Phone.
+func GroupedPhone(s string) string {
+	var d []rune
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			d = append(d, r)
+		}
+	}
+	if len(d) != 10 {
+		return Phone(s)
+	}
+	return string(d[:2]) + "X-XXX-" + string(d[6:])
+}
+
+// BirthDate keeps the year of an ISO date or timestamp:
+// "1985-04-12T00:00:00Z" becomes "1985-XX-XX".
+func BirthDate(s string) string {
+	if len(s) < 4 {
+		return strings.Repeat(string(maskRune), len(s))
+	}
+	return s[:4] + "-XX-XX"
+}
+
+// Email keeps the first character of the mailbox and the domain.
+func Email(s string) string {
+	at := strings.LastIndex(s, "@")
+	if at < 0 {
+		return keepFirst(s, 1)
+	}
+	return keepFirst(s[:at], 1) + s[at:]
+}
+
+// keepFirst masks all but the first n characters. Words of up to n
+// characters are masked entirely, since showing them would reveal them.
+func keepFirst(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return strings.Repeat(string(maskRune), len(r))
+	}
+	for i := n; i < len(r); i++ {
+		r[i] = maskRune
+	}
+	return string(r)
+}
+
+func allDigits(r []rune) bool {
+	for _, c := range r {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func keepLastDigits(s string, n int) string {
+	var digits []rune
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	for i := 0; i < len(digits)-n; i++ {
+		digits[i] = maskRune
+	}
+	return string(digits)
+}
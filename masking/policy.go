@@ -0,0 +1,106 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Action is what a field policy does with one field of a response.
+type Action string
+
+const (
+	Full Action = "full"
+	Mask Action = "mask"
+	Omit Action = "omit"
+)
+
+func (a Action) Valid() bool {
+	return a == Full || a == Mask || a == Omit
+}
+
+// patientFields maps the patient response fields a policy may cover, by
+// JSON name, to how each is masked.
+var patientFields = map[string]func(string) string{
+	"first_name_th":  Name,
+	"middle_name_th": Name,
+	"last_name_th":   Name,
+	"first_name_en":  Name,
+	"middle_name_en": Name,
+	"last_name_en":   Name,
+	"national_id":    NationalID,
+	"passport_id":    ID,
+	"phone_number":   GroupedPhone,
+	"email":          Email,
+	"date_of_birth":  BirthDate,
+}
+
+// PatientFields lists the fields a policy may cover, sorted.
+func PatientFields() []string {
+	fields := make([]string, 0, len(patientFields))
+	for f := range patientFields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// IsPatientField reports whether a policy may cover field.
+func IsPatientField(field string) bool {
+	_, ok := patientFields[field]
+	return ok
+}
+
+// Policy says what to do with each patient field; fields it does not list
+// are returned in full.
+type Policy map[string]Action
+
+// Full reports whether the policy leaves every field as it is.
+func (p Policy) Full() bool {
+	for _, a := range p {
+		if a != Full {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply masks or removes the fields of a patient, given as its decoded JSON
+// object, in place. A masked field that is not a string is removed rather
+// than shown; absent and null fields are left alone.
+func (p Policy) Apply(patient map[string]interface{}) {
+	for field, action := range p {
+		v, ok := patient[field]
+		if !ok {
+			continue
+		}
+		switch action {
+		case Omit:
+			delete(patient, field)
+		case Mask:
+			mask := patientFields[field]
+			if s, isString := v.(string); isString && mask != nil {
+				patient[field] = mask(s)
+			} else if v != nil {
+				delete(patient, field)
+			}
+		}
+	}
+}
+
+// Shape encodes a patient as its JSON object and applies the policy to it.
+// Numbers are kept as json.Number so IDs survive the round trip exactly.
+func (p Policy) Shape(patient interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(patient)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	p.Apply(doc)
+	return doc, nil
+}
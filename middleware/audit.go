@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"agnos_candidate_assignment/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditPatientsKey = "audit_patient_ids"
+	auditFiltersKey  = "audit_filters"
+)
+
+// maxUserAgentLength matches the audit_logs.user_agent column.
+const maxUserAgentLength = 512
+
+// AuditRecorder stores audit entries.
+type AuditRecorder interface {
+	Record(entry *models.AuditLog) error
+}
+
+// AuditAccess records every request to a patient route as an audit entry:
+// who made it, from where, the patients it returned or touched and the
+// filters it used. The response is held back until the entry is stored; if
+// that fails the client gets a 500 instead, so no patient data leaves
+// without a trace. It runs after JWTAuth where the route is authenticated.
+func AuditAccess(recorder AuditRecorder, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		entry := &models.AuditLog{
+			Action:    action,
+			Status:    buffered.status,
+			IP:        c.ClientIP(),
+			UserAgent: truncate(c.Request.UserAgent(), maxUserAgentLength),
+			RequestID: GetRequestID(c),
+		}
+		if claims := GetStaffClaims(c); claims != nil {
+			entry.HospitalID = claims.HospitalID
+			entry.StaffID = &claims.StaffID
+		} else if id, ok := c.Get("hospital_id"); ok {
+			entry.HospitalID, _ = id.(uint)
+		}
+		if ids, ok := c.Get(auditPatientsKey); ok {
+			entry.PatientIDs, _ = ids.(models.IDList)
+		}
+		entry.Filters = auditFilters(c)
+
+		// Without a hospital there is no chain to append to; such requests
+		// failed before reaching any patient data.
+		if entry.HospitalID != 0 {
+			if err := recorder.Record(entry); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to record audit log"})
+				return
+			}
+		}
+		buffered.flush()
+	}
+}
+
+// SetAuditPatients notes the patients a request returned or changed, for
+// AuditAccess to record.
+func SetAuditPatients(c *gin.Context, ids ...uint) {
+	c.Set(auditPatientsKey, models.IDList(ids))
+}
+
+// SetAuditFilters replaces the filters AuditAccess records, which default
+// to the query string and path parameters. Only the names of the fields set
+// in filters are recorded.
+func SetAuditFilters(c *gin.Context, filters interface{}) {
+	c.Set(auditFiltersKey, filters)
+}
+
+// auditFilters lists the names of the filters a request used as a sorted
+// JSON array. Their values are left out: they include national IDs, names
+// and contact details, which the append-only log could neither mask for
+// auditors nor purge.
+func auditFilters(c *gin.Context) models.RawJSON {
+	names := map[string]bool{}
+	if filters, ok := c.Get(auditFiltersKey); ok {
+		b, err := json.Marshal(filters)
+		if err != nil {
+			return ""
+		}
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return ""
+		}
+		addFilterNames(names, "", v)
+	} else {
+		for key := range c.Request.URL.Query() {
+			names[key] = true
+		}
+		for _, p := range c.Params {
+			if p.Key != "hospital" {
+				names[p.Key] = true
+			}
+		}
+	}
+	var list []string
+	for name, set := range names {
+		if set {
+			list = append(list, name)
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	sort.Strings(list)
+	b, err := json.Marshal(list)
+	if err != nil {
+		return ""
+	}
+	return models.RawJSON(b)
+}
+
+// addFilterNames adds the paths of the fields set in v, a decoded JSON
+// value, to names. Fields of nested groups are named after the group, as in
+// "any_of.last_name"; empty and false values count as unset.
+func addFilterNames(names map[string]bool, path string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if path != "" {
+				key = path + "." + key
+			}
+			addFilterNames(names, key, field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			addFilterNames(names, path, item)
+		}
+	case nil:
+	case string:
+		names[path] = names[path] || v != ""
+	case bool:
+		names[path] = names[path] || v
+	default:
+		names[path] = true
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// bufferedWriter holds a response in memory until flush writes it out.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *bufferedWriter) Flush() {}
+
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/keyset"
+	"agnos_candidate_assignment/models"
 	"agnos_candidate_assignment/repositories"
 
 	"github.com/gin-gonic/gin"
@@ -13,16 +16,31 @@ import (
 )
 
 type StaffClaims struct {
-	StaffID    uint `json:"staff_id"`
-	HospitalID uint `json:"hospital_id"`
+	StaffID     uint     `json:"staff_id"`
+	HospitalID  uint     `json:"hospital_id"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// HasPermission reports whether the token grants perm.
+func (claims *StaffClaims) HasPermission(perm string) bool {
+	for _, p := range claims.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
 type ContextStaffKey string
 
 const StaffContextKey ContextStaffKey = "staff_claims"
 
-func JWTAuth(conf *config.Config, staffRepo *repositories.StaffRepository) gin.HandlerFunc {
+// JWTAuth admits staff access tokens. Verification is pinned to the
+// algorithms of the keys currently in the set, the configured issuer and the
+// staff audience, and the token must carry an expiry and a jti.
+func JWTAuth(conf *config.Config, keys *keyset.Set, staffRepo *repositories.StaffRepository, tokenRepo *repositories.TokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if auth == "" {
@@ -38,21 +56,34 @@ func JWTAuth(conf *config.Config, staffRepo *repositories.StaffRepository) gin.H
 
 		tokenString := parts[1]
 		claims := &StaffClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(conf.JwtSecret), nil
-		})
-		if err != nil || !token.Valid {
+		parserOptions := []jwt.ParserOption{
+			jwt.WithValidMethods(keys.Algorithms()),
+			jwt.WithIssuer(conf.JWTIssuer),
+			jwt.WithAudience(conf.JWTAudience),
+			jwt.WithExpirationRequired(),
+		}
+		token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc, parserOptions...)
+		if err != nil || !token.Valid || claims.ID == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
+		// Logged-out tokens stay cryptographically valid until they expire.
+		denied, err := tokenRepo.IsDenied(claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			return
+		}
+		if denied {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			return
+		}
+
 		var staffID uint = claims.StaffID
 		var hospitalID uint = claims.HospitalID
 		if staffID == 0 {
 			var mc jwt.MapClaims
-			if _, err2 := jwt.ParseWithClaims(tokenString, &mc, func(token *jwt.Token) (interface{}, error) {
-				return []byte(conf.JwtSecret), nil
-			}); err2 == nil {
+			if _, err2 := jwt.ParseWithClaims(tokenString, &mc, keys.Keyfunc, parserOptions...); err2 == nil {
 				readUint := func(key string) (uint, bool) {
 					if v, ok := mc[key]; ok && v != nil {
 						switch t := v.(type) {
@@ -88,11 +119,38 @@ func JWTAuth(conf *config.Config, staffRepo *repositories.StaffRepository) gin.H
 			}
 		}
 
-		if _, err := staffRepo.GetByID(staffID); err != nil {
+		staff, err := staffRepo.GetByID(hospitalID, staffID)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Staff not found"})
 			return
 		}
 
+		if staff.Status != "" && staff.Status != models.StaffActive {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Staff account is not active"})
+			return
+		}
+		if !staff.Hospital.Active() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "hospital is deactivated"})
+			return
+		}
+
+		// A password change or reset ends every session started before it.
+		if staff.PasswordChangedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(staff.PasswordChangedAt.Truncate(time.Second))) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Password changed; please log in again"})
+			return
+		}
+
+		// Permissions are read from the token, so a token issued before the
+		// staff member's role changed must not keep the old grants.
+		role := ""
+		if staff.Role != nil {
+			role = staff.Role.Name
+		}
+		if role != claims.Role {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Role changed; please log in again"})
+			return
+		}
+
 		if staffID != claims.StaffID || hospitalID != claims.HospitalID {
 			c.Set(string(StaffContextKey), &StaffClaims{StaffID: staffID, HospitalID: hospitalID})
 		} else {
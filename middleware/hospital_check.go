@@ -1,28 +1,67 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
+	"agnos_candidate_assignment/models"
 	"agnos_candidate_assignment/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RequireActiveHospital rejects requests to a deactivated hospital's routes.
+// Unknown hospitals pass through so handlers report them as before.
+func RequireActiveHospital(hospRepo *repositories.HospitalRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h, err := hospitalFromPath(c, hospRepo)
+		if err == nil && !h.Active() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "hospital is deactivated"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ResolveHospital sets the hospital named by the path for routes without a
+// staff token, answering 404 for an unknown one and 500 when the lookup
+// fails.
+func ResolveHospital(hospRepo *repositories.HospitalRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h, err := hospitalFromPath(c, hospRepo)
+		if err != nil {
+			abortHospitalLookup(c, err)
+			return
+		}
+		c.Set("hospital_id", h.ID)
+		c.Next()
+	}
+}
+
+// hospitalFromPath looks up the hospital named by the :hospital path
+// parameter. It is always a name, even when it is all digits, the same as in
+// the login, registration and refresh services, so every route resolves a
+// given path to the same tenant.
+func hospitalFromPath(c *gin.Context, hospRepo *repositories.HospitalRepository) (*models.Hospital, error) {
+	return hospRepo.FindByName(c.Param("hospital"))
+}
+
+func abortHospitalLookup(c *gin.Context, err error) {
+	if errors.Is(err, repositories.ErrHospitalNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "hospital not found"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to look up hospital"})
+}
+
 func RequireHospitalMatch(hospRepo *repositories.HospitalRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		hParam := c.Param("hospital")
-		var hospID uint
-		if id, err := strconv.Atoi(hParam); err == nil {
-			hospID = uint(id)
-		} else {
-			h, err := hospRepo.FindByName(hParam)
-			if err != nil {
-				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "hospital not found"})
-				return
-			}
-			hospID = h.ID
+		h, err := hospitalFromPath(c, hospRepo)
+		if err != nil {
+			abortHospitalLookup(c, err)
+			return
 		}
+		hospID := h.ID
 
 		claims := GetStaffClaims(c)
 		if claims == nil {
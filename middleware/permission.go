@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects requests whose token does not grant perm. It
+// must run after JWTAuth.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetStaffClaims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing claims"})
+			return
+		}
+		if !claims.HasPermission(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission " + perm})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agnos_candidate_assignment/throttle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit refuses clients that exceed limiter's rate with 429 and a
+// Retry-After header. Clients are told apart by IP.
+func RateLimit(limiter *throttle.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wait := limiter.Allow(c.ClientIP(), time.Now()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests; try again later"})
+			return
+		}
+		c.Next()
+	}
+}
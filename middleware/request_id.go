@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// maxRequestIDLength bounds a client-supplied request ID.
+const maxRequestIDLength = 64
+
+// RequestID tags every request with an ID, echoed in the X-Request-ID
+// response header and written to audit entries. A well-formed ID sent by
+// the client or a proxy is kept so logs can be correlated across services;
+// otherwise a random one is generated.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				panic(err)
+			}
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestID gave the request, or "" when the
+// middleware did not run.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/keyset"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type SystemAdminClaims struct {
+	AdminID uint   `json:"admin_id"`
+	Scope   string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+const SystemAdminContextKey = "system_admin_claims"
+
+// SystemAdminAuth accepts only tokens issued by the system admin login; staff
+// tokens are rejected whatever their permissions. Like staff tokens, they
+// must carry a jti, and logged-out ones are refused.
+func SystemAdminAuth(conf *config.Config, keys *keyset.Set, adminRepo repositories.SystemAdminRepositoryInterface, tokens repositories.TokenDenylistInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Fields(c.GetHeader("Authorization"))
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing or malformed"})
+			return
+		}
+
+		claims := &SystemAdminClaims{}
+		token, err := jwt.ParseWithClaims(parts[1], claims, keys.Keyfunc,
+			jwt.WithValidMethods(keys.Algorithms()),
+			jwt.WithIssuer(conf.JWTIssuer),
+			jwt.WithAudience(conf.SystemAdminAudience()),
+			jwt.WithExpirationRequired())
+		if err != nil || !token.Valid || claims.Scope != models.SystemAdminScope || claims.AdminID == 0 || claims.ID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		denied, err := tokens.IsDenied(claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			return
+		}
+		if denied {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			return
+		}
+		if _, err := adminRepo.GetByID(claims.AdminID); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "System admin not found"})
+			return
+		}
+
+		c.Set(SystemAdminContextKey, claims)
+		c.Next()
+	}
+}
+
+func GetSystemAdminClaims(c *gin.Context) *SystemAdminClaims {
+	if v, ok := c.Get(SystemAdminContextKey); ok {
+		if claims, ok := v.(*SystemAdminClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
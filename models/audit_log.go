@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Audited actions on patient records.
+const (
+	AuditPatientSearch = "patient.search"
+	AuditPatientLookup = "patient.lookup"
+	AuditPatientVerify = "patient.verify"
+	AuditPatientRead   = "patient.read"
+	AuditPatientCreate = "patient.create"
+	AuditPatientUpdate = "patient.update"
+	AuditPatientDelete = "patient.delete"
+)
+
+// AuditLog records one access to patient records. Entries form a hash chain
+// per hospital: Seq counts up from 1 and Hash covers the entry together with
+// PrevHash, the previous entry's Hash, so editing, removing or reordering an
+// entry breaks every later link. The table is append-only; a trigger refuses
+// updates and deletes. Staff and patients are not foreign keys so entries
+// outlive them.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	HospitalID uint      `gorm:"not null;uniqueIndex:idx_audit_logs_chain,priority:1" json:"hospital_id"`
+	Seq        int64     `gorm:"not null;uniqueIndex:idx_audit_logs_chain,priority:2" json:"seq"`
+	StaffID    *uint     `gorm:"index" json:"staff_id,omitempty"`
+	Action     string    `gorm:"size:32;not null;index" json:"action"`
+	PatientIDs IDList    `gorm:"type:jsonb;not null" json:"patient_ids"`
+	Filters    RawJSON   `gorm:"type:text" json:"filters,omitempty"`
+	Status     int       `gorm:"not null" json:"status"`
+	IP         string    `gorm:"size:64" json:"ip"`
+	UserAgent  string    `gorm:"size:512" json:"user_agent"`
+	RequestID  string    `gorm:"size:64;index" json:"request_id"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+	PrevHash   string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash       string    `gorm:"size:64;not null" json:"hash"`
+}
+
+// LinkTo makes the entry the successor of prev, the last entry of its
+// chain or a zero AuditLog for the first, and seals it with its hash.
+func (l *AuditLog) LinkTo(prev *AuditLog) {
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = time.Now()
+	}
+	l.CreatedAt = l.CreatedAt.UTC().Truncate(time.Microsecond)
+	if l.PatientIDs == nil {
+		l.PatientIDs = IDList{}
+	}
+	l.Seq = prev.Seq + 1
+	l.PrevHash = prev.Hash
+	l.Hash = l.ComputeHash()
+}
+
+// ComputeHash returns the hex SHA-256 of the entry's content and PrevHash.
+// CreatedAt is hashed at microsecond precision, the precision Postgres
+// stores.
+func (l *AuditLog) ComputeHash() string {
+	content := struct {
+		HospitalID uint   `json:"hospital_id"`
+		Seq        int64  `json:"seq"`
+		StaffID    *uint  `json:"staff_id"`
+		Action     string `json:"action"`
+		PatientIDs IDList `json:"patient_ids"`
+		Filters    string `json:"filters"`
+		Status     int    `json:"status"`
+		IP         string `json:"ip"`
+		UserAgent  string `json:"user_agent"`
+		RequestID  string `json:"request_id"`
+		CreatedAt  string `json:"created_at"`
+		PrevHash   string `json:"prev_hash"`
+	}{
+		HospitalID: l.HospitalID,
+		Seq:        l.Seq,
+		StaffID:    l.StaffID,
+		Action:     l.Action,
+		PatientIDs: l.PatientIDs,
+		Filters:    string(l.Filters),
+		Status:     l.Status,
+		IP:         l.IP,
+		UserAgent:  l.UserAgent,
+		RequestID:  l.RequestID,
+		CreatedAt:  l.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		PrevHash:   l.PrevHash,
+	}
+	if content.PatientIDs == nil {
+		content.PatientIDs = IDList{}
+	}
+	b, _ := json.Marshal(content)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// IDList is a list of record IDs stored as a JSON array.
+type IDList []uint
+
+func (l IDList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]uint(l))
+	return string(b), err
+}
+
+func (l *IDList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, (*[]uint)(l))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]uint)(l))
+	case nil:
+		*l = nil
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into IDList", src)
+}
+
+// String formats the list as a JSON array, the form jsonb containment
+// queries take.
+func (l IDList) String() string {
+	b := []byte{'['}
+	for i, v := range l {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(v), 10)
+	}
+	return string(append(b, ']'))
+}
+
+// RawJSON is a JSON document stored as text, byte for byte, so it hashes
+// the same after a round trip through the database.
+type RawJSON string
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+func (j *RawJSON) UnmarshalJSON(b []byte) error {
+	*j = RawJSON(b)
+	return nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type HISSyncStatus string
+
+const (
+	HISSyncRunning   HISSyncStatus = "running"
+	HISSyncSucceeded HISSyncStatus = "succeeded"
+	HISSyncFailed    HISSyncStatus = "failed"
+)
+
+type HISSyncRun struct {
+	ID         uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	HospitalID uint          `gorm:"not null;index" json:"hospital_id"`
+	Hospital   Hospital      `gorm:"foreignKey:HospitalID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Trigger    string        `gorm:"size:20;not null" json:"trigger"`
+	Status     HISSyncStatus `gorm:"size:20;not null" json:"status"`
+	CursorFrom *time.Time    `json:"cursor_from,omitempty"`
+	CursorTo   *time.Time    `json:"cursor_to,omitempty"`
+	Fetched    int           `gorm:"not null;default:0" json:"fetched"`
+	Upserted   int           `gorm:"not null;default:0" json:"upserted"`
+	Failed     int           `gorm:"not null;default:0" json:"failed"`
+	Error      string        `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  time.Time     `gorm:"not null" json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
@@ -3,8 +3,27 @@ package models
 import "time"
 
 type Hospital struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name      string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"name"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string     `gorm:"type:varchar(255);not null;uniqueIndex" json:"name"`
+	APIURL         *string    `gorm:"column:api_url;size:512" json:"api_url,omitempty"`
+	HISAdapter     string     `gorm:"column:his_adapter;size:50" json:"his_adapter,omitempty"`
+	HISSyncedUntil *time.Time `gorm:"column:his_synced_until" json:"his_synced_until,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// RequireStaffApproval holds new staff accounts as pending until an
+	// admin approves them, even when they registered with an invitation.
+	RequireStaffApproval bool `gorm:"not null;default:false" json:"require_staff_approval"`
+
+	// RequireMFA makes every staff member enroll in TOTP MFA at their next
+	// login before they get an access token.
+	RequireMFA bool `gorm:"not null;default:false" json:"require_mfa"`
+
+	// DeactivatedAt is set while a system admin has suspended the hospital;
+	// its staff cannot log in or use the API.
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+func (h *Hospital) Active() bool {
+	return h.DeactivatedAt == nil
 }
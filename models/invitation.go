@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// StaffInvitation lets one person register as staff of a hospital. Only the
+// SHA-256 of the token is stored; the token itself is shown once, when the
+// invitation is issued. It is issued either by a staff member, CreatedByID,
+// or by a system admin, CreatedByAdminID; a system admin's invitation skips
+// the hospital's approval step, since the hospital may have no admin yet.
+type StaffInvitation struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	HospitalID       uint       `gorm:"not null;index" json:"hospital_id"`
+	Hospital         Hospital   `gorm:"foreignKey:HospitalID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	TokenHash        string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	RoleID           *uint      `json:"role_id,omitempty"`
+	Role             *Role      `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"role,omitempty"`
+	CreatedByID      *uint      `json:"created_by_id,omitempty"`
+	CreatedByAdminID *uint      `json:"created_by_admin_id,omitempty"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt           *time.Time `json:"used_at,omitempty"`
+	UsedByID         *uint      `json:"used_by_id,omitempty"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PasswordResetToken lets a staff member set a new password without the old
+// one. A hospital admin issues it; only the SHA-256 of the token is stored.
+type PasswordResetToken struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	StaffID     uint       `gorm:"not null;index" json:"staff_id"`
+	Staff       Staff      `gorm:"foreignKey:StaffID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CreatedByID uint       `gorm:"not null" json:"created_by_id"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
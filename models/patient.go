@@ -2,23 +2,59 @@ package models
 
 import "time"
 
+// Patient is a hospital's patient record. The names, national and passport
+// IDs, phone number and email are stored encrypted (see fieldcrypt); the
+// repository seals them on write and opens them on read, so outside it these
+// fields always hold plaintext.
 type Patient struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	HospitalID   uint      `gorm:"not null;index" json:"hospital_id"`
+	HospitalID   uint      `gorm:"not null;index;index:idx_patients_hospital_updated,priority:1;uniqueIndex:idx_patients_patient_hn,priority:1;uniqueIndex:idx_patients_national_id_bidx,priority:1;uniqueIndex:idx_patients_passport_id_bidx,priority:1" json:"hospital_id"`
 	Hospital     Hospital  `gorm:"foreignKey:HospitalID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"hospital,omitempty"`
-	FirstNameTH  *string   `gorm:"size:255" json:"first_name_th,omitempty"`
-	MiddleNameTH *string   `gorm:"size:255" json:"middle_name_th,omitempty"`
-	FirstNameEN  *string   `gorm:"size:255" json:"first_name_en,omitempty"`
-	MiddleNameEN *string   `gorm:"size:255" json:"middle_name_en,omitempty"`
-	LastNameTH   *string   `gorm:"size:255" json:"last_name_th,omitempty"`
-	LastNameEN   *string   `gorm:"size:255" json:"last_name_en,omitempty"`
+	FirstNameTH  *string   `gorm:"type:text" json:"first_name_th,omitempty"`
+	MiddleNameTH *string   `gorm:"type:text" json:"middle_name_th,omitempty"`
+	FirstNameEN  *string   `gorm:"type:text" json:"first_name_en,omitempty"`
+	MiddleNameEN *string   `gorm:"type:text" json:"middle_name_en,omitempty"`
+	LastNameTH   *string   `gorm:"type:text" json:"last_name_th,omitempty"`
+	LastNameEN   *string   `gorm:"type:text" json:"last_name_en,omitempty"`
 	DateOfBirth  time.Time `gorm:"type:date;not null" json:"date_of_birth"`
-	PatientHN    string    `gorm:"size:50;uniqueIndex" json:"patient_hn"`
-	NationalID   *string   `gorm:"size:255;uniqueIndex" json:"national_id,omitempty"`
-	PassportID   *string   `gorm:"size:255;uniqueIndex" json:"passport_id,omitempty"`
-	PhoneNumber  *string   `gorm:"size:50" json:"phone_number,omitempty"`
-	Email        *string   `gorm:"size:255" json:"email,omitempty"`
+	PatientHN    string    `gorm:"size:50;uniqueIndex:idx_patients_patient_hn,priority:2" json:"patient_hn"`
+	NationalID   *string   `gorm:"type:text" json:"national_id,omitempty"`
+	PassportID   *string   `gorm:"type:text" json:"passport_id,omitempty"`
+	PhoneNumber  *string   `gorm:"type:text" json:"phone_number,omitempty"`
+	Email        *string   `gorm:"type:text" json:"email,omitempty"`
 	Gender       Gender    `gorm:"size:1;not null" json:"gender"`
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime;index:idx_patients_hospital_updated,priority:2" json:"updated_at"`
+	MatchScore   *float64  `gorm:"->;-:migration" json:"match_score,omitempty"`
+
+	// Phonetic keys (see translit.Key) let a romanized query find a Thai
+	// name and the reverse. A name's key is stored only as a blind index,
+	// and its trigrams as a set of blind indexes for partial and fuzzy
+	// matches. Maintained by the repository, never exposed.
+	FirstNameTHKey    *string  `gorm:"size:255" json:"-"`
+	FirstNameENKey    *string  `gorm:"size:255" json:"-"`
+	MiddleNameTHKey   *string  `gorm:"size:255" json:"-"`
+	MiddleNameENKey   *string  `gorm:"size:255" json:"-"`
+	LastNameTHKey     *string  `gorm:"size:255" json:"-"`
+	LastNameENKey     *string  `gorm:"size:255" json:"-"`
+	FirstNameTHGrams  TokenSet `gorm:"type:text[]" json:"-"`
+	FirstNameENGrams  TokenSet `gorm:"type:text[]" json:"-"`
+	MiddleNameTHGrams TokenSet `gorm:"type:text[]" json:"-"`
+	MiddleNameENGrams TokenSet `gorm:"type:text[]" json:"-"`
+	LastNameTHGrams   TokenSet `gorm:"type:text[]" json:"-"`
+	LastNameENGrams   TokenSet `gorm:"type:text[]" json:"-"`
+
+	// Blind indexes (see fieldcrypt.BlindIndex) of the encrypted columns
+	// answer equality lookups without decrypting. Maintained by the
+	// repository, never exposed.
+	FirstNameTHBidx  *string `gorm:"size:32;index" json:"-"`
+	MiddleNameTHBidx *string `gorm:"size:32;index" json:"-"`
+	FirstNameENBidx  *string `gorm:"size:32;index" json:"-"`
+	MiddleNameENBidx *string `gorm:"size:32;index" json:"-"`
+	LastNameTHBidx   *string `gorm:"size:32;index" json:"-"`
+	LastNameENBidx   *string `gorm:"size:32;index" json:"-"`
+	NationalIDBidx   *string `gorm:"size:32;uniqueIndex:idx_patients_national_id_bidx,priority:2" json:"-"`
+	PassportIDBidx   *string `gorm:"size:32;uniqueIndex:idx_patients_passport_id_bidx,priority:2" json:"-"`
+	PhoneNumberBidx  *string `gorm:"size:32;index" json:"-"`
+	EmailBidx        *string `gorm:"size:32;index" json:"-"`
 }
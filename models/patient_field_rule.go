@@ -0,0 +1,15 @@
+package models
+
+// PatientFieldRule is one entry of a hospital's patient field policy: what
+// staff with Role (or any role, for "*") see of Field in patient responses.
+// Action is "full", "mask" or "omit" (see masking.Action).
+type PatientFieldRule struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	HospitalID uint   `gorm:"not null;uniqueIndex:idx_patient_field_rules_rule,priority:1" json:"-"`
+	Role       string `gorm:"size:50;not null;uniqueIndex:idx_patient_field_rules_rule,priority:2" json:"role"`
+	Field      string `gorm:"size:50;not null;uniqueIndex:idx_patient_field_rules_rule,priority:3" json:"field"`
+	Action     string `gorm:"size:10;not null" json:"action"`
+}
+
+// AnyRole is the PatientFieldRule role that applies to every role.
+const AnyRole = "*"
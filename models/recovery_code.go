@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use MFA fallback for a staff member who lost their
+// authenticator. Only the SHA-256 of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"-"`
+	StaffID   uint       `gorm:"not null;index" json:"-"`
+	Staff     Staff      `gorm:"foreignKey:StaffID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"-"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Permissions granted by roles. Routes are gated on these names, so they are
+// part of the API contract and stored in tokens.
+const (
+	PermPatientRead   = "patient:read"
+	PermPatientWrite  = "patient:write"
+	PermPatientDelete = "patient:delete"
+	PermHISSync       = "his:sync"
+	PermStaffManage   = "staff:manage"
+	PermAuditRead     = "audit:read"
+)
+
+// AllPermissions lists every permission the API checks.
+var AllPermissions = []string{
+	PermPatientRead,
+	PermPatientWrite,
+	PermPatientDelete,
+	PermHISSync,
+	PermStaffManage,
+	PermAuditRead,
+}
+
+const (
+	RoleAdmin        = "admin"
+	RoleDoctor       = "doctor"
+	RoleNurse        = "nurse"
+	RoleRegistration = "registration"
+	RoleAuditor      = "auditor"
+)
+
+// DefaultRoles are created at startup with exactly these permissions.
+var DefaultRoles = map[string][]string{
+	RoleAdmin:        AllPermissions,
+	RoleDoctor:       {PermPatientRead, PermPatientWrite},
+	RoleNurse:        {PermPatientRead, PermPatientWrite},
+	RoleRegistration: {PermPatientRead, PermPatientWrite},
+	RoleAuditor:      {PermPatientRead, PermAuditRead},
+}
+
+type Permission struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Name string `gorm:"size:100;not null;uniqueIndex" json:"name"`
+}
+
+type Role struct {
+	ID          uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string       `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE;" json:"permissions"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PermissionNames returns the names of the role's permissions.
+func (r *Role) PermissionNames() []string {
+	names := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
@@ -1,13 +1,53 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StaffActive   = "active"
+	StaffPending  = "pending"
+	StaffDisabled = "disabled"
+)
 
 type Staff struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserName     string    `gorm:"size:255;not null;uniqueIndex" json:"user_name"`
+	UserName     string    `gorm:"size:255;not null;uniqueIndex:idx_staffs_user_name,priority:2" json:"user_name"`
 	PasswordHash string    `gorm:"size:255;not null" json:"-"`
-	HospitalID   uint      `gorm:"not null;index" json:"hospital_id"`
+	HospitalID   uint      `gorm:"not null;index;uniqueIndex:idx_staffs_user_name,priority:1" json:"hospital_id"`
 	Hospital     Hospital  `gorm:"foreignKey:HospitalID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"hospital,omitempty"`
+	RoleID       *uint     `gorm:"index" json:"role_id,omitempty"`
+	Role         *Role     `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"role,omitempty"`
+	Status       string    `gorm:"size:20;not null;default:active" json:"status"`
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// TOTPSecret is set when MFA enrollment starts and MFAEnabled once a
+	// code from it has been verified. TOTPLastStep is the last accepted time
+	// step, so a code cannot be replayed. The secret is stored encrypted
+	// (see repositories.MFARepository.Secret).
+	TOTPSecret   *string `gorm:"type:text" json:"-"`
+	MFAEnabled   bool    `gorm:"not null;default:false" json:"mfa_enabled"`
+	TOTPLastStep int64   `gorm:"not null;default:0" json:"-"`
+
+	// FailedLogins counts wrong passwords in a row, the latest at
+	// LastFailedLoginAt. Logins are refused until LockedUntil.
+	FailedLogins      int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt *time.Time `json:"-"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty"`
+
+	// PasswordChangedAt is when the password was last changed or reset;
+	// access tokens issued before it are refused.
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+
+	// Profile details maintained by hospital admins.
+	DisplayName   string `gorm:"size:255" json:"display_name"`
+	LicenseNumber string `gorm:"size:64" json:"license_number"`
+	Department    string `gorm:"size:128" json:"department"`
+
+	// DeletedAt soft-deletes the account: it disappears from queries but the
+	// row stays for the audit history, and its username stays taken.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
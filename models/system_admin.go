@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SystemAdminScope is the scope claim of system admin tokens; staff tokens
+// never carry it.
+const SystemAdminScope = "system"
+
+// SystemAdmin operates the platform itself: it manages hospitals but belongs
+// to none and cannot use the staff API.
+type SystemAdmin struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserName     string    `gorm:"size:255;not null;uniqueIndex" json:"user_name"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
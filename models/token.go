@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RefreshToken is one link in a rotation chain. Each refresh marks the
+// presented token used and issues the next one in the same family; seeing a
+// used token again means it leaked, and the whole family is revoked. Only the
+// SHA-256 of the token is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	StaffID   uint       `gorm:"not null;index" json:"staff_id"`
+	Staff     Staff      `gorm:"foreignKey:StaffID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	FamilyID  string     `gorm:"size:32;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RevokedToken denylists an access token by its jti until it would have
+// expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;size:32;primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
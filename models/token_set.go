@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// TokenSet is a set of hex tokens stored in a Postgres text[] column. Hex
+// never needs quoting, so the array literal is just the joined tokens.
+type TokenSet []string
+
+func (s TokenSet) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return "{" + strings.Join(s, ",") + "}", nil
+}
+
+func (s *TokenSet) Scan(src interface{}) error {
+	var literal string
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		literal = v
+	case []byte:
+		literal = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into TokenSet", src)
+	}
+	literal = strings.TrimSuffix(strings.TrimPrefix(literal, "{"), "}")
+	if literal == "" {
+		*s = TokenSet{}
+		return nil
+	}
+	*s = strings.Split(literal, ",")
+	return nil
+}
@@ -0,0 +1,36 @@
+package password
+
+import "golang.org/x/crypto/bcrypt"
+
+// Hasher hashes passwords with bcrypt at Cost; a Cost below bcrypt's
+// minimum means bcrypt.DefaultCost.
+type Hasher struct {
+	Cost int
+}
+
+func (h Hasher) cost() int {
+	if h.Cost < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+func (h Hasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Compare returns nil if password matches hash.
+func (h Hasher) Compare(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// NeedsRehash reports whether hash was made with a different cost, so a
+// password that has just been verified should be hashed again.
+func (h Hasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost()
+}
@@ -0,0 +1,132 @@
+// Package password checks staff passwords against the configured policy and
+// hashes them with bcrypt.
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest password accepted. bcrypt only reads the first 72
+// bytes, so anything after them would be silently ignored.
+const MaxLength = 72
+
+// Error explains why a password was rejected.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "password " + e.Reason
+}
+
+// Policy is what a new password must satisfy. The zero value only enforces
+// MaxLength.
+type Policy struct {
+	MinLength int
+	// MinClasses is how many of lower case, upper case, digits and symbols
+	// must appear.
+	MinClasses int
+
+	// breached holds upper-case hex SHA-1 digests of known leaked passwords.
+	breached map[string]struct{}
+}
+
+// LoadBreached reads a list of leaked passwords, one per line. A line may be
+// the password itself or its SHA-1 in hex, optionally followed by ":count"
+// as in the Pwned Passwords downloads. Blank lines and lines starting with
+// '#' are skipped.
+func (p *Policy) LoadBreached(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return p.ReadBreached(f)
+}
+
+// ReadBreached adds the passwords listed in r, in the format LoadBreached
+// reads, and returns how many it read.
+func (p *Policy) ReadBreached(r io.Reader) (int, error) {
+	if p.breached == nil {
+		p.breached = map[string]struct{}{}
+	}
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if digest, ok := sha1Line(line); ok {
+			p.breached[digest] = struct{}{}
+		} else {
+			p.breached[digestOf(line)] = struct{}{}
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("reading breached password list: %w", err)
+	}
+	return n, nil
+}
+
+// Check returns an *Error if password may not be used by username.
+func (p *Policy) Check(password, username string) error {
+	if len(password) > MaxLength {
+		return &Error{Reason: fmt.Sprintf("must be at most %d bytes", MaxLength)}
+	}
+	if n := len([]rune(password)); n < p.MinLength {
+		return &Error{Reason: fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if classes(password) < p.MinClasses {
+		return &Error{Reason: fmt.Sprintf("must use at least %d of: lower case letters, upper case letters, digits, symbols", p.MinClasses)}
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return &Error{Reason: "must not be the username"}
+	}
+	if _, ok := p.breached[digestOf(password)]; ok {
+		return &Error{Reason: "appears in a list of breached passwords; choose another"}
+	}
+	return nil
+}
+
+func classes(s string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func digestOf(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// sha1Line recognizes "<40 hex digits>" with an optional ":count" suffix.
+func sha1Line(line string) (string, bool) {
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) != sha1.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(digest), true
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"time"
+
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLock namespaces the advisory locks that serialize appends to a
+// hospital's audit chain; the second key is the hospital ID.
+const auditChainLock = 0x61756469 // "audi"
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+type AuditFilter struct {
+	StaffID   *uint
+	PatientID *uint
+	Action    string
+	From      *time.Time
+	To        *time.Time
+}
+
+type AuditPage struct {
+	Entries    []models.AuditLog `json:"entries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Total      *int64            `json:"total,omitempty"`
+}
+
+// Append links entry to the end of its hospital's chain and stores it. The
+// advisory lock makes concurrent appends to one chain take turns, so no two
+// entries claim the same predecessor.
+func (repo *AuditRepository) Append(entry *models.AuditLog) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", auditChainLock, int32(entry.HospitalID)).Error; err != nil {
+			return err
+		}
+		var last models.AuditLog
+		if err := tx.Where("hospital_id = ?", entry.HospitalID).Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+
+		entry.LinkTo(&last)
+		return tx.Create(entry).Error
+	})
+}
+
+// Chain returns up to limit entries of the hospital's chain after seq, in
+// chain order.
+func (repo *AuditRepository) Chain(hospitalID uint, afterSeq int64, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := repo.db.Where("hospital_id = ? AND seq > ?", hospitalID, afterSeq).Order("seq ASC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// List returns one page of the hospital's audit entries, newest first
+// unless page.Sort is "id".
+func (repo *AuditRepository) List(hospitalID uint, filter AuditFilter, page PageRequest) (*AuditPage, error) {
+	db := repo.db.Model(&models.AuditLog{}).Where("hospital_id = ?", hospitalID)
+	if filter.StaffID != nil {
+		db = db.Where("staff_id = ?", *filter.StaffID)
+	}
+	if filter.PatientID != nil {
+		db = db.Where("patient_ids @> ?::jsonb", models.IDList{*filter.PatientID}.String())
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+
+	if page.Sort == "" {
+		page.Sort = "-id"
+	}
+	sort, err := parsePageSort(page.Sort, false)
+	if err != nil {
+		return nil, err
+	}
+	if sort.column != "id" {
+		return nil, ErrInvalidSort
+	}
+
+	result := &AuditPage{Entries: []models.AuditLog{}}
+	if page.IncludeTotal {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if page.Cursor != "" {
+		c, err := sort.decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = sort.after(db, c, nil)
+	}
+
+	limit := page.limit()
+	if err := db.Order(sort.orderBy()).Limit(limit + 1).Find(&result.Entries).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Entries) > limit {
+		result.Entries = result.Entries[:limit]
+		last := &result.Entries[limit-1]
+		result.NextCursor = sort.cursorAt(last.ID, last.CreatedAt, nil)
+	}
+	return result, nil
+}
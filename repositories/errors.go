@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// DuplicateKeyError reports which unique column a write collided with.
+type DuplicateKeyError struct {
+	Field string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return "duplicate value for " + e.Field
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
+// translateError turns Postgres unique violations into *DuplicateKeyError,
+// deriving the column from GORM's idx_<table>_<column> index naming. A
+// collision on an encrypted column's blind index reports the column itself.
+func translateError(table string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		field := strings.TrimPrefix(pgErr.ConstraintName, "idx_"+table+"_")
+		return &DuplicateKeyError{Field: strings.TrimSuffix(field, "_bidx")}
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+type FieldPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewFieldPolicyRepository(db *gorm.DB) *FieldPolicyRepository {
+	return &FieldPolicyRepository{db: db}
+}
+
+func (repo *FieldPolicyRepository) Rules(hospitalID uint) ([]models.PatientFieldRule, error) {
+	var rules []models.PatientFieldRule
+	err := repo.db.Where("hospital_id = ?", hospitalID).Order("role, field").Find(&rules).Error
+	return rules, err
+}
+
+// ReplaceRules swaps the hospital's whole policy for rules in one
+// transaction.
+func (repo *FieldPolicyRepository) ReplaceRules(hospitalID uint, rules []models.PatientFieldRule) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("hospital_id = ?", hospitalID).Delete(&models.PatientFieldRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		for i := range rules {
+			rules[i].HospitalID = hospitalID
+		}
+		return tx.Create(&rules).Error
+	})
+}
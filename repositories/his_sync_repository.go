@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+type HISSyncRepository struct {
+	db *gorm.DB
+}
+
+func NewHISSyncRepository(db *gorm.DB) *HISSyncRepository {
+	return &HISSyncRepository{db: db}
+}
+
+func (repo *HISSyncRepository) CreateRun(run *models.HISSyncRun) error {
+	return repo.db.Create(run).Error
+}
+
+func (repo *HISSyncRepository) SaveRun(run *models.HISSyncRun) error {
+	return repo.db.Save(run).Error
+}
+
+func (repo *HISSyncRepository) LastRun(hospitalID uint) (*models.HISSyncRun, error) {
+	var run models.HISSyncRun
+	if err := repo.db.Where("hospital_id = ?", hospitalID).Order("started_at DESC, id DESC").First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"agnos_candidate_assignment/models"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,22 +16,122 @@ func NewHospitalRepository(db *gorm.DB) *HospitalRepository {
 	return &HospitalRepository{db: db}
 }
 
+var ErrHospitalNotFound = errors.New("hospital not found")
+
+// ErrHospitalInUse is returned when deleting a hospital that still has staff
+// or patients; deactivate it instead.
+var ErrHospitalInUse = errors.New("hospital still has staff or patients")
+
 func (r *HospitalRepository) Create(h *models.Hospital) error {
-	return r.db.Create(h).Error
+	return translateError("hospitals", r.db.Create(h).Error)
 }
 
 func (r *HospitalRepository) FindByName(name string) (*models.Hospital, error) {
+	return r.first("name = ?", name)
+}
+
+func (r *HospitalRepository) FindByID(id uint) (*models.Hospital, error) {
+	return r.first("id = ?", id)
+}
+
+// first returns ErrHospitalNotFound when no hospital matches, and database
+// errors as they are.
+func (r *HospitalRepository) first(query string, args ...interface{}) (*models.Hospital, error) {
 	var h models.Hospital
-	if err := r.db.Where("name = ?", name).First(&h).Error; err != nil {
+	err := r.db.Where(query, args...).First(&h).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHospitalNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
 	return &h, nil
 }
 
-func (r *HospitalRepository) FindByID(id uint) (*models.Hospital, error) {
-	var h models.Hospital
-	if err := r.db.First(&h, id).Error; err != nil {
+func (r *HospitalRepository) List() ([]models.Hospital, error) {
+	var hospitals []models.Hospital
+	if err := r.db.Order("id").Find(&hospitals).Error; err != nil {
 		return nil, err
 	}
-	return &h, nil
+	return hospitals, nil
+}
+
+func (r *HospitalRepository) UpdateHISCursor(id uint, cursor time.Time) error {
+	return r.db.Model(&models.Hospital{}).Where("id = ?", id).Update("his_synced_until", cursor).Error
+}
+
+func (r *HospitalRepository) Rename(id uint, name string) error {
+	res := r.db.Model(&models.Hospital{}).Where("id = ?", id).Update("name", name)
+	if res.Error != nil {
+		return translateError("hospitals", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrHospitalNotFound
+	}
+	return nil
+}
+
+// SetDeactivatedAt suspends the hospital at the given time, or reactivates
+// it when at is nil.
+func (r *HospitalRepository) SetDeactivatedAt(id uint, at *time.Time) error {
+	res := r.db.Model(&models.Hospital{}).Where("id = ?", id).Update("deactivated_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrHospitalNotFound
+	}
+	return nil
+}
+
+// HospitalSecurity changes a hospital's staff security settings. Nil fields
+// are left as they are.
+type HospitalSecurity struct {
+	RequireMFA           *bool
+	RequireStaffApproval *bool
+}
+
+func (r *HospitalRepository) UpdateSecurity(id uint, settings HospitalSecurity) error {
+	updates := map[string]interface{}{}
+	if settings.RequireMFA != nil {
+		updates["require_mfa"] = *settings.RequireMFA
+	}
+	if settings.RequireStaffApproval != nil {
+		updates["require_staff_approval"] = *settings.RequireStaffApproval
+	}
+	if len(updates) == 0 {
+		_, err := r.FindByID(id)
+		return err
+	}
+	res := r.db.Model(&models.Hospital{}).Where("id = ?", id).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrHospitalNotFound
+	}
+	return nil
+}
+
+// Delete removes a hospital that has no staff and no patients. Its
+// invitations and sync history go with it through ON DELETE CASCADE.
+func (r *HospitalRepository) Delete(id uint) error {
+	return InHospital(r.db, id, func(tx *gorm.DB) error {
+		var h models.Hospital
+		if err := tx.First(&h, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrHospitalNotFound
+		} else if err != nil {
+			return err
+		}
+		for _, m := range []interface{}{&models.Staff{}, &models.Patient{}} {
+			var n int64
+			if err := tx.Unscoped().Model(m).Where("hospital_id = ?", id).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrHospitalInUse
+			}
+		}
+		return tx.Delete(&h).Error
+	})
 }
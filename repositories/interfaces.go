@@ -1,9 +1,84 @@
 package repositories
 
-import "agnos_candidate_assignment/models"
+import (
+	"agnos_candidate_assignment/models"
+	"time"
+)
 
 type HospitalRepositoryInterface interface {
 	Create(h *models.Hospital) error
 	FindByName(name string) (*models.Hospital, error)
 	FindByID(id uint) (*models.Hospital, error)
+	List() ([]models.Hospital, error)
+	UpdateHISCursor(id uint, cursor time.Time) error
+	Rename(id uint, name string) error
+	SetDeactivatedAt(id uint, at *time.Time) error
+	UpdateSecurity(id uint, settings HospitalSecurity) error
+	Delete(id uint) error
+}
+
+type StaffRepositoryInterface interface {
+	GetByID(hospitalID, id uint) (*models.Staff, error)
+	CountByRole(hospitalID, roleID uint) (int64, error)
+	UpdateRole(hospitalID, staffID, roleID uint) error
+	UpdateStatus(hospitalID, staffID uint, status string) error
+	ClearLoginFailures(hospitalID, staffID uint) error
+	List(hospitalID uint, filter StaffFilter, page PageRequest) (*StaffPage, error)
+	UpdateProfile(hospitalID, staffID uint, profile StaffProfile) error
+	Disable(hospitalID, staffID uint, now time.Time) error
+	SoftDelete(hospitalID, staffID uint, now time.Time) error
+}
+
+type InvitationRepositoryInterface interface {
+	Create(inv *models.StaffInvitation) error
+}
+
+type PasswordRepositoryInterface interface {
+	CreateReset(token *models.PasswordResetToken) error
+}
+
+type AuditRepositoryInterface interface {
+	Append(entry *models.AuditLog) error
+	Chain(hospitalID uint, afterSeq int64, limit int) ([]models.AuditLog, error)
+	List(hospitalID uint, filter AuditFilter, page PageRequest) (*AuditPage, error)
+}
+
+type SystemAdminRepositoryInterface interface {
+	Create(admin *models.SystemAdmin) error
+	GetByID(id uint) (*models.SystemAdmin, error)
+	FindByUserName(userName string) (*models.SystemAdmin, error)
+	Count() (int64, error)
+}
+
+// TokenDenylistInterface holds the jtis of tokens revoked before expiry.
+type TokenDenylistInterface interface {
+	Deny(jti string, expiresAt time.Time) error
+	IsDenied(jti string) (bool, error)
+}
+
+type RoleRepositoryInterface interface {
+	FindByName(name string) (*models.Role, error)
+	List() ([]models.Role, error)
+}
+
+type FieldPolicyRepositoryInterface interface {
+	Rules(hospitalID uint) ([]models.PatientFieldRule, error)
+	ReplaceRules(hospitalID uint, rules []models.PatientFieldRule) error
+}
+
+type PatientRepositoryInterface interface {
+	Create(p *models.Patient) error
+	Upsert(p *models.Patient) error
+	GetByID(hospitalID, id uint) (*models.Patient, error)
+	UpdateFields(hospitalID, id uint, fields map[string]interface{}) error
+	Delete(hospitalID, id uint) error
+	Search(hospitalID uint, criteria PatientSearchCriteria, page PageRequest) (*PatientPage, error)
+	GetByNationalID(hospitalID uint, nationalID string) (*models.Patient, error)
+	GetByPassportID(hospitalID uint, passportID string) (*models.Patient, error)
+}
+
+type HISSyncRepositoryInterface interface {
+	CreateRun(run *models.HISSyncRun) error
+	SaveRun(run *models.HISSyncRun) error
+	LastRun(hospitalID uint) (*models.HISSyncRun, error)
 }
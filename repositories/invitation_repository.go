@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"agnos_candidate_assignment/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrInvitationInvalid covers unknown, expired, used and other-hospital
+// invitations alike, so a caller cannot probe which tokens exist.
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Create stores an invitation. It returns ErrHospitalNotFound when the
+// hospital does not exist, as reported by the hospital foreign key.
+func (repo *InvitationRepository) Create(inv *models.StaffInvitation) error {
+	err := repo.db.Omit("Role", "Hospital").Create(inv).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "fk_staff_invitations_hospital" {
+		return ErrHospitalNotFound
+	}
+	return err
+}
+
+// FindUsable returns the hospital's unused, unexpired invitation with the
+// given token hash.
+func (repo *InvitationRepository) FindUsable(hospitalID uint, tokenHash string, now time.Time) (*models.StaffInvitation, error) {
+	var inv models.StaffInvitation
+	err := repo.db.Preload("Role.Permissions").
+		Where("hospital_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", hospitalID, tokenHash, now).
+		First(&inv).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// Redeem creates staff and marks the invitation used in one transaction. The
+// conditional update makes redemption single-use even when two registrations
+// race on the same token.
+func (repo *InvitationRepository) Redeem(inv *models.StaffInvitation, staff *models.Staff, now time.Time) error {
+	return InHospital(repo.db, staff.HospitalID, func(tx *gorm.DB) error {
+		res := tx.Model(&models.StaffInvitation{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", inv.ID, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+		if err := tx.Omit("Role", "Hospital").Create(staff).Error; err != nil {
+			return translateError("staffs", err)
+		}
+		return tx.Model(&models.StaffInvitation{}).Where("id = ?", inv.ID).Update("used_by_id", staff.ID).Error
+	})
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"agnos_candidate_assignment/fieldcrypt"
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+// totpSecretField names TOTP secrets to fieldcrypt, which binds each
+// ciphertext to its field.
+const totpSecretField = "totp_secret"
+
+// MFARepository stores staff MFA state. TOTP secrets are encrypted with
+// the field keys, like patient PII, so models.Staff.TOTPSecret holds
+// ciphertext; Secret opens it.
+type MFARepository struct {
+	db   *gorm.DB
+	keys *fieldcrypt.Keyring
+}
+
+func NewMFARepository(db *gorm.DB, keys *fieldcrypt.Keyring) *MFARepository {
+	return &MFARepository{db: db, keys: keys}
+}
+
+// Secret decrypts the staff member's TOTP secret. A secret stored before
+// encryption is returned as is until EncryptLegacy seals it.
+func (repo *MFARepository) Secret(staff *models.Staff) (string, error) {
+	if staff.TOTPSecret == nil {
+		return "", fmt.Errorf("staff %d has no TOTP secret", staff.ID)
+	}
+	if !fieldcrypt.IsSealed(*staff.TOTPSecret) {
+		return *staff.TOTPSecret, nil
+	}
+	secret, err := repo.keys.Open(totpSecretField, *staff.TOTPSecret)
+	if err != nil {
+		return "", fmt.Errorf("staff %d %s: %w", staff.ID, totpSecretField, err)
+	}
+	return secret, nil
+}
+
+// SetPendingSecret stores a secret for an enrollment that has not been
+// verified yet. It never touches an enabled enrollment.
+func (repo *MFARepository) SetPendingSecret(hospitalID, staffID uint, secret string) error {
+	sealed, err := repo.keys.Seal(totpSecretField, secret)
+	if err != nil {
+		return err
+	}
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Model(&models.Staff{}).
+			Where("hospital_id = ? AND id = ? AND mfa_enabled = ?", hospitalID, staffID, false).
+			Updates(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}).Error
+	})
+}
+
+// EncryptLegacy encrypts TOTP secrets stored before encryption was
+// introduced and returns how many it rewrote.
+func (repo *MFARepository) EncryptLegacy() (int, error) {
+	return repo.reseal(fieldcrypt.Prefix)
+}
+
+// Reencrypt rewrites every TOTP secret not sealed under the active master
+// key, so older master keys can be retired, and returns how many it
+// rewrote.
+func (repo *MFARepository) Reencrypt() (int, error) {
+	return repo.reseal(repo.keys.ActivePrefix())
+}
+
+// reseal rewrites the TOTP secrets not starting with prefix, across all
+// hospitals. Only staff who started an enrollment have one, so they fit in
+// one transaction.
+func (repo *MFARepository) reseal(prefix string) (int, error) {
+	updated := 0
+	err := AllHospitals(repo.db, func(tx *gorm.DB) error {
+		var stale []models.Staff
+		if err := tx.Unscoped().Select("id", "totp_secret").
+			Where("totp_secret IS NOT NULL AND totp_secret NOT LIKE ?", escapeLike(prefix)+"%").
+			Find(&stale).Error; err != nil {
+			return err
+		}
+		for i := range stale {
+			secret, err := repo.Secret(&stale[i])
+			if err != nil {
+				return err
+			}
+			sealed, err := repo.keys.Seal(totpSecretField, secret)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Staff{}).Where("id = ?", stale[i].ID).
+				UpdateColumn("totp_secret", sealed).Error; err != nil {
+				return err
+			}
+		}
+		updated = len(stale)
+		return nil
+	})
+	return updated, err
+}
+
+// Enable turns MFA on, records the step of the verifying code and replaces
+// the staff member's recovery codes.
+func (repo *MFARepository) Enable(hospitalID, staffID uint, step int64, recoveryHashes []string) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		if err := updateStaff(tx, hospitalID, staffID, map[string]interface{}{"mfa_enabled": true, "totp_last_step": step}); err != nil {
+			return err
+		}
+		if err := tx.Where("staff_id = ?", staffID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, len(recoveryHashes))
+		for i, h := range recoveryHashes {
+			codes[i] = models.RecoveryCode{StaffID: staffID, CodeHash: h}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (repo *MFARepository) Disable(hospitalID, staffID uint) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		if err := updateStaff(tx, hospitalID, staffID, map[string]interface{}{"mfa_enabled": false, "totp_secret": nil, "totp_last_step": 0}); err != nil {
+			return err
+		}
+		return tx.Where("staff_id = ?", staffID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// AdvanceStep records step as used if it is newer than the last accepted
+// one, and reports whether it was.
+func (repo *MFARepository) AdvanceStep(hospitalID, staffID uint, step int64) (bool, error) {
+	var advanced bool
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		res := tx.Model(&models.Staff{}).
+			Where("hospital_id = ? AND id = ? AND totp_last_step < ?", hospitalID, staffID, step).
+			Update("totp_last_step", step)
+		advanced = res.RowsAffected == 1
+		return res.Error
+	})
+	return advanced, err
+}
+
+// UseRecoveryCode consumes an unused recovery code and reports whether one
+// matched.
+func (repo *MFARepository) UseRecoveryCode(staffID uint, codeHash string, now time.Time) (bool, error) {
+	res := repo.db.Model(&models.RecoveryCode{}).
+		Where("staff_id = ? AND code_hash = ? AND used_at IS NULL", staffID, codeHash).
+		Update("used_at", now)
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// PageRequest asks for one keyset page. Sort is "id" or "updated_at",
+// optionally prefixed with "-" for descending order, or "relevance" for
+// ranked name searches.
+type PageRequest struct {
+	Limit        int
+	Cursor       string
+	Sort         string
+	IncludeTotal bool
+}
+
+type PatientPage struct {
+	Patients   []models.Patient `json:"patients"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Total      *int64           `json:"total,omitempty"`
+}
+
+type StaffPage struct {
+	Staff      []models.Staff `json:"staff"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      *int64         `json:"total,omitempty"`
+}
+
+type pageSort struct {
+	column string
+	desc   bool
+}
+
+// pageCursor is the position after the last row of a page. It records the
+// sort it was issued for so it cannot be replayed against another order.
+type pageCursor struct {
+	Sort      string     `json:"s"`
+	ID        uint       `json:"id"`
+	UpdatedAt *time.Time `json:"u,omitempty"`
+	Score     *float64   `json:"sc,omitempty"`
+}
+
+func (page PageRequest) limit() int {
+	switch {
+	case page.Limit <= 0:
+		return DefaultPageSize
+	case page.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return page.Limit
+}
+
+// parsePageSort defaults to relevance for ranked searches and to id
+// otherwise. Relevance is only valid when there is a score to order by.
+func parsePageSort(s string, ranked bool) (pageSort, error) {
+	switch {
+	case s == "" && ranked, s == "relevance" && ranked:
+		return pageSort{column: "relevance"}, nil
+	case s == "":
+		return pageSort{column: "id"}, nil
+	}
+	sort := pageSort{column: strings.TrimPrefix(s, "-"), desc: strings.HasPrefix(s, "-")}
+	if sort.column != "id" && sort.column != "updated_at" {
+		return pageSort{}, ErrInvalidSort
+	}
+	return sort, nil
+}
+
+func (sort pageSort) String() string {
+	if sort.desc {
+		return "-" + sort.column
+	}
+	return sort.column
+}
+
+func (sort pageSort) orderBy() string {
+	dir := " ASC"
+	if sort.desc {
+		dir = " DESC"
+	}
+	switch sort.column {
+	case "relevance":
+		return "match_score DESC, id ASC"
+	case "updated_at":
+		return "updated_at" + dir + ", id" + dir
+	}
+	return "id" + dir
+}
+
+// after restricts the query to rows strictly past the cursor. The score
+// expression is repeated because WHERE cannot reference the select alias.
+func (sort pageSort) after(db *gorm.DB, c *pageCursor, score *scoreExpr) *gorm.DB {
+	op := " > "
+	if sort.desc {
+		op = " < "
+	}
+	switch sort.column {
+	case "relevance":
+		args := append(append(append([]interface{}{}, score.args...), *c.Score), score.args...)
+		args = append(args, *c.Score, c.ID)
+		return db.Where("("+score.sql+" < ? OR ("+score.sql+" = ? AND id > ?))", args...)
+	case "updated_at":
+		return db.Where("(updated_at, id)"+op+"(?, ?)", *c.UpdatedAt, c.ID)
+	}
+	return db.Where("id"+op+"?", c.ID)
+}
+
+func (sort pageSort) cursorFor(p *models.Patient) string {
+	return sort.cursorAt(p.ID, p.UpdatedAt, p.MatchScore)
+}
+
+func (sort pageSort) cursorAt(id uint, updatedAt time.Time, score *float64) string {
+	c := pageCursor{Sort: sort.String(), ID: id}
+	switch sort.column {
+	case "relevance":
+		c.Score = score
+	case "updated_at":
+		c.UpdatedAt = &updatedAt
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (sort pageSort) decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort.String() {
+		return nil, ErrInvalidCursor
+	}
+	if (sort.column == "updated_at" && c.UpdatedAt == nil) || (sort.column == "relevance" && c.Score == nil) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
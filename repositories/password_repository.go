@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrResetTokenInvalid covers unknown, expired and used reset tokens alike.
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+type PasswordRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordRepository(db *gorm.DB) *PasswordRepository {
+	return &PasswordRepository{db: db}
+}
+
+// CreateReset stores a reset token and withdraws the staff member's earlier
+// unused ones, so only the latest token works.
+func (repo *PasswordRepository) CreateReset(token *models.PasswordResetToken) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("staff_id = ? AND used_at IS NULL", token.StaffID).
+			Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// FindReset returns the unused, unexpired reset token with the given hash
+// issued for a staff member of the hospital, with the staff member and
+// their hospital.
+func (repo *PasswordRepository) FindReset(hospitalID uint, tokenHash string, now time.Time) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Preload("Staff.Hospital").
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+			Where("staff_id IN (?)", tx.Model(&models.Staff{}).Select("id").Where("hospital_id = ?", hospitalID)).
+			First(&token).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SetPassword stores a new password hash, ends every session of the staff
+// member by revoking their refresh tokens, and clears failed logins.
+func (repo *PasswordRepository) SetPassword(hospitalID, staffID uint, hash string, now time.Time) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return setPassword(tx, hospitalID, staffID, hash, now)
+	})
+}
+
+// RedeemReset marks the token used and sets the password in one
+// transaction, so a token sets at most one password.
+func (repo *PasswordRepository) RedeemReset(hospitalID uint, token *models.PasswordResetToken, hash string, now time.Time) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		return setPassword(tx, hospitalID, token.StaffID, hash, now)
+	})
+}
+
+func setPassword(tx *gorm.DB, hospitalID, staffID uint, hash string, now time.Time) error {
+	if err := updateStaff(tx, hospitalID, staffID, map[string]interface{}{
+		"password_hash":        hash,
+		"password_changed_at":  now,
+		"failed_logins":        0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}); err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("staff_id = ? AND revoked_at IS NULL", staffID).
+		Update("revoked_at", now).Error
+}
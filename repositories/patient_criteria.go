@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/models"
+)
+
+// PatientSearchCriteria describes a patient search. Every set field must
+// match; AnyOf requires at least one of its groups to match and AllOf all of
+// them, so criteria nest into arbitrary AND/OR trees. Zero values are
+// ignored. Match applies to every name filter in the tree and is only read
+// from the root.
+type PatientSearchCriteria struct {
+	PatientHN  string `json:"patient_hn,omitempty"`
+	NationalID string `json:"national_id,omitempty"`
+	PassportID string `json:"passport_id,omitempty"`
+
+	// FirstName, MiddleName and LastName match either script.
+	FirstName    string `json:"first_name,omitempty"`
+	MiddleName   string `json:"middle_name,omitempty"`
+	LastName     string `json:"last_name,omitempty"`
+	FirstNameTH  string `json:"first_name_th,omitempty"`
+	MiddleNameTH string `json:"middle_name_th,omitempty"`
+	LastNameTH   string `json:"last_name_th,omitempty"`
+	FirstNameEN  string `json:"first_name_en,omitempty"`
+	MiddleNameEN string `json:"middle_name_en,omitempty"`
+	LastNameEN   string `json:"last_name_en,omitempty"`
+
+	PhoneNumber string        `json:"phone_number,omitempty"`
+	Email       string        `json:"email,omitempty"`
+	Gender      models.Gender `json:"gender,omitempty"`
+
+	// DateOfBirth matches one day; DOBFrom and DOBTo are inclusive bounds.
+	DateOfBirth *time.Time `json:"date_of_birth,omitempty"`
+	DOBFrom     *time.Time `json:"dob_from,omitempty"`
+	DOBTo       *time.Time `json:"dob_to,omitempty"`
+	// AgeMin and AgeMax are inclusive bounds on age in whole years.
+	AgeMin *int `json:"age_min,omitempty"`
+	AgeMax *int `json:"age_max,omitempty"`
+
+	AnyOf []PatientSearchCriteria `json:"any_of,omitempty"`
+	AllOf []PatientSearchCriteria `json:"all_of,omitempty"`
+
+	Match MatchMode `json:"match,omitempty"`
+}
+
+// Name columns searched by each language-neutral name criterion.
+var (
+	firstNameColumns  = []string{"first_name_th", "first_name_en"}
+	middleNameColumns = []string{"middle_name_th", "middle_name_en"}
+	lastNameColumns   = []string{"last_name_th", "last_name_en"}
+)
+
+// IsEmpty reports whether the criteria match every patient.
+func (criteria *PatientSearchCriteria) IsEmpty() bool {
+	w, _ := criteria.build(MatchExact, func(string, string) string { return "" }, time.Now())
+	return w == nil
+}
+
+// Simple reports whether the criteria are a flat set of equality filters,
+// with no groups, ranges or ages.
+func (criteria *PatientSearchCriteria) Simple() bool {
+	return len(criteria.AnyOf) == 0 && len(criteria.AllOf) == 0 &&
+		criteria.DOBFrom == nil && criteria.DOBTo == nil &&
+		criteria.AgeMin == nil && criteria.AgeMax == nil
+}
+
+// Walk calls fn for the criteria and every nested group, depth first, and
+// stops at the first error.
+func (criteria *PatientSearchCriteria) Walk(fn func(*PatientSearchCriteria) error) error {
+	if err := fn(criteria); err != nil {
+		return err
+	}
+	for i := range criteria.AnyOf {
+		if err := criteria.AnyOf[i].Walk(fn); err != nil {
+			return err
+		}
+	}
+	for i := range criteria.AllOf {
+		if err := criteria.AllOf[i].Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Where renders the criteria as a parameterized SQL condition, comparing
+// encrypted columns by their blind index from index and evaluating ages
+// against now. Column names come only from this file; every caller-supplied
+// value is a bind argument. An empty string means no condition.
+func (criteria *PatientSearchCriteria) Where(index BlindIndexFunc, now time.Time) (string, []interface{}) {
+	w, _ := criteria.build(criteria.Match, index, now)
+	if w == nil {
+		return "", nil
+	}
+	return w.sql, w.args
+}
+
+// condition is a SQL boolean expression with its bind arguments.
+type condition struct {
+	sql  string
+	args []interface{}
+}
+
+func joinConditions(conds []condition, op string) *condition {
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		return &conds[0]
+	}
+	parts := make([]string, len(conds))
+	var args []interface{}
+	for i, c := range conds {
+		parts[i] = c.sql
+		args = append(args, c.args...)
+	}
+	return &condition{sql: "(" + strings.Join(parts, " "+op+" ") + ")", args: args}
+}
+
+// build returns the condition for the criteria, nil when it matches
+// everything, and a similarity score for every name filter in the tree.
+func (criteria *PatientSearchCriteria) build(match MatchMode, index BlindIndexFunc, now time.Time) (*condition, []scoreExpr) {
+	var conds []condition
+	var scores []scoreExpr
+
+	equal := func(column, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			conds = append(conds, condition{sql: column + " = ?", args: []interface{}{value}})
+		}
+	}
+	sealed := func(column, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			conds = append(conds, condition{sql: bidxColumn(column) + " = ?", args: []interface{}{index(column, value)}})
+		}
+	}
+	name := func(columns []string, phonetic bool, value string) {
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		sql, args := nameCondition(columns, phonetic, match, value, index)
+		conds = append(conds, condition{sql: sql, args: args})
+		if match.ranked() {
+			scores = append(scores, nameScore(columns, value, index))
+		}
+	}
+	date := func(op string, t *time.Time) {
+		if t != nil {
+			conds = append(conds, condition{sql: "date_of_birth " + op + " ?", args: []interface{}{dateOnly(*t)}})
+		}
+	}
+
+	equal("patient_hn", criteria.PatientHN)
+	sealed("national_id", criteria.NationalID)
+	sealed("passport_id", criteria.PassportID)
+	name(firstNameColumns, true, criteria.FirstName)
+	name(middleNameColumns, true, criteria.MiddleName)
+	name(lastNameColumns, true, criteria.LastName)
+	name([]string{"first_name_th"}, false, criteria.FirstNameTH)
+	name([]string{"middle_name_th"}, false, criteria.MiddleNameTH)
+	name([]string{"last_name_th"}, false, criteria.LastNameTH)
+	name([]string{"first_name_en"}, false, criteria.FirstNameEN)
+	name([]string{"middle_name_en"}, false, criteria.MiddleNameEN)
+	name([]string{"last_name_en"}, false, criteria.LastNameEN)
+	sealed("phone_number", criteria.PhoneNumber)
+	sealed("email", criteria.Email)
+	equal("gender", string(criteria.Gender))
+
+	date("=", criteria.DateOfBirth)
+	date(">=", criteria.DOBFrom)
+	date("<=", criteria.DOBTo)
+
+	// Someone is at least n years old if born on or before today's date n
+	// years ago, and at most n years old if born after that date a year
+	// earlier.
+	today := dateOnly(now)
+	if criteria.AgeMin != nil {
+		born := today.AddDate(-*criteria.AgeMin, 0, 0)
+		date("<=", &born)
+	}
+	if criteria.AgeMax != nil {
+		born := today.AddDate(-*criteria.AgeMax-1, 0, 0)
+		date(">", &born)
+	}
+
+	if len(criteria.AnyOf) > 0 {
+		var groups []condition
+		matchesAll := false
+		for i := range criteria.AnyOf {
+			g, s := criteria.AnyOf[i].build(match, index, now)
+			scores = append(scores, s...)
+			if g == nil {
+				matchesAll = true
+				continue
+			}
+			groups = append(groups, *g)
+		}
+		// An empty alternative matches everyone, so the whole group does.
+		if !matchesAll {
+			conds = append(conds, *joinConditions(groups, "OR"))
+		}
+	}
+	for i := range criteria.AllOf {
+		g, s := criteria.AllOf[i].build(match, index, now)
+		scores = append(scores, s...)
+		if g != nil {
+			conds = append(conds, *g)
+		}
+	}
+
+	return joinConditions(conds, "AND"), scores
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"agnos_candidate_assignment/fieldcrypt"
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+// BlindIndexFunc returns the blind index of a value in an encrypted column,
+// normalizing the value the way stored values were.
+type BlindIndexFunc func(column, value string) string
+
+// sealedField is an encrypted patient column with accessors for its value
+// and its blind index. Folded columns are indexed lower-cased so lookups
+// ignore case.
+type sealedField struct {
+	column string
+	fold   bool
+	value  func(p *models.Patient) **string
+	bidx   func(p *models.Patient) **string
+}
+
+var sealedFields = []sealedField{
+	{"first_name_th", true, func(p *models.Patient) **string { return &p.FirstNameTH }, func(p *models.Patient) **string { return &p.FirstNameTHBidx }},
+	{"middle_name_th", true, func(p *models.Patient) **string { return &p.MiddleNameTH }, func(p *models.Patient) **string { return &p.MiddleNameTHBidx }},
+	{"last_name_th", true, func(p *models.Patient) **string { return &p.LastNameTH }, func(p *models.Patient) **string { return &p.LastNameTHBidx }},
+	{"first_name_en", true, func(p *models.Patient) **string { return &p.FirstNameEN }, func(p *models.Patient) **string { return &p.FirstNameENBidx }},
+	{"middle_name_en", true, func(p *models.Patient) **string { return &p.MiddleNameEN }, func(p *models.Patient) **string { return &p.MiddleNameENBidx }},
+	{"last_name_en", true, func(p *models.Patient) **string { return &p.LastNameEN }, func(p *models.Patient) **string { return &p.LastNameENBidx }},
+	{"national_id", false, func(p *models.Patient) **string { return &p.NationalID }, func(p *models.Patient) **string { return &p.NationalIDBidx }},
+	{"passport_id", false, func(p *models.Patient) **string { return &p.PassportID }, func(p *models.Patient) **string { return &p.PassportIDBidx }},
+	{"phone_number", false, func(p *models.Patient) **string { return &p.PhoneNumber }, func(p *models.Patient) **string { return &p.PhoneNumberBidx }},
+	{"email", true, func(p *models.Patient) **string { return &p.Email }, func(p *models.Patient) **string { return &p.EmailBidx }},
+}
+
+func bidxColumn(column string) string {
+	return column + "_bidx"
+}
+
+func (repo *PatientRepository) blindIndex(column, value string) string {
+	value = strings.TrimSpace(value)
+	for _, f := range sealedFields {
+		if f.column == column && f.fold {
+			value = strings.ToLower(value)
+		}
+	}
+	return repo.keys.BlindIndex(column, value)
+}
+
+// seal returns a copy of p ready to store: encrypted, with its blind
+// indexes and phonetic keys set. p itself is left in plaintext.
+func (repo *PatientRepository) seal(p *models.Patient) (*models.Patient, error) {
+	sealed := *p
+	repo.setNameKeys(&sealed)
+	for _, f := range sealedFields {
+		v := *f.value(&sealed)
+		if v == nil {
+			*f.bidx(&sealed) = nil
+			continue
+		}
+		ct, err := repo.keys.Seal(f.column, *v)
+		if err != nil {
+			return nil, err
+		}
+		idx := repo.blindIndex(f.column, *v)
+		*f.value(&sealed) = &ct
+		*f.bidx(&sealed) = &idx
+	}
+	return &sealed, nil
+}
+
+// open decrypts p in place.
+func (repo *PatientRepository) open(p *models.Patient) error {
+	for _, f := range sealedFields {
+		v := f.value(p)
+		if *v == nil {
+			continue
+		}
+		pt, err := repo.keys.Open(f.column, **v)
+		if err != nil {
+			return fmt.Errorf("patient %d %s: %w", p.ID, f.column, err)
+		}
+		*v = &pt
+	}
+	return nil
+}
+
+// sealFields encrypts the encrypted columns of a partial update and sets
+// their blind indexes; a nil value clears both.
+func (repo *PatientRepository) sealFields(fields map[string]interface{}) error {
+	for _, f := range sealedFields {
+		v, ok := fields[f.column]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			fields[f.column] = nil
+			fields[bidxColumn(f.column)] = nil
+			continue
+		}
+		ct, err := repo.keys.Seal(f.column, s)
+		if err != nil {
+			return err
+		}
+		fields[f.column] = ct
+		fields[bidxColumn(f.column)] = repo.blindIndex(f.column, s)
+	}
+	return nil
+}
+
+// EncryptLegacy encrypts values stored before encryption was introduced and
+// fills in missing blind indexes and phonetic keys, batchSize rows at a
+// time. It returns how many rows were rewritten.
+func (repo *PatientRepository) EncryptLegacy(batchSize int) (int, error) {
+	return repo.reseal(batchSize, fieldcrypt.Prefix)
+}
+
+// Reencrypt rewrites every row holding a value not sealed under the active
+// master key, legacy plaintext included, batchSize rows at a time, so older
+// master keys can be retired. It returns how many rows were rewritten.
+func (repo *PatientRepository) Reencrypt(batchSize int) (int, error) {
+	return repo.reseal(batchSize, repo.keys.ActivePrefix())
+}
+
+// reseal rewrites the rows with an encrypted value not starting with prefix,
+// a missing blind index or missing phonetic key indexes, across all
+// hospitals.
+func (repo *PatientRepository) reseal(batchSize int, prefix string) (int, error) {
+	var stale []string
+	var args []interface{}
+	for _, f := range sealedFields {
+		stale = append(stale, "("+f.column+" IS NOT NULL AND ("+f.column+" NOT LIKE ? OR "+bidxColumn(f.column)+" IS NULL))")
+		args = append(args, escapeLike(prefix)+"%")
+	}
+	for _, f := range nameKeyFields {
+		stale = append(stale, "("+f.column+" IS NOT NULL AND ("+nameKeyColumn(f.column)+" IS NULL OR "+nameGramsColumn(f.column)+" IS NULL))")
+	}
+	where := strings.Join(stale, " OR ")
+
+	updated := 0
+	var lastID uint
+	for {
+		var batch []models.Patient
+		err := AllHospitals(repo.db, func(tx *gorm.DB) error {
+			return tx.Where("id > ?", lastID).Where(where, args...).
+				Order("id").Limit(batchSize).Find(&batch).Error
+		})
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		err = AllHospitals(repo.db, func(tx *gorm.DB) error {
+			for i := range batch {
+				if err := repo.open(&batch[i]); err != nil {
+					return err
+				}
+				sealed, err := repo.seal(&batch[i])
+				if err != nil {
+					return err
+				}
+				// UpdateColumns leaves updated_at alone so re-encryption
+				// does not look like a change to the HIS sync.
+				if err := tx.Model(sealed).UpdateColumns(storedColumns(sealed)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return updated, err
+		}
+		updated += len(batch)
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// storedColumns are the encrypted, blind index, phonetic key and key
+// trigram columns of a sealed patient.
+func storedColumns(p *models.Patient) map[string]interface{} {
+	columns := map[string]interface{}{}
+	for _, f := range nameKeyFields {
+		columns[nameKeyColumn(f.column)] = *f.key(p)
+		columns[nameGramsColumn(f.column)] = *f.grams(p)
+	}
+	for _, f := range sealedFields {
+		columns[f.column] = *f.value(p)
+		columns[bidxColumn(f.column)] = *f.bidx(p)
+	}
+	return columns
+}
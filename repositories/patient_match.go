@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+
+	"agnos_candidate_assignment/translit"
+
+	"gorm.io/gorm"
+)
+
+// MatchMode selects how name filters compare against stored names.
+type MatchMode string
+
+const (
+	MatchExact    MatchMode = "exact"
+	MatchPrefix   MatchMode = "prefix"
+	MatchContains MatchMode = "contains"
+	MatchFuzzy    MatchMode = "fuzzy"
+)
+
+var ErrInvalidMatch = errors.New("invalid match mode")
+
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch MatchMode(s) {
+	case "", MatchExact:
+		return MatchExact, nil
+	case MatchPrefix, MatchContains, MatchFuzzy:
+		return MatchMode(s), nil
+	}
+	return "", ErrInvalidMatch
+}
+
+// ranked reports whether results should carry a similarity score and be
+// ordered by it by default.
+func (mode MatchMode) ranked() bool {
+	return mode == MatchPrefix || mode == MatchContains || mode == MatchFuzzy
+}
+
+// scoreExpr is a SQL expression with its bind arguments.
+type scoreExpr struct {
+	sql  string
+	args []interface{}
+}
+
+// fuzzyThreshold is the least trigram similarity a fuzzy match needs, the
+// pg_trgm default.
+const fuzzyThreshold = 0.3
+
+// nameCondition builds the WHERE clause for one name filter. Names are
+// stored encrypted, so an exact match compares blind indexes, plus the
+// blind indexes of the phonetic keys when phonetic is set so either script
+// finds the other. The other modes can only compare phonetic keys, through
+// the blind indexes of their trigrams in <column>_grams: prefix and contains
+// look for every trigram of the query's key, and fuzzy for enough of them.
+// They match on how a name sounds, not how it is spelled, and a contains
+// query shorter than three letters of key matches as a prefix. A value
+// without a key matches nothing in them.
+func nameCondition(columns []string, phonetic bool, mode MatchMode, value string, index BlindIndexFunc) (string, []interface{}) {
+	key := translit.Key(value)
+
+	var parts []string
+	var args []interface{}
+	if mode == MatchExact || mode == "" {
+		for _, col := range columns {
+			parts = append(parts, bidxColumn(col)+" = ?")
+			args = append(args, index(col, value))
+		}
+		if !phonetic || key == "" {
+			return "(" + strings.Join(parts, " OR ") + ")", args
+		}
+		for _, col := range columns {
+			parts = append(parts, nameKeyColumn(col)+" = ?")
+			args = append(args, index(nameKeyColumn(col), key))
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", args
+	}
+	if key == "" {
+		return "FALSE", nil
+	}
+
+	grams := queryGrams(mode, key)
+	for _, col := range columns {
+		tokens := indexGrams(col, grams, index)
+		if mode == MatchFuzzy {
+			parts = append(parts, "("+nameGramsColumn(col)+" && ?::text[] AND token_similarity("+nameGramsColumn(col)+", ?::text[]) >= ?)")
+			args = append(args, tokens, tokens, fuzzyThreshold)
+			continue
+		}
+		parts = append(parts, nameGramsColumn(col)+" @> ?::text[]")
+		args = append(args, tokens)
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
+
+// queryGrams returns the trigrams a stored key must hold to match key in
+// the mode.
+func queryGrams(mode MatchMode, key string) []string {
+	switch {
+	case mode == MatchContains && len(key) >= 3:
+		return trigrams(key)
+	case mode == MatchPrefix || mode == MatchContains:
+		return trigrams(gramStart + key)
+	}
+	return keyGrams(key)
+}
+
+// nameScore is the best trigram similarity between the value's phonetic key
+// and the key of any of the columns.
+func nameScore(columns []string, value string, index BlindIndexFunc) scoreExpr {
+	key := translit.Key(value)
+	if key == "" {
+		return scoreExpr{sql: "0"}
+	}
+
+	var parts []string
+	var args []interface{}
+	for _, col := range columns {
+		parts = append(parts, "token_similarity("+nameGramsColumn(col)+", ?::text[])")
+		args = append(args, indexGrams(col, keyGrams(key), index))
+	}
+	return scoreExpr{sql: "GREATEST(" + strings.Join(parts, ", ") + ")", args: args}
+}
+
+// sumScores adds per-filter scores so rows matching several names well rank
+// first.
+func sumScores(scores []scoreExpr) *scoreExpr {
+	if len(scores) == 0 {
+		return nil
+	}
+	parts := make([]string, len(scores))
+	var args []interface{}
+	for i, s := range scores {
+		parts[i] = s.sql
+		args = append(args, s.args...)
+	}
+	return &scoreExpr{sql: "(" + strings.Join(parts, " + ") + ")::float8", args: args}
+}
+
+func (score *scoreExpr) selectInto(db *gorm.DB) *gorm.DB {
+	return db.Select("patients.*, "+score.sql+" AS match_score", score.args...)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/translit"
+)
+
+// nameKeyField is a name column with accessors for its value and the blind
+// indexes of its phonetic key and key trigrams.
+type nameKeyField struct {
+	column string
+	value  func(p *models.Patient) **string
+	key    func(p *models.Patient) **string
+	grams  func(p *models.Patient) *models.TokenSet
+}
+
+var nameKeyFields = []nameKeyField{
+	{"first_name_th", func(p *models.Patient) **string { return &p.FirstNameTH }, func(p *models.Patient) **string { return &p.FirstNameTHKey }, func(p *models.Patient) *models.TokenSet { return &p.FirstNameTHGrams }},
+	{"first_name_en", func(p *models.Patient) **string { return &p.FirstNameEN }, func(p *models.Patient) **string { return &p.FirstNameENKey }, func(p *models.Patient) *models.TokenSet { return &p.FirstNameENGrams }},
+	{"middle_name_th", func(p *models.Patient) **string { return &p.MiddleNameTH }, func(p *models.Patient) **string { return &p.MiddleNameTHKey }, func(p *models.Patient) *models.TokenSet { return &p.MiddleNameTHGrams }},
+	{"middle_name_en", func(p *models.Patient) **string { return &p.MiddleNameEN }, func(p *models.Patient) **string { return &p.MiddleNameENKey }, func(p *models.Patient) *models.TokenSet { return &p.MiddleNameENGrams }},
+	{"last_name_th", func(p *models.Patient) **string { return &p.LastNameTH }, func(p *models.Patient) **string { return &p.LastNameTHKey }, func(p *models.Patient) *models.TokenSet { return &p.LastNameTHGrams }},
+	{"last_name_en", func(p *models.Patient) **string { return &p.LastNameEN }, func(p *models.Patient) **string { return &p.LastNameENKey }, func(p *models.Patient) *models.TokenSet { return &p.LastNameENGrams }},
+}
+
+func nameKeyColumn(column string) string {
+	return column + "_key"
+}
+
+func nameGramsColumn(column string) string {
+	return column + "_grams"
+}
+
+// Phonetic keys are ASCII letters, so these pads never clash with one.
+const (
+	gramStart = "^^"
+	gramEnd   = "$"
+)
+
+// trigrams returns the distinct three-letter windows of s, in order.
+func trigrams(s string) []string {
+	var grams []string
+	seen := map[string]bool{}
+	for i := 0; i+3 <= len(s); i++ {
+		g := s[i : i+3]
+		if !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
+
+// keyGrams returns the trigrams stored for a whole phonetic key. Like
+// pg_trgm, the key is padded so that its start and end count as letters.
+func keyGrams(key string) []string {
+	return trigrams(gramStart + key + gramEnd)
+}
+
+// indexGrams returns the blind indexes of grams in the column's trigram
+// set.
+func indexGrams(column string, grams []string, index BlindIndexFunc) models.TokenSet {
+	tokens := make(models.TokenSet, len(grams))
+	for i, g := range grams {
+		tokens[i] = index(nameGramsColumn(column), g)
+	}
+	return tokens
+}
+
+// nameKeys returns the blind indexes of a name's phonetic key and of its
+// key trigrams, or nils when the name has no key.
+func nameKeys(column string, name *string, index BlindIndexFunc) (*string, models.TokenSet) {
+	if name == nil {
+		return nil, nil
+	}
+	key := translit.Key(*name)
+	if key == "" {
+		return nil, nil
+	}
+	idx := index(nameKeyColumn(column), key)
+	return &idx, indexGrams(column, keyGrams(key), index)
+}
+
+func (repo *PatientRepository) setNameKeys(p *models.Patient) {
+	for _, f := range nameKeyFields {
+		*f.key(p), *f.grams(p) = nameKeys(f.column, *f.value(p), repo.blindIndex)
+	}
+}
+
+// addNameKeyFields adds the key and trigram columns for every name column
+// present in a partial update.
+func (repo *PatientRepository) addNameKeyFields(fields map[string]interface{}) {
+	for _, f := range nameKeyFields {
+		v, ok := fields[f.column]
+		if !ok {
+			continue
+		}
+		name, _ := v.(string)
+		fields[nameKeyColumn(f.column)], fields[nameGramsColumn(f.column)] = nameKeys(f.column, &name, repo.blindIndex)
+	}
+}
@@ -1,50 +1,206 @@
 package repositories
 
 import (
+	"time"
+
+	"agnos_candidate_assignment/fieldcrypt"
 	"agnos_candidate_assignment/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// PatientRepository stores patients with their personal fields encrypted
+// under keys; callers only ever see plaintext. Every method that takes a
+// hospital runs InHospital, so row-level security backs up its filters.
 type PatientRepository struct {
-	db *gorm.DB
+	db   *gorm.DB
+	keys *fieldcrypt.Keyring
 }
 
-func NewPatientRepository(db *gorm.DB) *PatientRepository {
-	return &PatientRepository{db: db}
+func NewPatientRepository(db *gorm.DB, keys *fieldcrypt.Keyring) *PatientRepository {
+	return &PatientRepository{db: db, keys: keys}
 }
 
 func (repo *PatientRepository) Create(p *models.Patient) error {
-	return repo.db.Create(p).Error
-}
-
-func (repo *PatientRepository) Search(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error) {
-	db := repo.db.Model(&models.Patient{}).Where("hospital_id = ?", hospitalID)
-	for k, v := range filters {
-		switch k {
-		case "first_name":
-			db = db.Where("(first_name_th = ? OR first_name_en = ?)", v, v)
-		case "middle_name":
-			db = db.Where("(middle_name_th = ? OR middle_name_en = ?)", v, v)
-		case "last_name":
-			db = db.Where("(last_name_th = ? OR last_name_en = ?)", v, v)
-		default:
-			db = db.Where(k+" = ?", v)
-		}
+	sealed, err := repo.seal(p)
+	if err != nil {
+		return err
 	}
-
-	var results []models.Patient
-	if err := db.Find(&results).Error; err != nil {
-		return nil, err
+	err = InHospital(repo.db, p.HospitalID, func(tx *gorm.DB) error {
+		return tx.Create(sealed).Error
+	})
+	if err != nil {
+		return translateError("patients", err)
 	}
+	p.ID, p.CreatedAt, p.UpdatedAt = sealed.ID, sealed.CreatedAt, sealed.UpdatedAt
+	return nil
+}
 
-	return results, nil
+func (repo *PatientRepository) GetByID(hospitalID, id uint) (*models.Patient, error) {
+	return repo.first(hospitalID, "id = ?", id)
 }
 
-func (repo *PatientRepository) GetByNationalOrPassportID(hospitalID uint, id string) (*models.Patient, error) {
+func (repo *PatientRepository) first(hospitalID uint, query string, args ...interface{}) (*models.Patient, error) {
 	var result models.Patient
-	if err := repo.db.Where("hospital_id = ? AND (national_id = ? OR passport_id = ?)", hospitalID, id, id).First(&result).Error; err != nil {
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Where("hospital_id = ?", hospitalID).Where(query, args...).First(&result).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.open(&result); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
+
+// UpdateFields applies a partial update keyed by column name. Callers must
+// only pass fixed column names, never user input.
+func (repo *PatientRepository) UpdateFields(hospitalID, id uint, fields map[string]interface{}) error {
+	repo.addNameKeyFields(fields)
+	if err := repo.sealFields(fields); err != nil {
+		return err
+	}
+	var updated int64
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		res := tx.Model(&models.Patient{}).Where("hospital_id = ? AND id = ?", hospitalID, id).Updates(fields)
+		updated = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return translateError("patients", err)
+	}
+	if updated == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repo *PatientRepository) Delete(hospitalID, id uint) error {
+	var deleted int64
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		res := tx.Where("hospital_id = ? AND id = ?", hospitalID, id).Delete(&models.Patient{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Upsert inserts the patient or, when the hospital already has a row with
+// the same HN, overwrites it with the incoming values. HNs are unique per
+// hospital, so another hospital's patient with the same HN is never touched.
+func (repo *PatientRepository) Upsert(p *models.Patient) error {
+	sealed, err := repo.seal(p)
+	if err != nil {
+		return err
+	}
+	err = InHospital(repo.db, p.HospitalID, func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "hospital_id"}, {Name: "patient_hn"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"first_name_th", "middle_name_th", "last_name_th",
+				"first_name_en", "middle_name_en", "last_name_en",
+				"first_name_th_key", "first_name_en_key",
+				"middle_name_th_key", "middle_name_en_key",
+				"last_name_th_key", "last_name_en_key",
+				"first_name_th_grams", "first_name_en_grams",
+				"middle_name_th_grams", "middle_name_en_grams",
+				"last_name_th_grams", "last_name_en_grams",
+				"first_name_th_bidx", "middle_name_th_bidx", "last_name_th_bidx",
+				"first_name_en_bidx", "middle_name_en_bidx", "last_name_en_bidx",
+				"date_of_birth", "national_id", "passport_id",
+				"phone_number", "email", "national_id_bidx", "passport_id_bidx",
+				"phone_number_bidx", "email_bidx", "gender", "updated_at",
+			}),
+		}).Create(sealed).Error
+	})
+	if err != nil {
+		return translateError("patients", err)
+	}
+	p.ID, p.CreatedAt, p.UpdatedAt = sealed.ID, sealed.CreatedAt, sealed.UpdatedAt
+	return nil
+}
+
+// Search returns one keyset page of the hospital's patients matching the
+// criteria. Exact filters on encrypted columns compare blind indexes; other
+// name match modes compare the indexed trigrams of phonetic keys, and
+// first_name, middle_name and last_name always match on phonetic keys so
+// either script finds the other. Ranked modes attach a similarity score to
+// each patient. Total is only counted when requested since it costs a full
+// scan of the matching rows.
+func (repo *PatientRepository) Search(hospitalID uint, criteria PatientSearchCriteria, page PageRequest) (*PatientPage, error) {
+	var result *PatientPage
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		var err error
+		result, err = repo.search(tx.Model(&models.Patient{}).Where("hospital_id = ?", hospitalID), criteria, page)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Patients {
+		if err := repo.open(&result.Patients[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (repo *PatientRepository) search(db *gorm.DB, criteria PatientSearchCriteria, page PageRequest) (*PatientPage, error) {
+	where, scores := criteria.build(criteria.Match, repo.blindIndex, time.Now())
+	if where != nil {
+		db = db.Where(where.sql, where.args...)
+	}
+	score := sumScores(scores)
+
+	sort, err := parsePageSort(page.Sort, score != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PatientPage{Patients: []models.Patient{}}
+	if page.IncludeTotal {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if score != nil {
+		db = score.selectInto(db)
+	}
+	if page.Cursor != "" {
+		c, err := sort.decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = sort.after(db, c, score)
+	}
+
+	limit := page.limit()
+	if err := db.Order(sort.orderBy()).Limit(limit + 1).Find(&result.Patients).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Patients) > limit {
+		result.Patients = result.Patients[:limit]
+		result.NextCursor = sort.cursorFor(&result.Patients[limit-1])
+	}
+	return result, nil
+}
+
+func (repo *PatientRepository) GetByNationalID(hospitalID uint, nationalID string) (*models.Patient, error) {
+	return repo.first(hospitalID, "national_id_bidx = ?", repo.blindIndex("national_id", nationalID))
+}
+
+func (repo *PatientRepository) GetByPassportID(hospitalID uint, passportID string) (*models.Patient, error) {
+	return repo.first(hospitalID, "passport_id_bidx = ?", repo.blindIndex("passport_id", passportID))
+}
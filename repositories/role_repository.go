@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (repo *RoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	if err := repo.db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (repo *RoleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	if err := repo.db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// EnsureDefaults creates the permissions and roles in defaults and resets
+// each default role to exactly its listed permissions, so permissions added
+// in a release reach existing databases. It is safe to run on every start.
+func (repo *RoleRepository) EnsureDefaults(defaults map[string][]string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		perms := map[string]*models.Permission{}
+		for _, names := range defaults {
+			for _, name := range names {
+				if _, ok := perms[name]; ok {
+					continue
+				}
+				p := &models.Permission{Name: name}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(p).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("name = ?", name).First(p).Error; err != nil {
+					return err
+				}
+				perms[name] = p
+			}
+		}
+
+		for name, permNames := range defaults {
+			role := &models.Role{Name: name}
+			if err := tx.Where("name = ?", name).FirstOrCreate(role).Error; err != nil {
+				return err
+			}
+			rolePerms := make([]models.Permission, len(permNames))
+			for i, pn := range permNames {
+				rolePerms[i] = *perms[pn]
+			}
+			if err := tx.Model(role).Association("Permissions").Replace(rolePerms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
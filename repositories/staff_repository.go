@@ -1,9 +1,14 @@
 package repositories
 
 import (
+	"strings"
+	"time"
+
 	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/throttle"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type StaffRepository struct {
@@ -15,22 +20,234 @@ func NewStaffRepository(db *gorm.DB) *StaffRepository {
 }
 
 func (repo *StaffRepository) CreateStaff(staff *models.Staff) error {
-	return repo.db.Create(staff).Error
+	return InHospital(repo.db, staff.HospitalID, func(tx *gorm.DB) error {
+		return tx.Create(staff).Error
+	})
 }
 
 func (repo *StaffRepository) GetByUsenameAndHospital(username string, hospitalID uint) (*models.Staff, error) {
 	var staff models.Staff
-	if err := repo.db.Where("user_name = ? AND hospital_id = ?", username, hospitalID).First(&staff).Error; err != nil {
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Preload("Role.Permissions").Where("user_name = ? AND hospital_id = ?", username, hospitalID).First(&staff).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &staff, nil
 }
 
-func (repo *StaffRepository) GetByID(id uint) (*models.Staff, error) {
+func (repo *StaffRepository) GetByID(hospitalID, id uint) (*models.Staff, error) {
 	var staff models.Staff
-	if err := repo.db.First(&staff, id).Error; err != nil {
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Preload("Role.Permissions").Preload("Hospital").Where("hospital_id = ? AND id = ?", hospitalID, id).First(&staff).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &staff, nil
 }
+
+// CountByRole counts the hospital's active staff with the role.
+func (repo *StaffRepository) CountByRole(hospitalID, roleID uint) (int64, error) {
+	var n int64
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return tx.Model(&models.Staff{}).
+			Where("hospital_id = ? AND role_id = ? AND status = ?", hospitalID, roleID, models.StaffActive).
+			Count(&n).Error
+	})
+	return n, err
+}
+
+func (repo *StaffRepository) UpdateRole(hospitalID, staffID, roleID uint) error {
+	return repo.update(hospitalID, staffID, map[string]interface{}{"role_id": roleID})
+}
+
+func (repo *StaffRepository) UpdateStatus(hospitalID, staffID uint, status string) error {
+	return repo.update(hospitalID, staffID, map[string]interface{}{"status": status})
+}
+
+// update changes columns of one of the hospital's staff.
+func (repo *StaffRepository) update(hospitalID, staffID uint, updates map[string]interface{}) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		return updateStaff(tx, hospitalID, staffID, updates)
+	})
+}
+
+func updateStaff(tx *gorm.DB, hospitalID, staffID uint, updates map[string]interface{}) error {
+	return tx.Model(&models.Staff{}).Where("hospital_id = ? AND id = ?", hospitalID, staffID).Updates(updates).Error
+}
+
+// RecordLoginFailure counts a wrong password against the staff member and
+// locks the account for as long as policy demands. It returns the lock's
+// end, which is zero when the failure carries no delay.
+func (repo *StaffRepository) RecordLoginFailure(hospitalID, staffID uint, now time.Time, policy throttle.Policy) (time.Time, error) {
+	var lockedUntil time.Time
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		// Find rather than First, so RecordUnknownLoginFailure runs the
+		// same statements against no row.
+		var staff models.Staff
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_logins", "last_failed_login_at").
+			Where("hospital_id = ? AND id = ?", hospitalID, staffID).
+			Limit(1).Find(&staff).Error; err != nil {
+			return err
+		}
+
+		failures := staff.FailedLogins
+		if staff.LastFailedLoginAt == nil || policy.Expired(*staff.LastFailedLoginAt, now) {
+			failures = 0
+		}
+		failures++
+
+		updates := map[string]interface{}{"failed_logins": failures, "last_failed_login_at": now, "locked_until": nil}
+		if delay := policy.Delay(failures); delay > 0 {
+			lockedUntil = now.Add(delay)
+			updates["locked_until"] = lockedUntil
+		}
+		return updateStaff(tx, hospitalID, staffID, updates)
+	})
+	return lockedUntil, err
+}
+
+// RecordUnknownLoginFailure runs what RecordLoginFailure does against no
+// account, so a login for an unknown username costs the database as much
+// as a wrong password and response times do not tell the two apart. IDs
+// start at 1, so staff ID 0 matches nothing.
+func (repo *StaffRepository) RecordUnknownLoginFailure(hospitalID uint, now time.Time, policy throttle.Policy) error {
+	_, err := repo.RecordLoginFailure(hospitalID, 0, now, policy)
+	return err
+}
+
+// ClearLoginFailures resets the failure count and lifts any lock.
+func (repo *StaffRepository) ClearLoginFailures(hospitalID, staffID uint) error {
+	return repo.update(hospitalID, staffID, map[string]interface{}{"failed_logins": 0, "last_failed_login_at": nil, "locked_until": nil})
+}
+
+// UpdatePasswordHash replaces the hash of an unchanged password, as when it
+// is rehashed at a new bcrypt cost.
+func (repo *StaffRepository) UpdatePasswordHash(hospitalID, staffID uint, hash string) error {
+	return repo.update(hospitalID, staffID, map[string]interface{}{"password_hash": hash})
+}
+
+// StaffFilter narrows a staff listing. Query matches user names and display
+// names case-insensitively.
+type StaffFilter struct {
+	Status string
+	Role   string
+	Query  string
+}
+
+// List returns one page of the hospital's staff, ordered by id or
+// updated_at. Soft-deleted staff are left out.
+func (repo *StaffRepository) List(hospitalID uint, filter StaffFilter, page PageRequest) (*StaffPage, error) {
+	var result *StaffPage
+	err := InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		var err error
+		result, err = listStaff(tx, hospitalID, filter, page)
+		return err
+	})
+	return result, err
+}
+
+func listStaff(tx *gorm.DB, hospitalID uint, filter StaffFilter, page PageRequest) (*StaffPage, error) {
+	db := tx.Model(&models.Staff{}).Where("hospital_id = ?", hospitalID)
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		db = db.Where("role_id IN (?)", tx.Model(&models.Role{}).Select("id").Where("name = ?", filter.Role))
+	}
+	if filter.Query != "" {
+		like := "%" + escapeLike(strings.ToLower(filter.Query)) + "%"
+		db = db.Where("(LOWER(user_name) LIKE ? OR LOWER(display_name) LIKE ?)", like, like)
+	}
+
+	sort, err := parsePageSort(page.Sort, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StaffPage{Staff: []models.Staff{}}
+	if page.IncludeTotal {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if page.Cursor != "" {
+		c, err := sort.decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = sort.after(db, c, nil)
+	}
+
+	limit := page.limit()
+	if err := db.Preload("Role").Preload("Hospital").Order(sort.orderBy()).Limit(limit + 1).Find(&result.Staff).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Staff) > limit {
+		result.Staff = result.Staff[:limit]
+		last := &result.Staff[limit-1]
+		result.NextCursor = sort.cursorAt(last.ID, last.UpdatedAt, nil)
+	}
+	return result, nil
+}
+
+// StaffProfile changes a staff member's profile. Nil fields are left as
+// they are.
+type StaffProfile struct {
+	DisplayName   *string
+	LicenseNumber *string
+	Department    *string
+}
+
+func (repo *StaffRepository) UpdateProfile(hospitalID, staffID uint, profile StaffProfile) error {
+	updates := map[string]interface{}{}
+	if profile.DisplayName != nil {
+		updates["display_name"] = *profile.DisplayName
+	}
+	if profile.LicenseNumber != nil {
+		updates["license_number"] = *profile.LicenseNumber
+	}
+	if profile.Department != nil {
+		updates["department"] = *profile.Department
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return repo.update(hospitalID, staffID, updates)
+}
+
+// Disable blocks the staff member from logging in and ends their sessions.
+// Access tokens are refused from the next request on, since JWTAuth checks
+// the account's status.
+func (repo *StaffRepository) Disable(hospitalID, staffID uint, now time.Time) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		if err := updateStaff(tx, hospitalID, staffID, map[string]interface{}{"status": models.StaffDisabled}); err != nil {
+			return err
+		}
+		return revokeStaffSessions(tx, staffID, now)
+	})
+}
+
+// SoftDelete hides the staff member and ends their sessions. The row is kept
+// so audit history still resolves.
+func (repo *StaffRepository) SoftDelete(hospitalID, staffID uint, now time.Time) error {
+	return InHospital(repo.db, hospitalID, func(tx *gorm.DB) error {
+		if err := revokeStaffSessions(tx, staffID, now); err != nil {
+			return err
+		}
+		return tx.Where("hospital_id = ? AND id = ?", hospitalID, staffID).Delete(&models.Staff{}).Error
+	})
+}
+
+func revokeStaffSessions(tx *gorm.DB, staffID uint, now time.Time) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("staff_id = ? AND revoked_at IS NULL", staffID).
+		Update("revoked_at", now).Error
+}
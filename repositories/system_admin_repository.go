@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+)
+
+type SystemAdminRepository struct {
+	db *gorm.DB
+}
+
+func NewSystemAdminRepository(db *gorm.DB) *SystemAdminRepository {
+	return &SystemAdminRepository{db: db}
+}
+
+func (repo *SystemAdminRepository) Create(admin *models.SystemAdmin) error {
+	return translateError("system_admins", repo.db.Create(admin).Error)
+}
+
+func (repo *SystemAdminRepository) GetByID(id uint) (*models.SystemAdmin, error) {
+	var admin models.SystemAdmin
+	if err := repo.db.First(&admin, id).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (repo *SystemAdminRepository) FindByUserName(userName string) (*models.SystemAdmin, error) {
+	var admin models.SystemAdmin
+	if err := repo.db.Where("user_name = ?", userName).First(&admin).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (repo *SystemAdminRepository) Count() (int64, error) {
+	var n int64
+	err := repo.db.Model(&models.SystemAdmin{}).Count(&n).Error
+	return n, err
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// InHospital runs fn in a transaction scoped to one hospital through the
+// app.hospital_id setting. Row-level security on patients and staffs then
+// hides the other hospitals' rows from fn and refuses writes to them, even
+// from a query that forgets its hospital_id filter. Every query in fn must
+// go through tx: the setting only exists in the transaction's connection, so
+// a query on db would run unscoped, and see nothing, on another connection.
+//
+// Queries on patients and staffs outside InHospital and AllHospitals see no
+// rows and cannot write any.
+func InHospital(db *gorm.DB, hospitalID uint, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// set_config with is_local = true lasts until the transaction ends,
+		// so the scope never leaks to the next user of the connection.
+		err := tx.Exec("SELECT set_config('app.hospital_id', ?, true)", strconv.FormatUint(uint64(hospitalID), 10)).Error
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// AllHospitals runs fn in a transaction that row-level security lets see
+// and change every hospital's patients and staff, through the
+// app.all_hospitals setting. It is for jobs that belong to no single
+// hospital, such as re-encrypting patients; request handling always goes
+// through InHospital.
+func AllHospitals(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('app.all_hospitals', 'on', true)").Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
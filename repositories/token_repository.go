@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"agnos_candidate_assignment/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRefreshTokenReused is returned by Rotate when the token was already
+// exchanged, including by a concurrent request that won the race.
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (repo *TokenRepository) CreateRefresh(rt *models.RefreshToken) error {
+	return repo.db.Create(rt).Error
+}
+
+func (repo *TokenRepository) FindRefresh(tokenHash string) (*models.RefreshToken, error) {
+	var rt models.RefreshToken
+	if err := repo.db.Where("token_hash = ?", tokenHash).First(&rt).Error; err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// Rotate marks old used and stores next in one transaction. Only the first
+// caller can mark a token used, so one refresh token yields one successor.
+func (repo *TokenRepository) Rotate(old, next *models.RefreshToken, now time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", old.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (repo *TokenRepository) RevokeFamily(familyID string, now time.Time) error {
+	return repo.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// Deny adds an access token's jti to the denylist.
+func (repo *TokenRepository) Deny(jti string, expiresAt time.Time) error {
+	return repo.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (repo *TokenRepository) IsDenied(jti string) (bool, error) {
+	var n int64
+	err := repo.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&n).Error
+	return n > 0, err
+}
+
+// PurgeExpired deletes denylist entries and refresh tokens past their
+// expiry; neither can be presented successfully any more.
+func (repo *TokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	var total int64
+	for _, m := range []interface{}{&models.RevokedToken{}, &models.RefreshToken{}} {
+		res := repo.db.Where("expires_at < ?", now).Delete(m)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
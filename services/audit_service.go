@@ -0,0 +1,79 @@
+package services
+
+import (
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+// auditVerifyBatch is how many entries Verify reads at a time.
+const auditVerifyBatch = 500
+
+type AuditService struct {
+	AuditRepo repositories.AuditRepositoryInterface
+}
+
+func NewAuditService(auditRepo repositories.AuditRepositoryInterface) *AuditService {
+	return &AuditService{AuditRepo: auditRepo}
+}
+
+// AuditVerification is the result of checking a hospital's audit chain.
+// When Valid is false, BrokenAt is the sequence number of the first entry
+// that does not link up, and Reason says why.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Record appends entry to its hospital's audit chain.
+func (auditService *AuditService) Record(entry *models.AuditLog) error {
+	return auditService.AuditRepo.Append(entry)
+}
+
+// List returns one page of the hospital's audit entries.
+func (auditService *AuditService) List(hospitalID uint, filter repositories.AuditFilter, page repositories.PageRequest) (*repositories.AuditPage, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &ValidationError{Message: "from must be before to"}
+	}
+	return auditService.AuditRepo.List(hospitalID, filter, page)
+}
+
+// Verify walks the hospital's audit chain from the start, recomputing every
+// hash. It stops at the first entry that was altered, is missing or is out
+// of place.
+func (auditService *AuditService) Verify(hospitalID uint) (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	var seq int64
+	prevHash := ""
+	for {
+		entries, err := auditService.AuditRepo.Chain(hospitalID, seq, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			reason := ""
+			switch {
+			case entry.Seq != seq+1:
+				reason = "entry missing before this one"
+			case entry.PrevHash != prevHash:
+				reason = "previous hash does not match"
+			case entry.Hash != entry.ComputeHash():
+				reason = "entry content does not match its hash"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAt = &entry.Seq
+				result.Reason = reason
+				return result, nil
+			}
+			seq = entry.Seq
+			prevHash = entry.Hash
+			result.Checked++
+		}
+		if len(entries) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// TOTPIssuer names the account in authenticator apps.
+	TOTPIssuer = "Agnos"
+
+	mfaPurposeVerify = "mfa"
+	mfaPurposeEnroll = "mfa_enroll"
+
+	mfaChallengeTTL   = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrMFAChallengeInvalid = errors.New("MFA challenge is invalid or expired; log in again")
+	ErrMFACodeInvalid      = errors.New("invalid MFA code")
+	ErrMFANotEnrolling     = errors.New("no MFA enrollment in progress")
+	ErrMFAAlreadyEnabled   = errors.New("MFA is already enabled")
+	ErrMFARequired         = errors.New("hospital requires MFA")
+)
+
+// MFAEnrollment is what an authenticator app needs to add the account.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type mfaChallengeClaims struct {
+	StaffID uint   `json:"staff_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// mfaFailure counts wrong codes against one challenge. The count is kept in
+// memory, so each instance allows mfaMaxAttempts per challenge; the staff
+// member's login failures, kept in the database, bound them overall.
+type mfaFailure struct {
+	count     int
+	expiresAt time.Time
+}
+
+func (auth *AuthService) issueMFAChallenge(staff *models.Staff, purpose string, now time.Time) (string, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return auth.Keys.Sign(mfaChallengeClaims{
+		StaffID: staff.ID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    auth.conf.JWTIssuer,
+			Audience:  jwt.ClaimStrings{auth.conf.MFAAudience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		},
+	})
+}
+
+// challengeStaff verifies an MFA challenge issued for the hospital and loads
+// its staff member.
+func (auth *AuthService) challengeStaff(hospitalName, mfaToken string) (*mfaChallengeClaims, *models.Staff, error) {
+	claims := &mfaChallengeClaims{}
+	token, err := jwt.ParseWithClaims(mfaToken, claims, auth.Keys.Keyfunc,
+		jwt.WithValidMethods(auth.Keys.Algorithms()),
+		jwt.WithIssuer(auth.conf.JWTIssuer),
+		jwt.WithAudience(auth.conf.MFAAudience()),
+		jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims.ID == "" {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+	if denied, err := auth.TokenRepo.IsDenied(claims.ID); err != nil {
+		return nil, nil, err
+	} else if denied {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+
+	hospital, err := auth.HospitalRepo.FindByName(hospitalName)
+	if err != nil {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+	staff, err := auth.StaffRepo.GetByID(hospital.ID, claims.StaffID)
+	if err != nil || staff.Status != models.StaffActive {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+	if !staff.Hospital.Active() {
+		return nil, nil, ErrHospitalInactive
+	}
+	if now := time.Now(); staff.LockedUntil != nil && now.Before(*staff.LockedUntil) {
+		return nil, nil, &LoginThrottledError{RetryAfter: staff.LockedUntil.Sub(now)}
+	}
+	return claims, staff, nil
+}
+
+// StartMFAEnrollmentWithChallenge begins enrollment for a staff member whose
+// login was held back because the hospital requires MFA.
+func (auth *AuthService) StartMFAEnrollmentWithChallenge(hospitalName, mfaToken string) (*MFAEnrollment, error) {
+	claims, staff, err := auth.challengeStaff(hospitalName, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != mfaPurposeEnroll || staff.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return auth.startEnrollment(staff)
+}
+
+// CompleteMFALogin finishes a login held back for MFA. code is a TOTP code,
+// or a recovery code for an enrolled staff member. For an enrollment
+// challenge the code must come from the new secret; the enrollment is then
+// enabled and the result carries the recovery codes.
+func (auth *AuthService) CompleteMFALogin(hospitalName, mfaToken, code string) (*LoginResult, error) {
+	claims, staff, err := auth.challengeStaff(hospitalName, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &LoginResult{Staff: staff}
+	switch claims.Purpose {
+	case mfaPurposeVerify:
+		if !staff.MFAEnabled {
+			return nil, ErrMFAChallengeInvalid
+		}
+		ok, err := auth.checkSecondFactor(staff, code, now)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, auth.recordMFAFailure(claims, staff, now)
+		}
+	case mfaPurposeEnroll:
+		if staff.MFAEnabled || staff.TOTPSecret == nil {
+			return nil, ErrMFANotEnrolling
+		}
+		codes, ok, err := auth.enable(staff, code, now)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, auth.recordMFAFailure(claims, staff, now)
+		}
+		result.RecoveryCodes = codes
+	default:
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	// A challenge completes one login only.
+	if err := auth.TokenRepo.Deny(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+	auth.clearMFAFailures(claims.ID)
+	if staff.FailedLogins > 0 {
+		if err := auth.StaffRepo.ClearLoginFailures(staff.HospitalID, staff.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Tokens, err = auth.issueSession(staff, now); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StartMFAEnrollment issues a new secret for a logged-in staff member. It
+// replaces any enrollment that was started but not confirmed.
+func (auth *AuthService) StartMFAEnrollment(hospitalID, staffID uint) (*MFAEnrollment, error) {
+	staff, err := auth.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil {
+		return nil, ErrStaffNotFound
+	}
+	if staff.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return auth.startEnrollment(staff)
+}
+
+// ConfirmMFAEnrollment enables MFA once code proves the authenticator holds
+// the secret, and returns the recovery codes. They are shown only once.
+func (auth *AuthService) ConfirmMFAEnrollment(hospitalID, staffID uint, code string) ([]string, error) {
+	staff, err := auth.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil {
+		return nil, ErrStaffNotFound
+	}
+	if staff.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if staff.TOTPSecret == nil {
+		return nil, ErrMFANotEnrolling
+	}
+	codes, ok, err := auth.enable(staff, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMFACodeInvalid
+	}
+	return codes, nil
+}
+
+// DisableMFA turns MFA off after checking a current code. Staff of a
+// hospital that requires MFA cannot disable it.
+func (auth *AuthService) DisableMFA(hospitalID, staffID uint, code string) error {
+	staff, err := auth.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil {
+		return ErrStaffNotFound
+	}
+	if staff.Hospital.RequireMFA {
+		return ErrMFARequired
+	}
+	if !staff.MFAEnabled {
+		return ErrMFANotEnrolling
+	}
+	now := time.Now()
+	ok, err := auth.checkSecondFactor(staff, code, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := auth.StaffRepo.RecordLoginFailure(staff.HospitalID, staff.ID, now, auth.accountPolicy); err != nil {
+			return err
+		}
+		return ErrMFACodeInvalid
+	}
+	return auth.MFARepo.Disable(staff.HospitalID, staff.ID)
+}
+
+func (auth *AuthService) startEnrollment(staff *models.Staff) (*MFAEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.MFARepo.SetPendingSecret(staff.HospitalID, staff.ID, secret); err != nil {
+		return nil, err
+	}
+	account := staff.UserName + "@" + staff.Hospital.Name
+	return &MFAEnrollment{Secret: secret, URI: totp.URI(TOTPIssuer, account, secret)}, nil
+}
+
+// enable verifies code against the pending secret and, if it matches, turns
+// MFA on with a fresh set of recovery codes.
+func (auth *AuthService) enable(staff *models.Staff, code string, now time.Time) ([]string, bool, error) {
+	secret, err := auth.MFARepo.Secret(staff)
+	if err != nil {
+		return nil, false, err
+	}
+	step, ok := totp.Validate(secret, code, now, 1)
+	if !ok {
+		return nil, false, nil
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := newRecoveryCode()
+		if err != nil {
+			return nil, false, err
+		}
+		codes[i] = c
+		hashes[i] = HashToken(normalizeRecoveryCode(c))
+	}
+	if err := auth.MFARepo.Enable(staff.HospitalID, staff.ID, step, hashes); err != nil {
+		return nil, false, err
+	}
+	staff.MFAEnabled = true
+	return codes, true, nil
+}
+
+// checkSecondFactor accepts a TOTP code newer than the last one used, or an
+// unused recovery code, which it consumes.
+func (auth *AuthService) checkSecondFactor(staff *models.Staff, code string, now time.Time) (bool, error) {
+	if staff.TOTPSecret != nil {
+		secret, err := auth.MFARepo.Secret(staff)
+		if err != nil {
+			return false, err
+		}
+		if step, ok := totp.Validate(secret, code, now, 1); ok {
+			return auth.MFARepo.AdvanceStep(staff.HospitalID, staff.ID, step)
+		}
+	}
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return false, nil
+	}
+	return auth.MFARepo.UseRecoveryCode(staff.ID, HashToken(normalized), now)
+}
+
+// recordMFAFailure counts a wrong code against the staff member's login
+// failures, which lock the account across challenges and instances just as
+// wrong passwords do, and against the challenge itself.
+func (auth *AuthService) recordMFAFailure(claims *mfaChallengeClaims, staff *models.Staff, now time.Time) error {
+	lockedUntil, err := auth.StaffRepo.RecordLoginFailure(staff.HospitalID, staff.ID, now, auth.accountPolicy)
+	if err != nil {
+		return err
+	}
+	if err := auth.recordChallengeFailure(claims, now); err != nil {
+		return err
+	}
+	if lockedUntil.After(now) {
+		return &LoginThrottledError{RetryAfter: lockedUntil.Sub(now)}
+	}
+	return ErrMFACodeInvalid
+}
+
+// recordChallengeFailure revokes a challenge after mfaMaxAttempts wrong
+// codes.
+func (auth *AuthService) recordChallengeFailure(claims *mfaChallengeClaims, now time.Time) error {
+	auth.mfaMu.Lock()
+	for jti, f := range auth.mfaFailures {
+		if now.After(f.expiresAt) {
+			delete(auth.mfaFailures, jti)
+		}
+	}
+	f := auth.mfaFailures[claims.ID]
+	f.count++
+	f.expiresAt = claims.ExpiresAt.Time
+	auth.mfaFailures[claims.ID] = f
+	auth.mfaMu.Unlock()
+
+	if f.count >= mfaMaxAttempts {
+		auth.clearMFAFailures(claims.ID)
+		if err := auth.TokenRepo.Deny(claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+		return ErrMFAChallengeInvalid
+	}
+	return nil
+}
+
+func (auth *AuthService) clearMFAFailures(jti string) {
+	auth.mfaMu.Lock()
+	delete(auth.mfaFailures, jti)
+	auth.mfaMu.Unlock()
+}
+
+// recoveryCodeLength is the number of base32 characters in a recovery code,
+// 50 bits, shown as two groups of five.
+const recoveryCodeLength = 10
+
+func newRecoveryCode() (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	return secret[:5] + "-" + secret[5:recoveryCodeLength], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
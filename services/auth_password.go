@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+// PasswordResetTTL is how long an admin-issued reset token works.
+const PasswordResetTTL = 24 * time.Hour
+
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+// ChangePassword replaces the staff member's password after checking the
+// current one, which is throttled like a login. Every existing session ends;
+// the caller gets a fresh token pair.
+func (auth *AuthService) ChangePassword(hospitalID, staffID uint, oldPassword, newPassword string) (*TokenPair, *models.Staff, error) {
+	staff, err := auth.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil {
+		return nil, nil, ErrStaffNotFound
+	}
+
+	now := time.Now()
+	if staff.LockedUntil != nil && now.Before(*staff.LockedUntil) {
+		return nil, nil, &LoginThrottledError{RetryAfter: staff.LockedUntil.Sub(now)}
+	}
+	if err := auth.CheckPasswordHash(oldPassword, staff.PasswordHash); err != nil {
+		if _, err := auth.StaffRepo.RecordLoginFailure(staff.HospitalID, staff.ID, now, auth.accountPolicy); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrWrongPassword
+	}
+	if newPassword == oldPassword {
+		return nil, nil, &ValidationError{Message: "new password must differ from the current one"}
+	}
+
+	if err := auth.setPassword(staff, newPassword, now, nil); err != nil {
+		return nil, nil, err
+	}
+	pair, err := auth.issueSession(staff, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, staff, nil
+}
+
+// ResetPassword sets a new password with a reset token issued by a hospital
+// admin. The token works once, and every existing session ends.
+func (auth *AuthService) ResetPassword(hospitalName, resetToken, newPassword string) error {
+	hospital, err := auth.HospitalRepo.FindByName(hospitalName)
+	if err != nil {
+		return repositories.ErrResetTokenInvalid
+	}
+	now := time.Now()
+	token, err := auth.PasswordRepo.FindReset(hospital.ID, HashToken(resetToken), now)
+	if err != nil {
+		return err
+	}
+	return auth.setPassword(&token.Staff, newPassword, now, token)
+}
+
+// setPassword checks newPassword against the policy and stores it, redeeming
+// reset when one is given.
+func (auth *AuthService) setPassword(staff *models.Staff, newPassword string, now time.Time, reset *models.PasswordResetToken) error {
+	if err := auth.policy.Check(newPassword, staff.UserName); err != nil {
+		return &ValidationError{Message: err.Error()}
+	}
+	hash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if reset != nil {
+		err = auth.PasswordRepo.RedeemReset(staff.HospitalID, reset, hash, now)
+	} else {
+		err = auth.PasswordRepo.SetPassword(staff.HospitalID, staff.ID, hash, now)
+	}
+	if err != nil {
+		return err
+	}
+	staff.PasswordHash = hash
+	staff.PasswordChangedAt = &now
+	return nil
+}
+
+// rehashIfNeeded upgrades a verified password's hash to the configured
+// bcrypt cost. Failing to do so does not fail the login.
+func (auth *AuthService) rehashIfNeeded(staff *models.Staff, password string) {
+	if !auth.hasher.NeedsRehash(staff.PasswordHash) {
+		return
+	}
+	hash, err := auth.HashPassword(password)
+	if err == nil {
+		err = auth.StaffRepo.UpdatePasswordHash(staff.HospitalID, staff.ID, hash)
+	}
+	if err != nil {
+		log.Printf("rehashing password of staff %d: %v", staff.ID, err)
+		return
+	}
+	staff.PasswordHash = hash
+}
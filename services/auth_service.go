@@ -2,97 +2,439 @@ package services
 
 import (
 	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/keyset"
 	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
 	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/throttle"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvitationRequired = errors.New("an invitation is required to register")
+	ErrUsernameTaken      = errors.New("Username already exists in this hospital")
+	ErrStaffPending       = errors.New("account is awaiting admin approval")
+	ErrStaffDisabled      = errors.New("account is disabled")
+	ErrHospitalInactive   = errors.New("hospital is deactivated")
+
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all sessions from that login are revoked")
+)
+
+// Wrong passwords cost nothing until these many in a row, per account and
+// per client IP; after that each one doubles the wait.
+const (
+	accountFreeAttempts = 3
+	ipFreeAttempts      = 10
+)
+
+// LoginThrottledError refuses a login attempt until RetryAfter has passed,
+// after too many wrong passwords for the account or from the client.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts; try again later"
+}
+
 type AuthService struct {
-	StaffRepo    *repositories.StaffRepository
-	HospitalRepo *repositories.HospitalRepository
-	conf         *config.Config
+	StaffRepo      *repositories.StaffRepository
+	HospitalRepo   *repositories.HospitalRepository
+	InvitationRepo *repositories.InvitationRepository
+	TokenRepo      *repositories.TokenRepository
+	MFARepo        *repositories.MFARepository
+	PasswordRepo   *repositories.PasswordRepository
+	Keys           *keyset.Set
+	conf           *config.Config
+
+	policy    *password.Policy
+	hasher    password.Hasher
+	dummyHash string
+
+	mfaMu       sync.Mutex
+	mfaFailures map[string]mfaFailure
+
+	accountPolicy   throttle.Policy
+	ipThrottle      *throttle.Tracker
+	unknownThrottle *throttle.Tracker
 }
 
-func NewAuthService(staffRepo *repositories.StaffRepository, hospitalRepo *repositories.HospitalRepository, conf *config.Config) *AuthService {
+func NewAuthService(staffRepo *repositories.StaffRepository, hospitalRepo *repositories.HospitalRepository, invitationRepo *repositories.InvitationRepository, tokenRepo *repositories.TokenRepository, mfaRepo *repositories.MFARepository, passwordRepo *repositories.PasswordRepository, policy *password.Policy, keys *keyset.Set, conf *config.Config) *AuthService {
+	hasher := password.Hasher{Cost: conf.BcryptCost}
+	// Compared against for unknown usernames, so those take as long as a
+	// wrong password.
+	dummyHash, _ := hasher.Hash("unknown user")
+	accountPolicy := throttle.Policy{FreeAttempts: accountFreeAttempts, MaxFailures: conf.LoginMaxFailures, Lockout: conf.LoginLockout}
 	return &AuthService{
-		StaffRepo:    staffRepo,
-		HospitalRepo: hospitalRepo,
-		conf:         conf,
+		StaffRepo:      staffRepo,
+		HospitalRepo:   hospitalRepo,
+		InvitationRepo: invitationRepo,
+		TokenRepo:      tokenRepo,
+		MFARepo:        mfaRepo,
+		PasswordRepo:   passwordRepo,
+		Keys:           keys,
+		conf:           conf,
+		mfaFailures:    map[string]mfaFailure{},
+
+		policy:    policy,
+		hasher:    hasher,
+		dummyHash: dummyHash,
+
+		accountPolicy:   accountPolicy,
+		ipThrottle:      throttle.NewTracker(throttle.Policy{FreeAttempts: ipFreeAttempts, MaxFailures: conf.LoginIPMaxFailures, Lockout: conf.LoginLockout}),
+		unknownThrottle: throttle.NewTracker(accountPolicy),
 	}
 }
 
 func (auth *AuthService) HashPassword(password string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
+	return auth.hasher.Hash(password)
 }
 
 func (auth *AuthService) CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return auth.hasher.Compare(hash, password)
 }
 
-func (auth *AuthService) Register(hospitalName, userName, password string) (*models.Staff, error) {
+// Register creates a staff account from an invitation; the account gets the
+// invitation's role. A hospital's first admin registers with an invitation
+// issued by a system admin, later staff with one issued by the hospital's
+// admins. When the hospital requires approval, accounts invited by its admins
+// start pending and cannot log in until approved. Redeem marks the invitation
+// used and creates the account in one transaction, so an invitation makes at
+// most one account even when registrations race.
+func (auth *AuthService) Register(hospitalName, userName, password, invitationToken string) (*models.Staff, error) {
 	hospital, err := auth.HospitalRepo.FindByName(hospitalName)
 	if err != nil {
-		return nil, errors.New("Hospital not found")
+		return nil, err
+	}
+	if !hospital.Active() {
+		return nil, ErrHospitalInactive
+	}
+
+	if invitationToken == "" {
+		return nil, ErrInvitationRequired
 	}
 
 	if _, err := auth.StaffRepo.GetByUsenameAndHospital(userName, hospital.ID); err == nil {
-		return nil, errors.New("Username already exists in this hospital")
+		return nil, ErrUsernameTaken
 	}
 
+	now := time.Now()
+	invitation, err := auth.InvitationRepo.FindUsable(hospital.ID, HashToken(invitationToken), now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auth.policy.Check(password, userName); err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
 	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
-	staff := &models.Staff{UserName: userName, PasswordHash: hashedPassword, HospitalID: hospital.ID}
-	if err := auth.StaffRepo.CreateStaff(staff); err != nil {
+	staff := &models.Staff{UserName: userName, PasswordHash: hashedPassword, HospitalID: hospital.ID, Status: models.StaffActive}
+
+	staff.RoleID = invitation.RoleID
+	if hospital.RequireStaffApproval && invitation.CreatedByAdminID == nil {
+		staff.Status = models.StaffPending
+	}
+	if err := auth.InvitationRepo.Redeem(invitation, staff, now); err != nil {
+		var dup *repositories.DuplicateKeyError
+		if errors.As(err, &dup) {
+			return nil, ErrUsernameTaken
+		}
 		return nil, err
 	}
+	staff.Role = invitation.Role
 	return staff, nil
 }
 
+// HashToken returns the hex SHA-256 under which single-use tokens are stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 type StaffClaims struct {
 	StaffID    uint
 	HospitalID uint
 	jwt.RegisteredClaims
 }
 
-func (auth *AuthService) Login(hospitalName, username, password string) (string, *models.Staff, error) {
+// TokenPair is what a staff login or refresh hands out: a short-lived access
+// token for the API and a single-use refresh token that renews it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// LoginResult is the outcome of a password login: either Tokens, or an
+// MFAToken challenge to finish with CompleteMFALogin. EnrollmentRequired
+// means the hospital requires MFA and the staff member has not enrolled, so
+// the challenge first goes through StartMFAEnrollmentWithChallenge.
+// RecoveryCodes is only set when a login completed an enrollment.
+type LoginResult struct {
+	Tokens             *TokenPair
+	Staff              *models.Staff
+	MFAToken           string
+	EnrollmentRequired bool
+	RecoveryCodes      []string
+}
+
+// Login checks a staff member's password. Wrong passwords are throttled per
+// account and per client IP, and an unknown username costs the same bcrypt
+// comparison and failure-recording transaction as a wrong password for a
+// known one, so response times do not reveal which usernames exist.
+func (auth *AuthService) Login(hospitalName, username, password, clientIP string) (*LoginResult, error) {
 	hospital, err := auth.HospitalRepo.FindByName(hospitalName)
 	if err != nil {
-		return "", nil, errors.New("Hospital not found")
+		return nil, err
+	}
+	if !hospital.Active() {
+		return nil, ErrHospitalInactive
+	}
+
+	now := time.Now()
+	if wait := auth.ipThrottle.Wait(clientIP, now); wait > 0 {
+		return nil, &LoginThrottledError{RetryAfter: wait}
 	}
 
 	staff, err := auth.StaffRepo.GetByUsenameAndHospital(username, hospital.ID)
 	if err != nil {
-		return "", nil, errors.New("Invalid username or password")
+		// Unknown usernames are throttled like real accounts, so a lockout
+		// does not confirm that an account exists either.
+		key := strconv.FormatUint(uint64(hospital.ID), 10) + ":" + username
+		if wait := auth.unknownThrottle.Wait(key, now); wait > 0 {
+			auth.ipThrottle.Fail(clientIP, now)
+			return nil, &LoginThrottledError{RetryAfter: wait}
+		}
+		_ = auth.CheckPasswordHash(password, auth.dummyHash)
+		auth.unknownThrottle.Fail(key, now)
+		auth.ipThrottle.Fail(clientIP, now)
+		if err := auth.StaffRepo.RecordUnknownLoginFailure(hospital.ID, now, auth.accountPolicy); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if staff.LockedUntil != nil && now.Before(*staff.LockedUntil) {
+		auth.ipThrottle.Fail(clientIP, now)
+		return nil, &LoginThrottledError{RetryAfter: staff.LockedUntil.Sub(now)}
 	}
 
 	if err := auth.CheckPasswordHash(password, staff.PasswordHash); err != nil {
-		return "", nil, errors.New("Invalid username or password")
+		auth.ipThrottle.Fail(clientIP, now)
+		if _, err := auth.StaffRepo.RecordLoginFailure(staff.HospitalID, staff.ID, now, auth.accountPolicy); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	auth.rehashIfNeeded(staff, password)
+
+	switch staff.Status {
+	case models.StaffPending:
+		return nil, ErrStaffPending
+	case models.StaffDisabled:
+		return nil, ErrStaffDisabled
+	}
+
+	if staff.MFAEnabled || hospital.RequireMFA {
+		purpose := mfaPurposeVerify
+		if !staff.MFAEnabled {
+			purpose = mfaPurposeEnroll
+		}
+		challenge, err := auth.issueMFAChallenge(staff, purpose, now)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{Staff: staff, MFAToken: challenge, EnrollmentRequired: purpose == mfaPurposeEnroll}, nil
+	}
+
+	// With MFA, failures are only cleared once the second factor passes,
+	// so wrong codes add up across challenges.
+	if staff.FailedLogins > 0 {
+		if err := auth.StaffRepo.ClearLoginFailures(staff.HospitalID, staff.ID); err != nil {
+			return nil, err
+		}
+	}
+	pair, err := auth.issueSession(staff, now)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair, Staff: staff}, nil
+}
+
+// issueSession starts a refresh token family and returns its first token
+// with an access token.
+func (auth *AuthService) issueSession(staff *models.Staff, now time.Time) (*TokenPair, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	refresh, rt, err := auth.newRefreshToken(staff.ID, familyID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.TokenRepo.CreateRefresh(rt); err != nil {
+		return nil, err
+	}
+
+	pair, err := auth.IssueAccessToken(staff, now)
+	if err != nil {
+		return nil, err
+	}
+	pair.RefreshToken = refresh
+	return pair, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The access token is
+// built from the staff member's current role, so permission changes apply at
+// the next refresh. Presenting a token that was already exchanged revokes
+// every token descended from the same login.
+func (auth *AuthService) Refresh(hospitalName, refreshToken string) (*TokenPair, *models.Staff, error) {
+	now := time.Now()
+	rt, err := auth.TokenRepo.FindRefresh(HashToken(refreshToken))
+	if err != nil || rt.RevokedAt != nil || !rt.ExpiresAt.After(now) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if rt.UsedAt != nil {
+		if err := auth.TokenRepo.RevokeFamily(rt.FamilyID, now); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	hospital, err := auth.HospitalRepo.FindByName(hospitalName)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	staff, err := auth.StaffRepo.GetByID(hospital.ID, rt.StaffID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if !staff.Hospital.Active() {
+		return nil, nil, ErrHospitalInactive
+	}
+	if staff.Status != models.StaffActive {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	// Sessions from before the hospital required MFA end at their next
+	// refresh, sending the staff member through enrollment.
+	if staff.Hospital.RequireMFA && !staff.MFAEnabled {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	refresh, next, err := auth.newRefreshToken(staff.ID, rt.FamilyID, now)
+	if err != nil {
+		return nil, nil, err
 	}
+	if err := auth.TokenRepo.Rotate(rt, next, now); errors.Is(err, repositories.ErrRefreshTokenReused) {
+		if err := auth.TokenRepo.RevokeFamily(rt.FamilyID, now); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := auth.IssueAccessToken(staff, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	pair.RefreshToken = refresh
+	return pair, staff, nil
+}
 
+// Logout denylists the calling access token until it expires and, when the
+// caller hands in its refresh token, revokes that token's family so the
+// session cannot be renewed.
+func (auth *AuthService) Logout(staffID uint, jti string, accessExpiresAt time.Time, refreshToken string) error {
 	now := time.Now()
+	if refreshToken != "" {
+		rt, err := auth.TokenRepo.FindRefresh(HashToken(refreshToken))
+		if err != nil || rt.StaffID != staffID {
+			return ErrInvalidRefreshToken
+		}
+		if err := auth.TokenRepo.RevokeFamily(rt.FamilyID, now); err != nil {
+			return err
+		}
+	}
+	if jti == "" {
+		return nil
+	}
+	return auth.TokenRepo.Deny(jti, accessExpiresAt)
+}
+
+// IssueAccessToken signs an access token for the staff member carrying
+// their role's permissions. It starts no session; logins go through
+// issueSession, and the CLI uses it to mint tokens for local testing.
+func (auth *AuthService) IssueAccessToken(staff *models.Staff, now time.Time) (*TokenPair, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(auth.conf.AccessTokenTTL)
 	claims := jwt.MapClaims{
 		"staff_id":    staff.ID,
 		"hospital_id": staff.HospitalID,
+		"jti":         jti,
+		"iss":         auth.conf.JWTIssuer,
+		"aud":         auth.conf.JWTAudience,
 		"iat":         now.Unix(),
-		"exp":         now.Add(24 * time.Hour).Unix(),
+		"exp":         expiresAt.Unix(),
+	}
+	if staff.Role != nil {
+		claims["role"] = staff.Role.Name
+		claims["perms"] = staff.Role.PermissionNames()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(auth.conf.JwtSecret))
+	signedToken, err := auth.Keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: signedToken, ExpiresAt: expiresAt}, nil
+}
+
+func (auth *AuthService) newRefreshToken(staffID uint, familyID string, now time.Time) (string, *models.RefreshToken, error) {
+	token, err := randomToken(32)
 	if err != nil {
 		return "", nil, err
 	}
+	return token, &models.RefreshToken{
+		StaffID:   staffID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(auth.conf.RefreshTokenTTL),
+	}, nil
+}
 
-	return signedToken, staff, nil
+// randomToken returns n random bytes as unpadded base64url, for tokens handed
+// to clients.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// randomHex returns n random bytes hex encoded, for identifiers.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,25 @@
+package services
+
+import "errors"
+
+var ErrPatientNotFound = errors.New("patient not found")
+
+// ValidationError is returned for input the caller must fix; handlers map
+// it to 400.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ConflictError is returned when a write would duplicate a unique field;
+// handlers map it to 409.
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return "a patient with this " + e.Field + " already exists"
+}
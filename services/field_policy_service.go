@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"agnos_candidate_assignment/masking"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+// clinicalDefaults masks what treating a patient does not need: identity
+// documents and contact details. Names and birth dates stay in full so
+// staff can tell patients apart.
+var clinicalDefaults = masking.Policy{
+	"national_id":  masking.Mask,
+	"passport_id":  masking.Mask,
+	"phone_number": masking.Mask,
+	"email":        masking.Mask,
+}
+
+// DefaultFieldPolicies apply to a role before any hospital rule. Clinical
+// and registration staff see identifiers and contact details masked;
+// auditors review access rather than treat patients, so they also see birth
+// dates masked. Admins see everything.
+var DefaultFieldPolicies = map[string]masking.Policy{
+	models.RoleDoctor:       clinicalDefaults,
+	models.RoleNurse:        clinicalDefaults,
+	models.RoleRegistration: clinicalDefaults,
+	models.RoleAuditor: {
+		"national_id":   masking.Mask,
+		"passport_id":   masking.Mask,
+		"phone_number":  masking.Mask,
+		"email":         masking.Mask,
+		"date_of_birth": masking.Mask,
+	},
+}
+
+// FieldPolicy is a hospital's patient field policy: its own rules by role,
+// "*" for every role, and the built-in defaults they override.
+type FieldPolicy struct {
+	Rules    map[string]masking.Policy `json:"rules"`
+	Defaults map[string]masking.Policy `json:"defaults"`
+}
+
+type FieldPolicyService struct {
+	Repo     repositories.FieldPolicyRepositoryInterface
+	RoleRepo repositories.RoleRepositoryInterface
+}
+
+func NewFieldPolicyService(repo repositories.FieldPolicyRepositoryInterface, roleRepo repositories.RoleRepositoryInterface) *FieldPolicyService {
+	return &FieldPolicyService{Repo: repo, RoleRepo: roleRepo}
+}
+
+// PolicyFor resolves what staff with role see of patients in the hospital:
+// the role's default, overridden by the hospital's "*" rules, overridden in
+// turn by its rules for the role.
+func (s *FieldPolicyService) PolicyFor(hospitalID uint, role string) (masking.Policy, error) {
+	policy := masking.Policy{}
+	for field, action := range DefaultFieldPolicies[role] {
+		policy[field] = action
+	}
+
+	rules, err := s.Repo.Rules(hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if r.Role == models.AnyRole {
+			policy[r.Field] = masking.Action(r.Action)
+		}
+	}
+	for _, r := range rules {
+		if r.Role == role && role != models.AnyRole {
+			policy[r.Field] = masking.Action(r.Action)
+		}
+	}
+	return policy, nil
+}
+
+func (s *FieldPolicyService) Get(hospitalID uint) (*FieldPolicy, error) {
+	rules, err := s.Repo.Rules(hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	policy := &FieldPolicy{Rules: map[string]masking.Policy{}, Defaults: DefaultFieldPolicies}
+	for _, r := range rules {
+		if policy.Rules[r.Role] == nil {
+			policy.Rules[r.Role] = masking.Policy{}
+		}
+		policy.Rules[r.Role][r.Field] = masking.Action(r.Action)
+	}
+	return policy, nil
+}
+
+// Replace sets the hospital's rules, replacing all earlier ones. Roles must
+// exist or be "*"; an empty set of rules leaves only the defaults.
+func (s *FieldPolicyService) Replace(hospitalID uint, rules map[string]masking.Policy) (*FieldPolicy, error) {
+	var rows []models.PatientFieldRule
+	for role, policy := range rules {
+		if role != models.AnyRole {
+			if _, err := s.RoleRepo.FindByName(role); err != nil {
+				return nil, &ValidationError{Message: fmt.Sprintf("unknown role %q", role)}
+			}
+		}
+		for field, action := range policy {
+			if !masking.IsPatientField(field) {
+				return nil, &ValidationError{Message: fmt.Sprintf("unknown field %q; policies cover %v", field, masking.PatientFields())}
+			}
+			if !action.Valid() {
+				return nil, &ValidationError{Message: fmt.Sprintf("invalid action %q for %s; use full, mask or omit", action, field)}
+			}
+			rows = append(rows, models.PatientFieldRule{Role: role, Field: field, Action: string(action)})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Role != rows[j].Role {
+			return rows[i].Role < rows[j].Role
+		}
+		return rows[i].Field < rows[j].Field
+	})
+
+	if err := s.Repo.ReplaceRules(hospitalID, rows); err != nil {
+		return nil, err
+	}
+	return s.Get(hospitalID)
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+var (
+	ErrSyncInProgress = errors.New("HIS sync already running for this hospital")
+	ErrHISNotEnabled  = errors.New("hospital has no HIS configured")
+	ErrSyncStopped    = errors.New("HIS sync is shutting down")
+)
+
+const (
+	SyncTriggerScheduled = "scheduled"
+	SyncTriggerManual    = "manual"
+)
+
+// HISSyncService pulls changed patients from each hospital's HIS and upserts
+// them locally, recording every run in his_sync_runs.
+type HISSyncService struct {
+	HospitalRepo repositories.HospitalRepositoryInterface
+	PatientRepo  repositories.PatientRepositoryInterface
+	SyncRepo     repositories.HISSyncRepositoryInterface
+	HIS          *his.Registry
+
+	mu      sync.Mutex
+	running map[uint]bool
+	stopped bool
+	runs    sync.WaitGroup
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewHISSyncService(hospitalRepo repositories.HospitalRepositoryInterface, patientRepo repositories.PatientRepositoryInterface, syncRepo repositories.HISSyncRepositoryInterface, registry *his.Registry) *HISSyncService {
+	return &HISSyncService{
+		HospitalRepo: hospitalRepo,
+		PatientRepo:  patientRepo,
+		SyncRepo:     syncRepo,
+		HIS:          registry,
+		running:      map[uint]bool{},
+	}
+}
+
+// Start runs SyncAll every interval until Stop is called. A non-positive
+// interval disables the scheduler.
+func (s *HISSyncService) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.SyncAll()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduler, refuses new runs and waits for running ones,
+// scheduled or manual, to finish writing.
+func (s *HISSyncService) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	s.runs.Wait()
+}
+
+// SyncAll syncs every active hospital that has an HIS configured, one at a
+// time.
+func (s *HISSyncService) SyncAll() {
+	hospitals, err := s.HospitalRepo.List()
+	if err != nil {
+		log.Printf("HIS sync: failed to list hospitals: %v", err)
+		return
+	}
+
+	for i := range hospitals {
+		if !hospitals[i].Active() {
+			continue
+		}
+		if _, err := s.HIS.ClientFor(&hospitals[i]); err != nil {
+			continue
+		}
+		run, err := s.begin(&hospitals[i], SyncTriggerScheduled)
+		if err != nil {
+			log.Printf("HIS sync: hospital %d: %v", hospitals[i].ID, err)
+			continue
+		}
+		s.run(&hospitals[i], run)
+	}
+}
+
+// Trigger starts a manual sync for one hospital in the background and
+// returns the run record immediately.
+func (s *HISSyncService) Trigger(hospitalID uint) (*models.HISSyncRun, error) {
+	hospital, err := s.HospitalRepo.FindByID(hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.HIS.ClientFor(hospital); err != nil {
+		return nil, ErrHISNotEnabled
+	}
+
+	run, err := s.begin(hospital, SyncTriggerManual)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := *run
+	go s.run(hospital, run)
+	return &snapshot, nil
+}
+
+func (s *HISSyncService) LastRun(hospitalID uint) (*models.HISSyncRun, error) {
+	return s.SyncRepo.LastRun(hospitalID)
+}
+
+// begin claims the per-hospital lock and records a running sync, which
+// Stop waits for until run releases it.
+func (s *HISSyncService) begin(hospital *models.Hospital, trigger string) (*models.HISSyncRun, error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil, ErrSyncStopped
+	}
+	if s.running[hospital.ID] {
+		s.mu.Unlock()
+		return nil, ErrSyncInProgress
+	}
+	s.running[hospital.ID] = true
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	run := &models.HISSyncRun{
+		HospitalID: hospital.ID,
+		Trigger:    trigger,
+		Status:     models.HISSyncRunning,
+		CursorFrom: hospital.HISSyncedUntil,
+		StartedAt:  time.Now(),
+	}
+	if err := s.SyncRepo.CreateRun(run); err != nil {
+		s.release(hospital.ID)
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *HISSyncService) release(hospitalID uint) {
+	s.mu.Lock()
+	delete(s.running, hospitalID)
+	s.mu.Unlock()
+	s.runs.Done()
+}
+
+func (s *HISSyncService) run(hospital *models.Hospital, run *models.HISSyncRun) {
+	defer s.release(hospital.ID)
+
+	err := s.pull(hospital, run)
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = models.HISSyncSucceeded
+	if err != nil {
+		run.Status = models.HISSyncFailed
+		run.Error = err.Error()
+	}
+	if err := s.SyncRepo.SaveRun(run); err != nil {
+		log.Printf("HIS sync: failed to record run %d: %v", run.ID, err)
+	}
+}
+
+// pull fetches one change set and advances the hospital cursor only if
+// every valid patient in it was stored, so rows that failed for a passing
+// reason, such as a database outage, are retried next run. Records that can
+// never be stored, because the HIS sent them malformed, with invalid IDs or
+// with an ID another of the hospital's patients already has, are counted as
+// failed but not retried, so they cannot hold the cursor back for good.
+func (s *HISSyncService) pull(hospital *models.Hospital, run *models.HISSyncRun) error {
+	client, err := s.HIS.ClientFor(hospital)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if hospital.HISSyncedUntil != nil {
+		since = *hospital.HISSyncedUntil
+	}
+
+	changes, err := client.ListChangedPatients(since)
+	if err != nil {
+		return err
+	}
+
+	run.Fetched = len(changes.Patients) + changes.Skipped
+	run.Failed = changes.Skipped
+	rejected := 0
+	for i := range changes.Patients {
+		p := &changes.Patients[i]
+		p.HospitalID = hospital.ID
+		if err := normalizePatientIDs(p); err != nil {
+			log.Printf("HIS sync: hospital %d patient %q: %v", hospital.ID, p.PatientHN, err)
+			run.Failed++
+			rejected++
+			continue
+		}
+		if err := s.PatientRepo.Upsert(p); err != nil {
+			log.Printf("HIS sync: hospital %d patient %q: %v", hospital.ID, p.PatientHN, err)
+			run.Failed++
+			if errors.Is(err, repositories.ErrDuplicateKey) {
+				rejected++
+			}
+			continue
+		}
+		run.Upserted++
+	}
+
+	if run.Upserted+rejected < len(changes.Patients) {
+		return errors.New("some patients could not be stored; cursor not advanced")
+	}
+
+	if changes.Cursor.After(since) {
+		if err := s.HospitalRepo.UpdateHISCursor(hospital.ID, changes.Cursor); err != nil {
+			return err
+		}
+		cursor := changes.Cursor
+		hospital.HISSyncedUntil = &cursor
+		run.CursorTo = &cursor
+	}
+	return nil
+}
@@ -1,13 +1,77 @@
 package services
 
-import "agnos_candidate_assignment/models"
+import (
+	"time"
+
+	"agnos_candidate_assignment/masking"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
 
 type AuthServiceInterface interface {
-	Register(hospital, username, password string) (*models.Staff, error)
-	Login(hospital, username, password string) (string, *models.Staff, error)
+	Register(hospital, username, password, invitationToken string) (*models.Staff, error)
+	Login(hospital, username, password, clientIP string) (*LoginResult, error)
+	Refresh(hospital, refreshToken string) (*TokenPair, *models.Staff, error)
+	Logout(staffID uint, jti string, accessExpiresAt time.Time, refreshToken string) error
+
+	CompleteMFALogin(hospital, mfaToken, code string) (*LoginResult, error)
+	StartMFAEnrollmentWithChallenge(hospital, mfaToken string) (*MFAEnrollment, error)
+	StartMFAEnrollment(hospitalID, staffID uint) (*MFAEnrollment, error)
+	ConfirmMFAEnrollment(hospitalID, staffID uint, code string) ([]string, error)
+	DisableMFA(hospitalID, staffID uint, code string) error
+
+	ChangePassword(hospitalID, staffID uint, oldPassword, newPassword string) (*TokenPair, *models.Staff, error)
+	ResetPassword(hospital, resetToken, newPassword string) error
+}
+
+type SystemAdminServiceInterface interface {
+	Login(username, password, clientIP string) (string, *models.SystemAdmin, error)
+	Logout(jti string, expiresAt time.Time) error
+}
+
+type RoleServiceInterface interface {
+	ListRoles() ([]models.Role, error)
+	AssignRole(hospitalID, staffID uint, role string) (*models.Staff, error)
+}
+
+type StaffServiceInterface interface {
+	Invite(hospitalID, invitedBy uint, role string, ttl time.Duration) (string, *models.StaffInvitation, error)
+	InviteAdmin(hospitalID, systemAdminID uint, ttl time.Duration) (string, *models.StaffInvitation, error)
+	Approve(hospitalID, staffID uint) (*models.Staff, error)
+	Lockout(hospitalID, staffID uint) (*Lockout, error)
+	Unlock(hospitalID, staffID uint) error
+	IssuePasswordReset(hospitalID, issuedBy, staffID uint) (string, *models.PasswordResetToken, error)
+	List(hospitalID uint, filter repositories.StaffFilter, page repositories.PageRequest) (*repositories.StaffPage, error)
+	Get(hospitalID, staffID uint) (*models.Staff, error)
+	UpdateProfile(hospitalID, staffID uint, profile repositories.StaffProfile) (*models.Staff, error)
+	Disable(hospitalID, actorID, staffID uint) (*models.Staff, error)
+	Enable(hospitalID, staffID uint) (*models.Staff, error)
+	Delete(hospitalID, actorID, staffID uint) error
 }
 
 type PatientServiceInterface interface {
-	Search(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error)
+	Search(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error)
 	GetByNationalOrPassport(hospitalID uint, id string) (*models.Patient, error)
+	Verify(hospitalID uint, id string) (*PatientVerification, error)
+	Get(hospitalID, id uint) (*models.Patient, error)
+	Create(hospitalID uint, p *models.Patient) error
+	Update(hospitalID, id uint, upd PatientUpdate) (*models.Patient, error)
+	Delete(hospitalID, id uint) error
+}
+
+type FieldPolicyServiceInterface interface {
+	PolicyFor(hospitalID uint, role string) (masking.Policy, error)
+	Get(hospitalID uint) (*FieldPolicy, error)
+	Replace(hospitalID uint, rules map[string]masking.Policy) (*FieldPolicy, error)
+}
+
+type HISSyncServiceInterface interface {
+	Trigger(hospitalID uint) (*models.HISSyncRun, error)
+	LastRun(hospitalID uint) (*models.HISSyncRun, error)
+}
+
+type AuditServiceInterface interface {
+	Record(entry *models.AuditLog) error
+	List(hospitalID uint, filter repositories.AuditFilter, page repositories.PageRequest) (*repositories.AuditPage, error)
+	Verify(hospitalID uint) (*AuditVerification, error)
 }
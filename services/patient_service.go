@@ -1,22 +1,503 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/masking"
 	"agnos_candidate_assignment/models"
 	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/validation"
+
+	"gorm.io/gorm"
 )
 
 type PatientService struct {
-	Repo *repositories.PatientRepository
+	Repo         repositories.PatientRepositoryInterface
+	HospitalRepo repositories.HospitalRepositoryInterface
+	HIS          *his.Registry
 }
 
-func NewPatientService(repo *repositories.PatientRepository) *PatientService {
-	return &PatientService{Repo: repo}
+func NewPatientService(repo repositories.PatientRepositoryInterface, hospitalRepo repositories.HospitalRepositoryInterface, registry *his.Registry) *PatientService {
+	return &PatientService{Repo: repo, HospitalRepo: hospitalRepo, HIS: registry}
 }
 
-func (patientservice *PatientService) Search(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error) {
-	return patientservice.Repo.Search(hospitalID, filters)
+// Search returns one page of matching patients. When the first page of an
+// exact search with flat criteria comes back empty, the hospital's HIS is
+// queried and any patients it returns are stored before the page is re-read,
+// so the response shape is the same whichever source answered. The HIS API
+// only supports equality filters, so partial matching, ranges and groups
+// only cover local data.
+func (patientservice *PatientService) Search(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+	match, err := repositories.ParseMatchMode(string(criteria.Match))
+	if err != nil {
+		return nil, &ValidationError{Message: "match must be one of exact, prefix, contains, fuzzy"}
+	}
+	criteria.Match = match
+	if err := criteria.Walk(normalizeCriteria); err != nil {
+		return nil, err
+	}
+
+	result, err := patientservice.Repo.Search(hospitalID, criteria, page)
+	if err != nil {
+		return nil, translateSearchError(err)
+	}
+	if len(result.Patients) > 0 || page.Cursor != "" || match != repositories.MatchExact || !criteria.Simple() || criteria.IsEmpty() {
+		return result, nil
+	}
+
+	client := patientservice.hisClient(hospitalID)
+	if client == nil {
+		return result, nil
+	}
+
+	fetched, err := client.SearchPatients(hisFilters(&criteria))
+	if err != nil {
+		log.Printf("HIS search for hospital %d failed: %v", hospitalID, err)
+		return result, nil
+	}
+
+	stored := 0
+	for i := range fetched {
+		p := &fetched[i]
+		if err := patientservice.store(hospitalID, p); err != nil {
+			log.Printf("failed to store HIS patient %q: %v", p.PatientHN, err)
+			continue
+		}
+		stored++
+	}
+	if stored == 0 {
+		return result, nil
+	}
+
+	result, err = patientservice.Repo.Search(hospitalID, criteria, page)
+	if err != nil {
+		return nil, translateSearchError(err)
+	}
+	return result, nil
 }
 
+// PatientVerification is what the public verify endpoint reveals about a
+// patient: enough for them to confirm a record is theirs, with every field
+// masked.
+type PatientVerification struct {
+	PatientID   uint   `json:"-"`
+	FirstNameTH string `json:"first_name_th,omitempty" example:"ส***"`
+	LastNameTH  string `json:"last_name_th,omitempty" example:"ใ**"`
+	FirstNameEN string `json:"first_name_en,omitempty" example:"S******"`
+	LastNameEN  string `json:"last_name_en,omitempty" example:"J*****"`
+	BirthYear   int    `json:"birth_year" example:"1985"`
+	PhoneNumber string `json:"phone_number,omitempty" example:"******5678"`
+	Email       string `json:"email,omitempty" example:"s******@example.com"`
+}
+
+// Verify looks a national ID or passport number up in local data only, so
+// unauthenticated callers cannot make the hospital query its HIS, and
+// returns the record masked.
+func (patientservice *PatientService) Verify(hospitalID uint, nationalOrPassport string) (*PatientVerification, error) {
+	kind, id, err := validation.ClassifyID(nationalOrPassport)
+	if err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
+
+	var p *models.Patient
+	if kind == validation.NationalID {
+		p, err = patientservice.Repo.GetByNationalID(hospitalID, id)
+	} else {
+		p, err = patientservice.Repo.GetByPassportID(hospitalID, id)
+	}
+	if err != nil {
+		return nil, translatePatientError(err)
+	}
+
+	v := &PatientVerification{PatientID: p.ID, BirthYear: p.DateOfBirth.Year()}
+	masked := []struct {
+		src  *string
+		dst  *string
+		mask func(string) string
+	}{
+		{p.FirstNameTH, &v.FirstNameTH, masking.Name},
+		{p.LastNameTH, &v.LastNameTH, masking.Name},
+		{p.FirstNameEN, &v.FirstNameEN, masking.Name},
+		{p.LastNameEN, &v.LastNameEN, masking.Name},
+		{p.PhoneNumber, &v.PhoneNumber, masking.Phone},
+		{p.Email, &v.Email, masking.Email},
+	}
+	for _, m := range masked {
+		if m.src != nil {
+			*m.dst = m.mask(*m.src)
+		}
+	}
+	return v, nil
+}
+
+// GetByNationalOrPassport classifies the identifier and looks it up in the
+// matching column only, then in the hospital's HIS. ErrPatientNotFound means
+// neither has the patient; a failed lookup in either returns its error.
 func (patientservice *PatientService) GetByNationalOrPassport(hospitalID uint, nationalOrPassport string) (*models.Patient, error) {
-	return patientservice.Repo.GetByNationalOrPassportID(hospitalID, nationalOrPassport)
+	kind, id, err := validation.ClassifyID(nationalOrPassport)
+	if err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
+
+	var p *models.Patient
+	if kind == validation.NationalID {
+		p, err = patientservice.Repo.GetByNationalID(hospitalID, id)
+	} else {
+		p, err = patientservice.Repo.GetByPassportID(hospitalID, id)
+	}
+	if err == nil {
+		return p, nil
+	}
+	if err = translatePatientError(err); !errors.Is(err, ErrPatientNotFound) {
+		return nil, err
+	}
+
+	client := patientservice.hisClient(hospitalID)
+	if client == nil {
+		return nil, err
+	}
+
+	fetched, hisErr := client.GetPatient(id)
+	if errors.Is(hisErr, his.ErrNotFound) {
+		return nil, err
+	}
+	if hisErr != nil {
+		log.Printf("HIS lookup for hospital %d failed: %v", hospitalID, hisErr)
+		return nil, fmt.Errorf("HIS lookup failed: %w", hisErr)
+	}
+
+	if err := patientservice.store(hospitalID, fetched); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// PatientUpdate carries a PATCH: nil fields are left unchanged, and an
+// empty string clears an optional field.
+type PatientUpdate struct {
+	FirstNameTH  *string
+	MiddleNameTH *string
+	LastNameTH   *string
+	FirstNameEN  *string
+	MiddleNameEN *string
+	LastNameEN   *string
+	DateOfBirth  *time.Time
+	PatientHN    *string
+	NationalID   *string
+	PassportID   *string
+	PhoneNumber  *string
+	Email        *string
+	Gender       *models.Gender
+}
+
+func (patientservice *PatientService) Get(hospitalID, id uint) (*models.Patient, error) {
+	p, err := patientservice.Repo.GetByID(hospitalID, id)
+	if err != nil {
+		return nil, translatePatientError(err)
+	}
+	return p, nil
+}
+
+func (patientservice *PatientService) Create(hospitalID uint, p *models.Patient) error {
+	if err := prepareNewPatient(hospitalID, p); err != nil {
+		return err
+	}
+	return translatePatientError(patientservice.Repo.Create(p))
+}
+
+// Import validates a patient as Create does but upserts it by HN, so a
+// bulk load can be run again.
+func (patientservice *PatientService) Import(hospitalID uint, p *models.Patient) error {
+	if err := prepareNewPatient(hospitalID, p); err != nil {
+		return err
+	}
+	return translatePatientError(patientservice.Repo.Upsert(p))
+}
+
+// Export calls fn with every patient of the hospital in id order, reading
+// them a page at a time, shaped by policy as the API would show them to the
+// exporting role: the *models.Patient itself when the policy is full, its
+// masked JSON object otherwise. It never queries the HIS.
+func (patientservice *PatientService) Export(hospitalID uint, policy masking.Policy, fn func(record interface{}) error) error {
+	page := repositories.PageRequest{Limit: repositories.MaxPageSize, Sort: "id"}
+	for {
+		result, err := patientservice.Repo.Search(hospitalID, repositories.PatientSearchCriteria{}, page)
+		if err != nil {
+			return err
+		}
+		for i := range result.Patients {
+			var record interface{} = &result.Patients[i]
+			if !policy.Full() {
+				if record, err = policy.Shape(record); err != nil {
+					return err
+				}
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if result.NextCursor == "" {
+			return nil
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+// prepareNewPatient validates a patient about to be stored for the
+// hospital and normalizes its identity documents.
+func prepareNewPatient(hospitalID uint, p *models.Patient) error {
+	p.ID = 0
+	p.HospitalID = hospitalID
+	// An exported patient carries its hospital; never save it as well.
+	p.Hospital = models.Hospital{}
+	p.PatientHN = strings.TrimSpace(p.PatientHN)
+	if p.PatientHN == "" {
+		return &ValidationError{Message: "patient_hn is required"}
+	}
+	if err := validateDateOfBirth(p.DateOfBirth); err != nil {
+		return err
+	}
+	if err := validateGender(p.Gender); err != nil {
+		return err
+	}
+	return normalizePatientIDs(p)
+}
+
+func (patientservice *PatientService) Update(hospitalID, id uint, upd PatientUpdate) (*models.Patient, error) {
+	fields := map[string]interface{}{}
+	optional := map[string]*string{
+		"first_name_th":  upd.FirstNameTH,
+		"middle_name_th": upd.MiddleNameTH,
+		"last_name_th":   upd.LastNameTH,
+		"first_name_en":  upd.FirstNameEN,
+		"middle_name_en": upd.MiddleNameEN,
+		"last_name_en":   upd.LastNameEN,
+		"national_id":    upd.NationalID,
+		"passport_id":    upd.PassportID,
+		"phone_number":   upd.PhoneNumber,
+		"email":          upd.Email,
+	}
+	for column, v := range optional {
+		if v == nil {
+			continue
+		}
+		if trimmed := strings.TrimSpace(*v); trimmed != "" {
+			fields[column] = trimmed
+		} else {
+			fields[column] = nil
+		}
+	}
+
+	if err := normalizeOptionalID(fields, "national_id", validation.NormalizeNationalID); err != nil {
+		return nil, err
+	}
+	if err := normalizeOptionalID(fields, "passport_id", validation.NormalizePassport); err != nil {
+		return nil, err
+	}
+
+	if upd.PatientHN != nil {
+		hn := strings.TrimSpace(*upd.PatientHN)
+		if hn == "" {
+			return nil, &ValidationError{Message: "patient_hn cannot be empty"}
+		}
+		fields["patient_hn"] = hn
+	}
+	if upd.DateOfBirth != nil {
+		if err := validateDateOfBirth(*upd.DateOfBirth); err != nil {
+			return nil, err
+		}
+		fields["date_of_birth"] = *upd.DateOfBirth
+	}
+	if upd.Gender != nil {
+		if err := validateGender(*upd.Gender); err != nil {
+			return nil, err
+		}
+		fields["gender"] = *upd.Gender
+	}
+
+	if len(fields) == 0 {
+		return nil, &ValidationError{Message: "no fields to update"}
+	}
+
+	if err := patientservice.Repo.UpdateFields(hospitalID, id, fields); err != nil {
+		return nil, translatePatientError(err)
+	}
+	return patientservice.Get(hospitalID, id)
+}
+
+func (patientservice *PatientService) Delete(hospitalID, id uint) error {
+	return translatePatientError(patientservice.Repo.Delete(hospitalID, id))
+}
+
+func validateDateOfBirth(dob time.Time) error {
+	if dob.IsZero() {
+		return &ValidationError{Message: "date_of_birth is required"}
+	}
+	if dob.After(time.Now()) {
+		return &ValidationError{Message: "date_of_birth cannot be in the future"}
+	}
+	return nil
+}
+
+func validateGender(g models.Gender) error {
+	if g != models.Male && g != models.Female {
+		return &ValidationError{Message: "gender must be M or F"}
+	}
+	return nil
+}
+
+// normalizePatientIDs validates the patient's identity documents and
+// rewrites them into their canonical stored form.
+func normalizePatientIDs(p *models.Patient) error {
+	if p.NationalID != nil {
+		id, err := validation.NormalizeNationalID(*p.NationalID)
+		if err != nil {
+			return &ValidationError{Message: err.Error()}
+		}
+		p.NationalID = &id
+	}
+	if p.PassportID != nil {
+		id, err := validation.NormalizePassport(*p.PassportID)
+		if err != nil {
+			return &ValidationError{Message: err.Error()}
+		}
+		p.PassportID = &id
+	}
+	return nil
+}
+
+func normalizeOptionalID(fields map[string]interface{}, column string, normalize func(string) (string, error)) error {
+	v, ok := fields[column].(string)
+	if !ok {
+		return nil
+	}
+	id, err := normalize(v)
+	if err != nil {
+		return &ValidationError{Message: err.Error()}
+	}
+	fields[column] = id
+	return nil
+}
+
+// normalizeCriteria validates one criteria group and applies the same ID
+// normalization as writes, so "1-1007-..." finds the row stored as
+// "11007...".
+func normalizeCriteria(criteria *repositories.PatientSearchCriteria) error {
+	if criteria.NationalID != "" {
+		id, err := validation.NormalizeNationalID(criteria.NationalID)
+		if err != nil {
+			return &ValidationError{Message: err.Error()}
+		}
+		criteria.NationalID = id
+	}
+	if criteria.PassportID != "" {
+		id, err := validation.NormalizePassport(criteria.PassportID)
+		if err != nil {
+			return &ValidationError{Message: err.Error()}
+		}
+		criteria.PassportID = id
+	}
+	if criteria.Gender != "" {
+		if err := validateGender(criteria.Gender); err != nil {
+			return err
+		}
+	}
+	if criteria.DOBFrom != nil && criteria.DOBTo != nil && criteria.DOBFrom.After(*criteria.DOBTo) {
+		return &ValidationError{Message: "dob_from must not be after dob_to"}
+	}
+	if (criteria.AgeMin != nil && *criteria.AgeMin < 0) || (criteria.AgeMax != nil && *criteria.AgeMax < 0) {
+		return &ValidationError{Message: "age bounds cannot be negative"}
+	}
+	if criteria.AgeMin != nil && criteria.AgeMax != nil && *criteria.AgeMin > *criteria.AgeMax {
+		return &ValidationError{Message: "age_min must not be greater than age_max"}
+	}
+	return nil
+}
+
+// hisFilters converts flat criteria into the query parameters of the HIS
+// search API.
+func hisFilters(criteria *repositories.PatientSearchCriteria) map[string]interface{} {
+	filters := map[string]interface{}{}
+	set := func(key, value string) {
+		if value != "" {
+			filters[key] = value
+		}
+	}
+	set("patient_hn", criteria.PatientHN)
+	set("national_id", criteria.NationalID)
+	set("passport_id", criteria.PassportID)
+	set("first_name", criteria.FirstName)
+	set("middle_name", criteria.MiddleName)
+	set("last_name", criteria.LastName)
+	set("first_name_th", criteria.FirstNameTH)
+	set("middle_name_th", criteria.MiddleNameTH)
+	set("last_name_th", criteria.LastNameTH)
+	set("first_name_en", criteria.FirstNameEN)
+	set("middle_name_en", criteria.MiddleNameEN)
+	set("last_name_en", criteria.LastNameEN)
+	set("phone_number", criteria.PhoneNumber)
+	set("email", criteria.Email)
+	set("gender", string(criteria.Gender))
+	if criteria.DateOfBirth != nil {
+		set("date_of_birth", criteria.DateOfBirth.Format("2006-01-02"))
+	}
+	return filters
+}
+
+func translateSearchError(err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrInvalidCursor):
+		return &ValidationError{Message: "invalid cursor"}
+	case errors.Is(err, repositories.ErrInvalidSort):
+		return &ValidationError{Message: "sort must be one of id, -id, updated_at, -updated_at, or relevance for partial name matches"}
+	}
+	return err
+}
+
+func translatePatientError(err error) error {
+	var dup *repositories.DuplicateKeyError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrPatientNotFound
+	case errors.As(err, &dup):
+		return &ConflictError{Field: dup.Field}
+	}
+	return err
+}
+
+// hisClient returns nil when the hospital has no usable HIS, so callers
+// simply serve what is stored locally.
+func (patientservice *PatientService) hisClient(hospitalID uint) his.HISClient {
+	if patientservice.HIS == nil || patientservice.HospitalRepo == nil {
+		return nil
+	}
+
+	hospital, err := patientservice.HospitalRepo.FindByID(hospitalID)
+	if err != nil {
+		return nil
+	}
+
+	client, err := patientservice.HIS.ClientFor(hospital)
+	if err != nil {
+		if !errors.Is(err, his.ErrNotConfigured) {
+			log.Printf("HIS client for hospital %d: %v", hospitalID, err)
+		}
+		return nil
+	}
+	return client
+}
+
+func (patientservice *PatientService) store(hospitalID uint, p *models.Patient) error {
+	p.HospitalID = hospitalID
+	if err := normalizePatientIDs(p); err != nil {
+		return err
+	}
+	return patientservice.Repo.Upsert(p)
 }
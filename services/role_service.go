@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+var (
+	ErrStaffNotFound = errors.New("staff not found")
+	ErrRoleNotFound  = errors.New("role not found")
+	ErrLastAdmin     = errors.New("cannot remove the hospital's last admin")
+)
+
+type RoleService struct {
+	StaffRepo repositories.StaffRepositoryInterface
+	RoleRepo  repositories.RoleRepositoryInterface
+}
+
+func NewRoleService(staffRepo repositories.StaffRepositoryInterface, roleRepo repositories.RoleRepositoryInterface) *RoleService {
+	return &RoleService{StaffRepo: staffRepo, RoleRepo: roleRepo}
+}
+
+func (roleservice *RoleService) ListRoles() ([]models.Role, error) {
+	return roleservice.RoleRepo.List()
+}
+
+// AssignRole gives a staff member of the hospital a new role. It refuses to
+// demote the hospital's only admin, which would leave nobody able to manage
+// staff. The change takes effect at the staff member's next login.
+func (roleservice *RoleService) AssignRole(hospitalID, staffID uint, roleName string) (*models.Staff, error) {
+	staff, err := roleservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return nil, ErrStaffNotFound
+	}
+
+	role, err := roleservice.RoleRepo.FindByName(roleName)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	if staff.Status == models.StaffActive && staff.Role != nil && staff.Role.Name == models.RoleAdmin && role.Name != models.RoleAdmin {
+		admins, err := roleservice.StaffRepo.CountByRole(hospitalID, staff.Role.ID)
+		if err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	if err := roleservice.StaffRepo.UpdateRole(hospitalID, staff.ID, role.ID); err != nil {
+		return nil, err
+	}
+	staff.RoleID = &role.ID
+	staff.Role = role
+	return staff, nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+)
+
+const (
+	DefaultInvitationTTL = 72 * time.Hour
+	MaxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+// Longest accepted profile fields, matching the column sizes.
+const (
+	maxDisplayNameLength   = 255
+	maxLicenseNumberLength = 64
+	maxDepartmentLength    = 128
+)
+
+var (
+	ErrStaffNotPending  = errors.New("staff account is not pending approval")
+	ErrStaffNotDisabled = errors.New("staff account is not disabled")
+	ErrCannotModifySelf = errors.New("you cannot disable or delete your own account")
+)
+
+type StaffService struct {
+	StaffRepo      repositories.StaffRepositoryInterface
+	RoleRepo       repositories.RoleRepositoryInterface
+	InvitationRepo repositories.InvitationRepositoryInterface
+	PasswordRepo   repositories.PasswordRepositoryInterface
+}
+
+func NewStaffService(staffRepo repositories.StaffRepositoryInterface, roleRepo repositories.RoleRepositoryInterface, invitationRepo repositories.InvitationRepositoryInterface, passwordRepo repositories.PasswordRepositoryInterface) *StaffService {
+	return &StaffService{StaffRepo: staffRepo, RoleRepo: roleRepo, InvitationRepo: invitationRepo, PasswordRepo: passwordRepo}
+}
+
+// Invite issues a single-use registration token for the hospital. roleName
+// may be empty, leaving the new account without permissions until a role is
+// assigned; ttl of zero means DefaultInvitationTTL. The returned token is not
+// stored and cannot be recovered later.
+func (staffservice *StaffService) Invite(hospitalID, invitedBy uint, roleName string, ttl time.Duration) (string, *models.StaffInvitation, error) {
+	inv := &models.StaffInvitation{HospitalID: hospitalID, CreatedByID: &invitedBy}
+	return staffservice.issueInvitation(inv, roleName, ttl)
+}
+
+// InviteAdmin issues a system admin's invitation to register as one of the
+// hospital's admins, such as its first. The account is active at once, even
+// when the hospital requires approval.
+func (staffservice *StaffService) InviteAdmin(hospitalID, systemAdminID uint, ttl time.Duration) (string, *models.StaffInvitation, error) {
+	inv := &models.StaffInvitation{HospitalID: hospitalID, CreatedByAdminID: &systemAdminID}
+	return staffservice.issueInvitation(inv, models.RoleAdmin, ttl)
+}
+
+func (staffservice *StaffService) issueInvitation(inv *models.StaffInvitation, roleName string, ttl time.Duration) (string, *models.StaffInvitation, error) {
+	if ttl == 0 {
+		ttl = DefaultInvitationTTL
+	}
+	if ttl < 0 || ttl > MaxInvitationTTL {
+		return "", nil, &ValidationError{Message: fmt.Sprintf("expiry must be between 1 and %d hours", int(MaxInvitationTTL.Hours()))}
+	}
+	inv.ExpiresAt = time.Now().Add(ttl)
+
+	if roleName != "" {
+		role, err := staffservice.RoleRepo.FindByName(roleName)
+		if err != nil {
+			return "", nil, ErrRoleNotFound
+		}
+		inv.RoleID = &role.ID
+		inv.Role = role
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	inv.TokenHash = HashToken(token)
+
+	if err := staffservice.InvitationRepo.Create(inv); err != nil {
+		return "", nil, err
+	}
+	return token, inv, nil
+}
+
+// Approve activates a pending staff account of the hospital.
+func (staffservice *StaffService) Approve(hospitalID, staffID uint) (*models.Staff, error) {
+	staff, err := staffservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return nil, ErrStaffNotFound
+	}
+	if staff.Status != models.StaffPending {
+		return nil, ErrStaffNotPending
+	}
+	if err := staffservice.StaffRepo.UpdateStatus(hospitalID, staff.ID, models.StaffActive); err != nil {
+		return nil, err
+	}
+	staff.Status = models.StaffActive
+	return staff, nil
+}
+
+// Lockout is a staff account's failed login state.
+type Lockout struct {
+	StaffID      uint       `json:"staff_id"`
+	FailedLogins int        `json:"failed_logins"`
+	Locked       bool       `json:"locked"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+// Lockout reports how many wrong passwords the staff member has had in a
+// row and whether logins are currently refused.
+func (staffservice *StaffService) Lockout(hospitalID, staffID uint) (*Lockout, error) {
+	staff, err := staffservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return nil, ErrStaffNotFound
+	}
+	lockout := &Lockout{StaffID: staff.ID, FailedLogins: staff.FailedLogins}
+	if staff.LockedUntil != nil && time.Now().Before(*staff.LockedUntil) {
+		lockout.Locked = true
+		lockout.LockedUntil = staff.LockedUntil
+	}
+	return lockout, nil
+}
+
+// Unlock clears the staff member's failed logins and lifts any lock. Locks
+// on client IPs are not affected.
+func (staffservice *StaffService) Unlock(hospitalID, staffID uint) error {
+	staff, err := staffservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return ErrStaffNotFound
+	}
+	return staffservice.StaffRepo.ClearLoginFailures(hospitalID, staff.ID)
+}
+
+// IssuePasswordReset creates a single-use token with which the staff member
+// sets a new password, valid for PasswordResetTTL. Earlier unused tokens for
+// the same staff member stop working. The returned token is not stored.
+func (staffservice *StaffService) IssuePasswordReset(hospitalID, issuedBy, staffID uint) (string, *models.PasswordResetToken, error) {
+	staff, err := staffservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return "", nil, ErrStaffNotFound
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	reset := &models.PasswordResetToken{
+		StaffID:     staff.ID,
+		TokenHash:   HashToken(token),
+		CreatedByID: issuedBy,
+		ExpiresAt:   time.Now().Add(PasswordResetTTL),
+	}
+	if err := staffservice.PasswordRepo.CreateReset(reset); err != nil {
+		return "", nil, err
+	}
+	return token, reset, nil
+}
+
+// List returns one page of the hospital's staff.
+func (staffservice *StaffService) List(hospitalID uint, filter repositories.StaffFilter, page repositories.PageRequest) (*repositories.StaffPage, error) {
+	switch filter.Status {
+	case "", models.StaffActive, models.StaffPending, models.StaffDisabled:
+	default:
+		return nil, &ValidationError{Message: "status must be active, pending or disabled"}
+	}
+	return staffservice.StaffRepo.List(hospitalID, filter, page)
+}
+
+// Get returns a staff member of the hospital.
+func (staffservice *StaffService) Get(hospitalID, staffID uint) (*models.Staff, error) {
+	staff, err := staffservice.StaffRepo.GetByID(hospitalID, staffID)
+	if err != nil || staff.HospitalID != hospitalID {
+		return nil, ErrStaffNotFound
+	}
+	return staff, nil
+}
+
+// UpdateProfile changes the given profile fields; surrounding whitespace is
+// trimmed and an empty string clears a field.
+func (staffservice *StaffService) UpdateProfile(hospitalID, staffID uint, profile repositories.StaffProfile) (*models.Staff, error) {
+	staff, err := staffservice.Get(hospitalID, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cleaned repositories.StaffProfile
+	fields := []struct {
+		name string
+		in   *string
+		max  int
+		out  **string
+	}{
+		{"display_name", profile.DisplayName, maxDisplayNameLength, &cleaned.DisplayName},
+		{"license_number", profile.LicenseNumber, maxLicenseNumberLength, &cleaned.LicenseNumber},
+		{"department", profile.Department, maxDepartmentLength, &cleaned.Department},
+	}
+	for _, f := range fields {
+		if f.in == nil {
+			continue
+		}
+		v := strings.TrimSpace(*f.in)
+		if utf8.RuneCountInString(v) > f.max {
+			return nil, &ValidationError{Message: fmt.Sprintf("%s must be at most %d characters", f.name, f.max)}
+		}
+		*f.out = &v
+	}
+
+	if err := staffservice.StaffRepo.UpdateProfile(hospitalID, staff.ID, cleaned); err != nil {
+		return nil, err
+	}
+	if cleaned.DisplayName != nil {
+		staff.DisplayName = *cleaned.DisplayName
+	}
+	if cleaned.LicenseNumber != nil {
+		staff.LicenseNumber = *cleaned.LicenseNumber
+	}
+	if cleaned.Department != nil {
+		staff.Department = *cleaned.Department
+	}
+	return staff, nil
+}
+
+// Disable stops a staff member from logging in and ends their sessions at
+// once. Admins cannot disable themselves or the hospital's last active
+// admin.
+func (staffservice *StaffService) Disable(hospitalID, actorID, staffID uint) (*models.Staff, error) {
+	staff, err := staffservice.removable(hospitalID, actorID, staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.Status == models.StaffDisabled {
+		return staff, nil
+	}
+	if err := staffservice.StaffRepo.Disable(hospitalID, staff.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	staff.Status = models.StaffDisabled
+	return staff, nil
+}
+
+// Enable lets a disabled staff member log in again. Their old sessions stay
+// ended.
+func (staffservice *StaffService) Enable(hospitalID, staffID uint) (*models.Staff, error) {
+	staff, err := staffservice.Get(hospitalID, staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.Status != models.StaffDisabled {
+		return nil, ErrStaffNotDisabled
+	}
+	if err := staffservice.StaffRepo.UpdateStatus(hospitalID, staff.ID, models.StaffActive); err != nil {
+		return nil, err
+	}
+	staff.Status = models.StaffActive
+	return staff, nil
+}
+
+// Delete soft-deletes a staff member, with the same restrictions as Disable.
+// The account disappears from the API, but its row and audit history stay.
+func (staffservice *StaffService) Delete(hospitalID, actorID, staffID uint) error {
+	staff, err := staffservice.removable(hospitalID, actorID, staffID)
+	if err != nil {
+		return err
+	}
+	return staffservice.StaffRepo.SoftDelete(hospitalID, staff.ID, time.Now())
+}
+
+func (staffservice *StaffService) removable(hospitalID, actorID, staffID uint) (*models.Staff, error) {
+	staff, err := staffservice.Get(hospitalID, staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.ID == actorID {
+		return nil, ErrCannotModifySelf
+	}
+	if staff.Status == models.StaffActive && staff.Role != nil && staff.Role.Name == models.RoleAdmin {
+		admins, err := staffservice.StaffRepo.CountByRole(hospitalID, staff.Role.ID)
+		if err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, ErrLastAdmin
+		}
+	}
+	return staff, nil
+}
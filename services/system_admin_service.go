@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/keyset"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/password"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/throttle"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SystemAdminTokenTTL is how long a system admin token is valid.
+const SystemAdminTokenTTL = 8 * time.Hour
+
+var ErrInvalidCredentials = errors.New("Invalid username or password")
+
+// SystemAdminService logs system admins in. Wrong passwords are throttled
+// like staff logins, per username and per client IP, with the same
+// LOGIN_* settings; the counts are kept in memory per instance, and unknown
+// usernames are counted and timed like real ones.
+type SystemAdminService struct {
+	Repo   repositories.SystemAdminRepositoryInterface
+	Tokens repositories.TokenDenylistInterface
+	Keys   *keyset.Set
+	conf   *config.Config
+
+	hasher          password.Hasher
+	dummyHash       string
+	accountThrottle *throttle.Tracker
+	ipThrottle      *throttle.Tracker
+}
+
+func NewSystemAdminService(repo repositories.SystemAdminRepositoryInterface, tokens repositories.TokenDenylistInterface, keys *keyset.Set, conf *config.Config) *SystemAdminService {
+	hasher := password.Hasher{Cost: conf.BcryptCost}
+	dummyHash, _ := hasher.Hash("unknown admin")
+	return &SystemAdminService{
+		Repo:            repo,
+		Tokens:          tokens,
+		Keys:            keys,
+		conf:            conf,
+		hasher:          hasher,
+		dummyHash:       dummyHash,
+		accountThrottle: throttle.NewTracker(throttle.Policy{FreeAttempts: accountFreeAttempts, MaxFailures: conf.LoginMaxFailures, Lockout: conf.LoginLockout}),
+		ipThrottle:      throttle.NewTracker(throttle.Policy{FreeAttempts: ipFreeAttempts, MaxFailures: conf.LoginIPMaxFailures, Lockout: conf.LoginLockout}),
+	}
+}
+
+// EnsureBootstrap creates the configured system admin if there is no system
+// admin at all, and reports whether it did.
+func (s *SystemAdminService) EnsureBootstrap() (bool, error) {
+	if s.conf.SystemAdminUsername == "" || s.conf.SystemAdminPassword == "" {
+		return false, nil
+	}
+	n, err := s.Repo.Count()
+	if err != nil || n > 0 {
+		return false, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.conf.SystemAdminPassword), s.conf.BcryptCost)
+	if err != nil {
+		return false, err
+	}
+	admin := &models.SystemAdmin{UserName: s.conf.SystemAdminUsername, PasswordHash: string(hash)}
+	if err := s.Repo.Create(admin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Login checks a system admin's password and returns a token carrying a
+// jti, so Logout can revoke it. A locked username or client IP gets
+// *LoginThrottledError.
+func (s *SystemAdminService) Login(username, password, clientIP string) (string, *models.SystemAdmin, error) {
+	now := time.Now()
+	if wait := s.ipThrottle.Wait(clientIP, now); wait > 0 {
+		return "", nil, &LoginThrottledError{RetryAfter: wait}
+	}
+	if wait := s.accountThrottle.Wait(username, now); wait > 0 {
+		s.ipThrottle.Fail(clientIP, now)
+		return "", nil, &LoginThrottledError{RetryAfter: wait}
+	}
+
+	admin, err := s.Repo.FindByUserName(username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	hash := s.dummyHash
+	if admin != nil {
+		hash = admin.PasswordHash
+	}
+	if err := s.hasher.Compare(hash, password); err != nil || admin == nil {
+		s.accountThrottle.Fail(username, now)
+		s.ipThrottle.Fail(clientIP, now)
+		return "", nil, ErrInvalidCredentials
+	}
+	s.accountThrottle.Reset(username)
+
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", nil, err
+	}
+	claims := jwt.MapClaims{
+		"admin_id": admin.ID,
+		"jti":      jti,
+		"scope":    models.SystemAdminScope,
+		"iss":      s.conf.JWTIssuer,
+		"aud":      s.conf.SystemAdminAudience(),
+		"iat":      now.Unix(),
+		"exp":      now.Add(SystemAdminTokenTTL).Unix(),
+	}
+	signed, err := s.Keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, admin, nil
+}
+
+// Logout revokes a system admin token until it expires.
+func (s *SystemAdminService) Logout(jti string, expiresAt time.Time) error {
+	return s.Tokens.Deny(jti, expiresAt)
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAuditRepo struct {
+	entries []models.AuditLog
+	err     error
+}
+
+func (m *mockAuditRepo) Append(entry *models.AuditLog) error {
+	if m.err != nil {
+		return m.err
+	}
+	last := &models.AuditLog{}
+	if n := len(m.entries); n > 0 {
+		last = &m.entries[n-1]
+	}
+	entry.LinkTo(last)
+	entry.ID = uint(len(m.entries) + 1)
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *mockAuditRepo) Chain(hospitalID uint, afterSeq int64, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	for _, e := range m.entries {
+		if e.HospitalID == hospitalID && e.Seq > afterSeq && len(entries) < limit {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *mockAuditRepo) List(hospitalID uint, filter repositories.AuditFilter, page repositories.PageRequest) (*repositories.AuditPage, error) {
+	return &repositories.AuditPage{Entries: m.entries}, nil
+}
+
+func newAuditChain(t *testing.T, n int) (*mockAuditRepo, *services.AuditService) {
+	repo := &mockAuditRepo{}
+	svc := services.NewAuditService(repo)
+	staffID := uint(7)
+	for i := 0; i < n; i++ {
+		require.NoError(t, svc.Record(&models.AuditLog{
+			HospitalID: 2,
+			StaffID:    &staffID,
+			Action:     models.AuditPatientRead,
+			PatientIDs: models.IDList{uint(i + 1)},
+			Status:     http.StatusOK,
+		}))
+	}
+	return repo, svc
+}
+
+func TestAuditVerify_IntactChain(t *testing.T) {
+	repo, svc := newAuditChain(t, 3)
+	require.Empty(t, repo.entries[0].PrevHash)
+	require.Equal(t, repo.entries[0].Hash, repo.entries[1].PrevHash)
+
+	result, err := svc.Verify(2)
+	require.NoError(t, err)
+	require.True(t, result.Valid)
+	require.Equal(t, int64(3), result.Checked)
+}
+
+func TestAuditVerify_DetectsTampering(t *testing.T) {
+	repo, svc := newAuditChain(t, 3)
+	repo.entries[1].PatientIDs = models.IDList{99}
+
+	result, err := svc.Verify(2)
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, int64(2), *result.BrokenAt)
+	require.Equal(t, int64(1), result.Checked)
+
+	// Re-sealing the edited entry still breaks the link to the next one.
+	repo.entries[1].Hash = repo.entries[1].ComputeHash()
+	result, err = svc.Verify(2)
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, int64(3), *result.BrokenAt)
+}
+
+func TestAuditVerify_DetectsRemoval(t *testing.T) {
+	repo, svc := newAuditChain(t, 3)
+	repo.entries = append(repo.entries[:1], repo.entries[2:]...)
+
+	result, err := svc.Verify(2)
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, int64(3), *result.BrokenAt)
+}
+
+func newAuditRouter(repo *mockAuditRepo, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	withStaff := func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{StaffID: 7, HospitalID: 2})
+	}
+	r.GET("/api/patient/:patient_id", withStaff, middleware.AuditAccess(services.NewAuditService(repo), models.AuditPatientRead), handler)
+	return r
+}
+
+func TestAuditAccess_RecordsRequest(t *testing.T) {
+	repo := &mockAuditRepo{}
+	r := newAuditRouter(repo, func(c *gin.Context) {
+		middleware.SetAuditPatients(c, 42)
+		c.JSON(http.StatusOK, gin.H{"id": 42})
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/patient/42?fields=name", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	req.Header.Set("User-Agent", "ward-terminal/1.0")
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "req-123", rr.Header().Get(middleware.RequestIDHeader))
+	require.JSONEq(t, `{"id":42}`, rr.Body.String())
+	require.Len(t, repo.entries, 1)
+	entry := repo.entries[0]
+	require.Equal(t, uint(2), entry.HospitalID)
+	require.Equal(t, uint(7), *entry.StaffID)
+	require.Equal(t, models.AuditPatientRead, entry.Action)
+	require.Equal(t, models.IDList{42}, entry.PatientIDs)
+	require.Equal(t, http.StatusOK, entry.Status)
+	require.Equal(t, "req-123", entry.RequestID)
+	require.Equal(t, "ward-terminal/1.0", entry.UserAgent)
+	require.JSONEq(t, `["fields","patient_id"]`, string(entry.Filters))
+}
+
+func TestAuditAccess_RecordsFilterNamesOnly(t *testing.T) {
+	repo := &mockAuditRepo{}
+	r := newAuditRouter(repo, func(c *gin.Context) {
+		middleware.SetAuditFilters(c, map[string]interface{}{
+			"last_name":   "Jaidee",
+			"national_id": "",
+			"age_min":     30,
+			"any_of": []map[string]interface{}{
+				{"phone_number": "0812340001"},
+				{"email": "somchai@example.com", "first_name": ""},
+			},
+		})
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/42", nil))
+	require.Len(t, repo.entries, 1)
+	require.JSONEq(t, `["age_min","any_of.email","any_of.phone_number","last_name"]`, string(repo.entries[0].Filters))
+
+	// Query and path values are dropped the same way.
+	repo = &mockAuditRepo{}
+	r = newAuditRouter(repo, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/42?national_id=1100700000001", nil))
+	require.Len(t, repo.entries, 1)
+	require.NotContains(t, string(repo.entries[0].Filters), "1100700000001")
+	require.JSONEq(t, `["national_id","patient_id"]`, string(repo.entries[0].Filters))
+}
+
+func TestAuditAccess_RecordsFailures(t *testing.T) {
+	repo := &mockAuditRepo{}
+	r := newAuditRouter(repo, func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/patient/5", nil)
+	req.Header.Set(middleware.RequestIDHeader, "not a valid id!")
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Len(t, repo.entries, 1)
+	require.Equal(t, http.StatusNotFound, repo.entries[0].Status)
+	require.Empty(t, repo.entries[0].PatientIDs)
+	require.Len(t, repo.entries[0].RequestID, 32)
+	require.Equal(t, repo.entries[0].RequestID, rr.Header().Get(middleware.RequestIDHeader))
+}
+
+func TestAuditAccess_WithholdsResponseWhenNotRecorded(t *testing.T) {
+	repo := &mockAuditRepo{err: errors.New("database down")}
+	r := newAuditRouter(repo, func(c *gin.Context) {
+		middleware.SetAuditPatients(c, 42)
+		c.JSON(http.StatusOK, gin.H{"national_id": "1100700000001"})
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/42", nil))
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.False(t, strings.Contains(rr.Body.String(), "1100700000001"))
+}
+
+func TestAuditHandler_ListAndVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, svc := newAuditChain(t, 2)
+	h := handlers.NewAuditHandler(svc)
+	r := gin.New()
+	withHospital := func(c *gin.Context) { c.Set("hospital_id", uint(2)) }
+	r.GET("/api/h/audit", withHospital, h.List)
+	r.GET("/api/h/audit/verify", withHospital, h.Verify)
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/api/h/audit", http.StatusOK},
+		{"/api/h/audit?patient_id=1&staff_id=7&action=patient.read&from=2024-01-01T00:00:00Z", http.StatusOK},
+		{"/api/h/audit?patient_id=x", http.StatusBadRequest},
+		{"/api/h/audit?from=yesterday", http.StatusBadRequest},
+		{"/api/h/audit?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		require.Equal(t, tc.want, rr.Code, tc.path)
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/h/audit/verify", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var result services.AuditVerification
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	require.True(t, result.Valid)
+	require.Equal(t, int64(len(repo.entries)), result.Checked)
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/cli"
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/masking"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runCLI(conf *config.Config, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(args, conf, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_RejectsBadCommandLinesBeforeConnecting(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "missing command"},
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+		{[]string{"staff"}, "staff: missing subcommand"},
+		{[]string{"staff", "promote"}, `staff: unknown subcommand "promote"`},
+		{[]string{"migrate", "sideways"}, `migrate: unknown action "sideways"`},
+		{[]string{"migrate", "down", "-steps", "0"}, "-steps must be at least 1"},
+		{[]string{"drop"}, "pass -confirm"},
+		{[]string{"staff", "create", "-hospital", "Central Hospital", "-role", "nurse"}, "staff create: -username is required"},
+		{[]string{"hospital", "list", "extra"}, `unexpected argument "extra"`},
+	}
+	for _, tc := range cases {
+		// No DATABASE_URL: reaching the database would fail with exit 1.
+		code, _, stderr := runCLI(&config.Config{}, tc.args...)
+		require.Equal(t, 2, code, tc.args)
+		require.Contains(t, stderr, tc.want, tc.args)
+		require.Contains(t, stderr, "usage: agnos", tc.args)
+	}
+
+	code, _, stderr := runCLI(&config.Config{}, "drop", "-force")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "flag provided but not defined: -force")
+}
+
+func TestCLI_ReportsMissingConfiguration(t *testing.T) {
+	code, _, stderr := runCLI(&config.Config{}, "hospital", "list")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "DATABASE_URL is not set")
+
+	code, _, stderr = runCLI(&config.Config{AccessTokenTTL: time.Minute}, "token", "mint", "-hospital", "1", "-username", "alice")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "JWT_KEYS_DIR is not set")
+
+	code, stdout, _ := runCLI(&config.Config{}, "help")
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "token mint")
+}
+
+func TestLoadFixtures_DevFixturesImport(t *testing.T) {
+	fx, err := cli.LoadFixtures(filepath.Join("..", "fixtures", "dev"))
+	require.NoError(t, err)
+	require.Len(t, fx.Hospitals, 3)
+	require.Len(t, fx.Staff, 12)
+	require.Len(t, fx.Patients, 100)
+	for _, s := range fx.Staff {
+		_, ok := models.DefaultRoles[s.Role]
+		require.True(t, ok, "staff %s has unknown role %s", s.Username, s.Role)
+	}
+
+	// Every patient passes the API's validation.
+	repo := &mockPatientRepo{}
+	svc := services.NewPatientService(repo, &mockHospitalRepo{}, his.NewRegistry(time.Second))
+	for i, f := range fx.Patients {
+		p := f.Patient
+		require.NoError(t, svc.Import(uint(i%3+1), &p), f.PatientHN)
+	}
+	require.Len(t, repo.upserted, 100)
+	require.Equal(t, "Central Hospital", fx.Patients[0].Hospital)
+	require.Equal(t, "HN00001", repo.upserted[0].PatientHN)
+	require.Equal(t, uint(1), repo.upserted[0].HospitalID)
+}
+
+func TestLoadFixtures_RejectsUnknownHospital(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hospitals.json"), []byte(`[{"name": "Central Hospital"}]`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "staff.json"),
+		[]byte(`[{"username": "bob", "hospital": "Central Hospitl", "password": "Bob!23", "role": "doctor"}]`), 0o600))
+
+	_, err := cli.LoadFixtures(dir)
+	require.ErrorContains(t, err, `unknown hospital "Central Hospitl"`)
+
+	_, err = cli.LoadFixtures(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
+
+func TestPatientService_ImportDropsExportedHospital(t *testing.T) {
+	repo := &mockPatientRepo{}
+	svc := services.NewPatientService(repo, &mockHospitalRepo{}, his.NewRegistry(time.Second))
+
+	p := &models.Patient{
+		ID:          9,
+		HospitalID:  5,
+		Hospital:    models.Hospital{ID: 5, Name: "Elsewhere"},
+		PatientHN:   " HN9 ",
+		DateOfBirth: time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC),
+		Gender:      models.Female,
+	}
+	require.NoError(t, svc.Import(2, p))
+	require.Len(t, repo.upserted, 1)
+	require.Equal(t, uint(2), repo.upserted[0].HospitalID)
+	require.Equal(t, "HN9", repo.upserted[0].PatientHN)
+	require.Zero(t, repo.upserted[0].Hospital.ID)
+}
+
+func TestPatientService_ExportPagesInIDOrder(t *testing.T) {
+	var cursors []string
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		require.Equal(t, uint(4), hospitalID)
+		require.Equal(t, "id", page.Sort)
+		cursors = append(cursors, page.Cursor)
+		if page.Cursor == "" {
+			return &repositories.PatientPage{Patients: []models.Patient{{ID: 1}, {ID: 2}}, NextCursor: "next"}, nil
+		}
+		return &repositories.PatientPage{Patients: []models.Patient{{ID: 3}}}, nil
+	}}
+	svc := services.NewPatientService(repo, &mockHospitalRepo{}, his.NewRegistry(time.Second))
+
+	var ids []uint
+	require.NoError(t, svc.Export(4, masking.Policy{}, func(record interface{}) error {
+		ids = append(ids, record.(*models.Patient).ID)
+		return nil
+	}))
+	require.Equal(t, []uint{1, 2, 3}, ids)
+	require.Equal(t, []string{"", "next"}, cursors)
+}
+
+func TestPatientService_ExportAppliesPolicy(t *testing.T) {
+	nationalID, email := "1234567890123", "somchai@example.com"
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return &repositories.PatientPage{Patients: []models.Patient{{ID: 1, PatientHN: "HN1", NationalID: &nationalID, Email: &email}}}, nil
+	}}
+	svc := services.NewPatientService(repo, &mockHospitalRepo{}, his.NewRegistry(time.Second))
+
+	var records []interface{}
+	policy := masking.Policy{"national_id": masking.Mask, "email": masking.Omit}
+	require.NoError(t, svc.Export(4, policy, func(record interface{}) error {
+		records = append(records, record)
+		return nil
+	}))
+	require.Len(t, records, 1)
+	doc, ok := records[0].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, "HN1", doc["patient_hn"])
+	require.Equal(t, masking.NationalID(nationalID), doc["national_id"])
+	require.NotContains(t, doc, "email")
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/masking"
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFieldPolicyRepo struct {
+	rules map[uint][]models.PatientFieldRule
+}
+
+func (m *mockFieldPolicyRepo) Rules(hospitalID uint) ([]models.PatientFieldRule, error) {
+	return m.rules[hospitalID], nil
+}
+
+func (m *mockFieldPolicyRepo) ReplaceRules(hospitalID uint, rules []models.PatientFieldRule) error {
+	if m.rules == nil {
+		m.rules = map[uint][]models.PatientFieldRule{}
+	}
+	m.rules[hospitalID] = rules
+	return nil
+}
+
+func newFieldPolicies() *services.FieldPolicyService {
+	return services.NewFieldPolicyService(&mockFieldPolicyRepo{}, mockRoleRepo{})
+}
+
+func TestFieldPolicy_PolicyFor(t *testing.T) {
+	repo := &mockFieldPolicyRepo{rules: map[uint][]models.PatientFieldRule{2: {
+		{Role: models.AnyRole, Field: "email", Action: "omit"},
+		{Role: models.AnyRole, Field: "national_id", Action: "full"},
+		{Role: models.RoleNurse, Field: "email", Action: "mask"},
+	}}}
+	svc := services.NewFieldPolicyService(repo, mockRoleRepo{})
+
+	policy, err := svc.PolicyFor(1, models.RoleAuditor)
+	require.NoError(t, err)
+	require.Equal(t, services.DefaultFieldPolicies[models.RoleAuditor], policy)
+
+	policy, err = svc.PolicyFor(1, models.RoleAdmin)
+	require.NoError(t, err)
+	require.True(t, policy.Full())
+
+	// Clinical roles see identifiers and contact details masked by default.
+	for _, role := range []string{models.RoleDoctor, models.RoleNurse, models.RoleRegistration} {
+		policy, err = svc.PolicyFor(1, role)
+		require.NoError(t, err)
+		require.Equal(t, masking.Policy{
+			"national_id": masking.Mask, "passport_id": masking.Mask,
+			"phone_number": masking.Mask, "email": masking.Mask,
+		}, policy, role)
+	}
+
+	// Hospital rules override the defaults, and a role's rules the "*" ones.
+	policy, err = svc.PolicyFor(2, models.RoleAuditor)
+	require.NoError(t, err)
+	require.Equal(t, masking.Full, policy["national_id"])
+	require.Equal(t, masking.Omit, policy["email"])
+	require.Equal(t, masking.Mask, policy["phone_number"])
+
+	policy, err = svc.PolicyFor(2, models.RoleNurse)
+	require.NoError(t, err)
+	require.Equal(t, masking.Policy{
+		"email": masking.Mask, "national_id": masking.Full,
+		"passport_id": masking.Mask, "phone_number": masking.Mask,
+	}, policy)
+}
+
+func TestFieldPolicy_ReplaceValidates(t *testing.T) {
+	svc := newFieldPolicies()
+	cases := []map[string]masking.Policy{
+		{"janitor": {"email": masking.Mask}},
+		{models.RoleNurse: {"patient_hn": masking.Mask}},
+		{models.RoleNurse: {"email": "hide"}},
+	}
+	for _, rules := range cases {
+		_, err := svc.Replace(2, rules)
+		var validationErr *services.ValidationError
+		require.ErrorAs(t, err, &validationErr, "%v", rules)
+	}
+
+	policy, err := svc.Replace(2, map[string]masking.Policy{
+		models.AnyRole:   {"phone_number": masking.Mask},
+		models.RoleNurse: {"national_id": masking.Omit},
+	})
+	require.NoError(t, err)
+	require.Equal(t, masking.Omit, policy.Rules[models.RoleNurse]["national_id"])
+	require.Equal(t, masking.Mask, policy.Rules[models.AnyRole]["phone_number"])
+	require.NotEmpty(t, policy.Defaults)
+}
+
+func TestPatientHandler_AppliesFieldPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	nid, phone, email, name := "1234567890123", "0812340001", "somchai@example.com", "Somchai"
+	patient := models.Patient{
+		ID: 42, HospitalID: 2, FirstNameEN: &name, NationalID: &nid, PhoneNumber: &phone, Email: &email,
+		DateOfBirth: time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC), PatientHN: "HN1", Gender: models.Male,
+	}
+	mock := &mockPatientService{
+		SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+			return &repositories.PatientPage{Patients: []models.Patient{patient}, NextCursor: "next"}, nil
+		},
+		GetFn: func(hospitalID, id uint) (*models.Patient, error) {
+			p := patient
+			return &p, nil
+		},
+	}
+	policies := newFieldPolicies()
+	_, err := policies.Replace(2, map[string]masking.Policy{models.RoleNurse: {"email": masking.Omit}})
+	require.NoError(t, err)
+
+	ph := handlers.NewPatientHandler(mock, policies)
+	r := gin.New()
+	withRole := func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2, Role: c.GetHeader("X-Test-Role")})
+	}
+	r.GET("/api/patient/search", withRole, ph.Search)
+	r.GET("/api/patient/:patient_id", withRole, ph.Get)
+
+	get := func(path, role string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Test-Role", role)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return body
+	}
+
+	full := get("/api/patient/42", models.RoleAdmin)
+	require.Equal(t, nid, full["national_id"])
+	require.Equal(t, email, full["email"])
+
+	clinical := get("/api/patient/42", models.RoleDoctor)
+	require.Equal(t, "1-2345-XXXXX-XX-3", clinical["national_id"])
+	require.Equal(t, "s******@example.com", clinical["email"])
+	require.Equal(t, "1985-04-12T00:00:00Z", clinical["date_of_birth"])
+
+	audited := get("/api/patient/42", models.RoleAuditor)
+	require.Equal(t, "1-2345-XXXXX-XX-3", audited["national_id"])
+	require.Equal(t, "08X-XXX-0001", audited["phone_number"])
+	require.Equal(t, "s******@example.com", audited["email"])
+	require.Equal(t, "1985-XX-XX", audited["date_of_birth"])
+	require.Equal(t, name, audited["first_name_en"])
+	require.Equal(t, float64(42), audited["id"])
+
+	page := get("/api/patient/search?patient_hn=HN1", models.RoleNurse)
+	require.Equal(t, "next", page["next_cursor"])
+	shaped := page["patients"].([]interface{})[0].(map[string]interface{})
+	require.NotContains(t, shaped, "email")
+	require.Equal(t, "1-2345-XXXXX-XX-3", shaped["national_id"])
+}
+
+func TestFieldPolicyHandler_GetAndReplace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := handlers.NewFieldPolicyHandler(newFieldPolicies())
+	r := gin.New()
+	withHospital := func(c *gin.Context) { c.Set("hospital_id", uint(2)) }
+	r.GET("/api/h/patient-policy", withHospital, h.Get)
+	r.PUT("/api/h/patient-policy", withHospital, h.Replace)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/h/patient-policy", bytes.NewBufferString(body)))
+		return rr
+	}
+	require.Equal(t, http.StatusBadRequest, put(`{}`).Code)
+	require.Equal(t, http.StatusBadRequest, put(`{"rules":{"nurse":{"email":"hide"}}}`).Code)
+	require.Equal(t, http.StatusOK, put(`{"rules":{"nurse":{"email":"omit"},"*":{"phone_number":"mask"}}}`).Code)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/h/patient-policy", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var policy services.FieldPolicy
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &policy))
+	require.Equal(t, masking.Omit, policy.Rules["nurse"]["email"])
+	require.Equal(t, masking.Mask, policy.Defaults[models.RoleAuditor]["national_id"])
+}
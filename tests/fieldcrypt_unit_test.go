@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agnos_candidate_assignment/fieldcrypt"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyring(t *testing.T, ids ...string) (*fieldcrypt.Keyring, string) {
+	var entries []string
+	for _, id := range append(ids, fieldcrypt.IndexKeyID) {
+		key, err := fieldcrypt.GenerateKey()
+		require.NoError(t, err)
+		entries = append(entries, id+"="+key)
+	}
+	spec := strings.Join(entries, ",")
+	keys, err := fieldcrypt.Parse(spec)
+	require.NoError(t, err)
+	return keys, spec
+}
+
+func TestFieldcrypt_SealOpen(t *testing.T) {
+	keys, _ := newKeyring(t, "k1")
+
+	sealed, err := keys.Seal("national_id", "1100700000001")
+	require.NoError(t, err)
+	require.True(t, fieldcrypt.IsSealed(sealed))
+	require.True(t, keys.Current(sealed))
+	require.NotContains(t, sealed, "1100700000001")
+
+	again, err := keys.Seal("national_id", "1100700000001")
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again, "every value gets a fresh data key and nonce")
+
+	plain, err := keys.Open("national_id", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "1100700000001", plain)
+
+	// Unsealed values predate encryption and pass through.
+	plain, err = keys.Open("email", "legacy@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "legacy@example.com", plain)
+}
+
+func TestFieldcrypt_OpenRejects(t *testing.T) {
+	keys, _ := newKeyring(t, "k1")
+	sealed, err := keys.Seal("national_id", "1100700000001")
+	require.NoError(t, err)
+
+	_, err = keys.Open("passport_id", sealed)
+	require.Error(t, err, "a value moved to another column must not open")
+
+	b := []byte(sealed)
+	i := len(b) - 10
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	_, err = keys.Open("national_id", string(b))
+	require.Error(t, err)
+
+	_, err = keys.Open("national_id", fieldcrypt.Prefix+"k1:nope")
+	require.ErrorIs(t, err, fieldcrypt.ErrMalformed)
+
+	other, _ := newKeyring(t, "k2")
+	_, err = other.Open("national_id", sealed)
+	require.ErrorIs(t, err, fieldcrypt.ErrUnknownKey)
+}
+
+func TestFieldcrypt_Rotation(t *testing.T) {
+	old, oldSpec := newKeyring(t, "k1")
+	sealed, err := old.Seal("email", "a@example.com")
+	require.NoError(t, err)
+
+	newKey, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	rotated, err := fieldcrypt.Parse("k2=" + newKey + "\n" + oldSpec)
+	require.NoError(t, err)
+	require.Equal(t, "k2", rotated.ActiveKeyID())
+	require.False(t, rotated.Current(sealed))
+
+	plain, err := rotated.Open("email", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "a@example.com", plain)
+
+	resealed, err := rotated.Seal("email", plain)
+	require.NoError(t, err)
+	require.True(t, rotated.Current(resealed))
+	require.True(t, strings.HasPrefix(resealed, rotated.ActivePrefix()))
+
+	// The index key is shared, so blind indexes survive rotation.
+	require.Equal(t, old.BlindIndex("email", plain), rotated.BlindIndex("email", plain))
+}
+
+func TestFieldcrypt_BlindIndex(t *testing.T) {
+	keys, _ := newKeyring(t, "k1")
+
+	idx := keys.BlindIndex("national_id", "1100700000001")
+	require.Len(t, idx, 32)
+	require.Equal(t, idx, keys.BlindIndex("national_id", "1100700000001"))
+	require.NotEqual(t, idx, keys.BlindIndex("passport_id", "1100700000001"))
+	require.NotEqual(t, idx, keys.BlindIndex("national_id", "1100700000002"))
+
+	other, _ := newKeyring(t, "k1")
+	require.NotEqual(t, idx, other.BlindIndex("national_id", "1100700000001"))
+}
+
+func TestFieldcrypt_Load(t *testing.T) {
+	_, spec := newKeyring(t, "k1", "k0")
+
+	path := filepath.Join(t.TempDir(), "field.keys")
+	content := "# active key first\n" + strings.ReplaceAll(spec, ",", "\n") + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	keys, err := fieldcrypt.Load("", path)
+	require.NoError(t, err)
+	require.Equal(t, "k1", keys.ActiveKeyID())
+
+	_, err = fieldcrypt.Load("", "")
+	require.ErrorIs(t, err, fieldcrypt.ErrNoKeys)
+
+	key, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	_, err = fieldcrypt.Parse("k1=" + key)
+	require.ErrorIs(t, err, fieldcrypt.ErrNoIndexKey)
+	_, err = fieldcrypt.Parse("index=" + key)
+	require.ErrorIs(t, err, fieldcrypt.ErrNoMasterKey)
+	_, err = fieldcrypt.Parse("k1=c2hvcnQ=,index=" + key)
+	require.Error(t, err)
+	_, err = fieldcrypt.Parse("k1=" + key + ",k1=" + key + ",index=" + key)
+	require.Error(t, err)
+}
+
+func TestMFARepository_SecretOpensSealedAndLegacy(t *testing.T) {
+	keys, _ := newKeyring(t, "k1")
+	repo := repositories.NewMFARepository(nil, keys)
+
+	sealed, err := keys.Seal("totp_secret", "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	secret, err := repo.Secret(&models.Staff{TOTPSecret: &sealed})
+	require.NoError(t, err)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	// Secrets stored before encryption still work until sealed at startup.
+	legacy := "JBSWY3DPEHPK3PXP"
+	secret, err = repo.Secret(&models.Staff{TOTPSecret: &legacy})
+	require.NoError(t, err)
+	require.Equal(t, legacy, secret)
+
+	// A secret sealed for another field does not open as a TOTP secret.
+	other, err := keys.Seal("email", "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	_, err = repo.Secret(&models.Staff{TOTPSecret: &other})
+	require.Error(t, err)
+}
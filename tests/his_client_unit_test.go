@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/his/histest"
+	"agnos_candidate_assignment/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hisPatient(hn, nationalID string) his.PatientRecord {
+	return his.PatientRecord{
+		FirstNameTH: "สมชาย",
+		FirstNameEN: "Somchai",
+		LastNameEN:  "Jaidee",
+		DateOfBirth: "1985-04-12",
+		PatientHN:   hn,
+		NationalID:  nationalID,
+		Gender:      "male",
+	}
+}
+
+func TestHISRegistry_NotConfigured(t *testing.T) {
+	reg := his.NewRegistry(time.Second)
+	_, err := reg.ClientFor(&models.Hospital{Name: "No HIS"})
+	require.ErrorIs(t, err, his.ErrNotConfigured)
+}
+
+func TestHISRegistry_UnknownAdapter(t *testing.T) {
+	reg := his.NewRegistry(time.Second)
+	url := "http://localhost"
+	_, err := reg.ClientFor(&models.Hospital{APIURL: &url, HISAdapter: "nope"})
+	require.ErrorIs(t, err, his.ErrUnknownAdapter)
+}
+
+func TestAgnosClient_GetPatient_Normalizes(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN1", "1100700000001"))
+	defer srv.Close()
+
+	client, err := his.NewRegistry(time.Second).ClientFor(&models.Hospital{APIURL: &srv.URL})
+	require.NoError(t, err)
+
+	p, err := client.GetPatient("1100700000001")
+	require.NoError(t, err)
+	require.Equal(t, "HN1", p.PatientHN)
+	require.Equal(t, models.Male, p.Gender)
+	require.Equal(t, time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC), p.DateOfBirth)
+	require.Nil(t, p.PassportID)
+}
+
+func TestAgnosClient_GetPatient_NotFound(t *testing.T) {
+	srv := histest.NewServer()
+	defer srv.Close()
+
+	client, err := his.NewRegistry(time.Second).ClientFor(&models.Hospital{APIURL: &srv.URL})
+	require.NoError(t, err)
+
+	_, err = client.GetPatient("missing")
+	require.ErrorIs(t, err, his.ErrNotFound)
+}
+
+func TestAgnosClient_Search_SkipsInvalidRecords(t *testing.T) {
+	bad := hisPatient("", "1100700000019")
+	srv := histest.NewServer(hisPatient("HN1", "1100700000001"), bad)
+	defer srv.Close()
+
+	client, err := his.NewRegistry(time.Second).ClientFor(&models.Hospital{APIURL: &srv.URL})
+	require.NoError(t, err)
+
+	patients, err := client.SearchPatients(map[string]interface{}{"first_name": "Somchai"})
+	require.NoError(t, err)
+	require.Len(t, patients, 1)
+	require.Equal(t, "HN1", patients[0].PatientHN)
+}
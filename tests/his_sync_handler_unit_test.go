@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type mockHISSyncService struct {
+	TriggerFn func(hospitalID uint) (*models.HISSyncRun, error)
+	LastRunFn func(hospitalID uint) (*models.HISSyncRun, error)
+}
+
+func (m *mockHISSyncService) Trigger(hospitalID uint) (*models.HISSyncRun, error) {
+	return m.TriggerFn(hospitalID)
+}
+
+func (m *mockHISSyncService) LastRun(hospitalID uint) (*models.HISSyncRun, error) {
+	return m.LastRunFn(hospitalID)
+}
+
+func newHISSyncRouter(mock *mockHISSyncService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := handlers.NewHISSyncHandler(mock)
+	r := gin.New()
+	withHospital := func(c *gin.Context) { c.Set("hospital_id", uint(2)) }
+	r.POST("/api/h/his/sync", withHospital, h.Trigger)
+	r.GET("/api/h/his/sync", withHospital, h.Status)
+	return r
+}
+
+func TestHISSyncTrigger_Accepted(t *testing.T) {
+	r := newHISSyncRouter(&mockHISSyncService{TriggerFn: func(hospitalID uint) (*models.HISSyncRun, error) {
+		return &models.HISSyncRun{ID: 1, HospitalID: hospitalID, Status: models.HISSyncRunning}, nil
+	}})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/h/his/sync", nil))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+}
+
+func TestHISSyncTrigger_AlreadyRunning(t *testing.T) {
+	r := newHISSyncRouter(&mockHISSyncService{TriggerFn: func(hospitalID uint) (*models.HISSyncRun, error) {
+		return nil, services.ErrSyncInProgress
+	}})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/h/his/sync", nil))
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestHISSyncStatus_NoRuns(t *testing.T) {
+	r := newHISSyncRouter(&mockHISSyncService{LastRunFn: func(hospitalID uint) (*models.HISSyncRun, error) {
+		return nil, errors.New("record not found")
+	}})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/h/his/sync", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/his/histest"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/stretchr/testify/require"
+)
+
+type syncHospitalRepo struct {
+	mockHospitalRepo
+	hospitals []models.Hospital
+	cursors   map[uint]time.Time
+}
+
+func (m *syncHospitalRepo) List() ([]models.Hospital, error) {
+	return m.hospitals, nil
+}
+
+func (m *syncHospitalRepo) UpdateHISCursor(id uint, cursor time.Time) error {
+	m.cursors[id] = cursor
+	return nil
+}
+
+type mockSyncRepo struct {
+	runs []models.HISSyncRun
+}
+
+func (m *mockSyncRepo) CreateRun(run *models.HISSyncRun) error {
+	run.ID = uint(len(m.runs) + 1)
+	m.runs = append(m.runs, *run)
+	return nil
+}
+
+func (m *mockSyncRepo) SaveRun(run *models.HISSyncRun) error {
+	m.runs[run.ID-1] = *run
+	return nil
+}
+
+func (m *mockSyncRepo) LastRun(hospitalID uint) (*models.HISSyncRun, error) {
+	if len(m.runs) == 0 {
+		return nil, errors.New("record not found")
+	}
+	run := m.runs[len(m.runs)-1]
+	return &run, nil
+}
+
+func changedPatient(hn, updatedAt string) his.PatientRecord {
+	rec := hisPatient(hn, "")
+	rec.UpdatedAt = updatedAt
+	return rec
+}
+
+func TestHISSync_AdvancesCursorIncrementally(t *testing.T) {
+	srv := histest.NewServer(
+		changedPatient("HN1", "2024-01-01T00:00:00Z"),
+		changedPatient("HN2", "2024-01-02T00:00:00Z"),
+	)
+	defer srv.Close()
+
+	hospRepo := &syncHospitalRepo{
+		hospitals: []models.Hospital{{ID: 1, APIURL: &srv.URL}, {ID: 2}},
+		cursors:   map[uint]time.Time{},
+	}
+	patientRepo := &mockPatientRepo{}
+	syncRepo := &mockSyncRepo{}
+	svc := services.NewHISSyncService(hospRepo, patientRepo, syncRepo, his.NewRegistry(time.Second))
+
+	svc.SyncAll()
+	require.Len(t, syncRepo.runs, 1)
+	require.Equal(t, models.HISSyncSucceeded, syncRepo.runs[0].Status)
+	require.Equal(t, 2, syncRepo.runs[0].Upserted)
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), hospRepo.cursors[1].UTC())
+	require.Equal(t, uint(1), patientRepo.upserted[0].HospitalID)
+
+	srv.Add(changedPatient("HN3", "2024-01-03T00:00:00Z"))
+	svc.SyncAll()
+	require.Len(t, syncRepo.runs, 2)
+	require.Equal(t, 1, syncRepo.runs[1].Fetched)
+	require.Equal(t, "HN3", patientRepo.upserted[2].PatientHN)
+}
+
+func TestHISSync_SkipsDeactivatedHospitals(t *testing.T) {
+	srv := histest.NewServer(changedPatient("HN1", "2024-01-01T00:00:00Z"))
+	defer srv.Close()
+
+	deactivated := time.Now()
+	hospRepo := &syncHospitalRepo{
+		hospitals: []models.Hospital{{ID: 1, APIURL: &srv.URL, DeactivatedAt: &deactivated}},
+		cursors:   map[uint]time.Time{},
+	}
+	syncRepo := &mockSyncRepo{}
+	svc := services.NewHISSyncService(hospRepo, &mockPatientRepo{}, syncRepo, his.NewRegistry(time.Second))
+
+	svc.SyncAll()
+	require.Empty(t, syncRepo.runs)
+}
+
+func TestHISSync_HISDownRecordsFailure(t *testing.T) {
+	srv := histest.NewServer()
+	url := srv.URL
+	srv.Close()
+
+	hospRepo := &syncHospitalRepo{
+		hospitals: []models.Hospital{{ID: 1, APIURL: &url}},
+		cursors:   map[uint]time.Time{},
+	}
+	syncRepo := &mockSyncRepo{}
+	svc := services.NewHISSyncService(hospRepo, &mockPatientRepo{}, syncRepo, his.NewRegistry(time.Second))
+
+	svc.SyncAll()
+	require.Len(t, syncRepo.runs, 1)
+	require.Equal(t, models.HISSyncFailed, syncRepo.runs[0].Status)
+	require.NotEmpty(t, syncRepo.runs[0].Error)
+	require.Empty(t, hospRepo.cursors)
+}
+
+// upsertFailRepo fails the upsert of one HN with err.
+type upsertFailRepo struct {
+	mockPatientRepo
+	hn  string
+	err error
+}
+
+func (m *upsertFailRepo) Upsert(p *models.Patient) error {
+	if p.PatientHN == m.hn {
+		return m.err
+	}
+	return m.mockPatientRepo.Upsert(p)
+}
+
+func TestHISSync_DuplicateDoesNotHoldCursorBack(t *testing.T) {
+	srv := histest.NewServer(
+		changedPatient("HN1", "2024-01-01T00:00:00Z"),
+		changedPatient("HN2", "2024-01-02T00:00:00Z"),
+	)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		err     error
+		advance bool
+	}{
+		{&repositories.DuplicateKeyError{Field: "national_id"}, true},
+		{errors.New("connection reset"), false},
+	} {
+		hospRepo := &syncHospitalRepo{
+			hospitals: []models.Hospital{{ID: 1, APIURL: &srv.URL}},
+			cursors:   map[uint]time.Time{},
+		}
+		syncRepo := &mockSyncRepo{}
+		svc := services.NewHISSyncService(hospRepo, &upsertFailRepo{hn: "HN1", err: tc.err}, syncRepo, his.NewRegistry(time.Second))
+
+		svc.SyncAll()
+		require.Len(t, syncRepo.runs, 1)
+		require.Equal(t, 1, syncRepo.runs[0].Upserted)
+		require.Equal(t, 1, syncRepo.runs[0].Failed)
+		_, advanced := hospRepo.cursors[1]
+		require.Equal(t, tc.advance, advanced, tc.err.Error())
+	}
+}
+
+// blockingPatientRepo holds every upsert until release is closed.
+type blockingPatientRepo struct {
+	mockPatientRepo
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingPatientRepo) Upsert(p *models.Patient) error {
+	m.started <- struct{}{}
+	<-m.release
+	return m.mockPatientRepo.Upsert(p)
+}
+
+func TestHISSync_StopWaitsForManualRun(t *testing.T) {
+	srv := histest.NewServer(changedPatient("HN1", "2024-01-01T00:00:00Z"))
+	defer srv.Close()
+
+	hospRepo := &syncHospitalRepo{
+		hospitals: []models.Hospital{{ID: 1, APIURL: &srv.URL}},
+		cursors:   map[uint]time.Time{},
+	}
+	hospital := hospRepo.hospitals[0]
+	patientRepo := &blockingPatientRepo{started: make(chan struct{}, 1), release: make(chan struct{})}
+	svc := services.NewHISSyncService(&stubHospitalRepo{hospital: &hospital}, patientRepo, &mockSyncRepo{}, his.NewRegistry(time.Second))
+
+	_, err := svc.Trigger(1)
+	require.NoError(t, err)
+	<-patientRepo.started
+
+	stopped := make(chan struct{})
+	go func() {
+		svc.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a manual run was still writing")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(patientRepo.release)
+	<-stopped
+	require.Len(t, patientRepo.upserted, 1)
+
+	_, err = svc.Trigger(1)
+	require.ErrorIs(t, err, services.ErrSyncStopped)
+}
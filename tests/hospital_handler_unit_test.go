@@ -7,16 +7,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"agnos_candidate_assignment/handlers"
 	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
 
 type mockHospitalRepo struct {
-	CreateFn func(h *models.Hospital) error
+	CreateFn    func(h *models.Hospital) error
+	RenameFn    func(id uint, name string) error
+	SetActiveFn func(id uint, at *time.Time) error
+	DeleteFn    func(id uint) error
+	SecurityFn  func(id uint, settings repositories.HospitalSecurity) error
 }
 
 func (m *mockHospitalRepo) Create(h *models.Hospital) error {
@@ -31,6 +37,30 @@ func (m *mockHospitalRepo) FindByID(id uint) (*models.Hospital, error) {
 	return nil, nil
 }
 
+func (m *mockHospitalRepo) List() ([]models.Hospital, error) {
+	return nil, nil
+}
+
+func (m *mockHospitalRepo) UpdateHISCursor(id uint, cursor time.Time) error {
+	return nil
+}
+
+func (m *mockHospitalRepo) Rename(id uint, name string) error {
+	return m.RenameFn(id, name)
+}
+
+func (m *mockHospitalRepo) SetDeactivatedAt(id uint, at *time.Time) error {
+	return m.SetActiveFn(id, at)
+}
+
+func (m *mockHospitalRepo) UpdateSecurity(id uint, settings repositories.HospitalSecurity) error {
+	return m.SecurityFn(id, settings)
+}
+
+func (m *mockHospitalRepo) Delete(id uint) error {
+	return m.DeleteFn(id)
+}
+
 func TestHospitalCreate_Positive(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mock := &mockHospitalRepo{CreateFn: func(h *models.Hospital) error {
@@ -41,11 +71,11 @@ func TestHospitalCreate_Positive(t *testing.T) {
 	hh := handlers.NewHospitalHandler(mock)
 
 	router := gin.New()
-	router.POST("/api/hospital", hh.Create)
+	router.POST("/api/admin/hospitals", hh.Create)
 
 	body := map[string]string{"name": "UT Hospital"}
 	b, _ := json.Marshal(body)
-	req := httptest.NewRequest(http.MethodPost, "/api/hospital", bytes.NewReader(b))
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/hospitals", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -65,11 +95,11 @@ func TestHospitalCreate_NegativeCreateError(t *testing.T) {
 
 	hh := handlers.NewHospitalHandler(&struct{ *mockHospitalRepo }{mock})
 	router := gin.New()
-	router.POST("/api/hospital", hh.Create)
+	router.POST("/api/admin/hospitals", hh.Create)
 
 	body := map[string]string{"name": "UT Hospital"}
 	b, _ := json.Marshal(body)
-	req := httptest.NewRequest(http.MethodPost, "/api/hospital", bytes.NewReader(b))
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/hospitals", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -85,12 +115,137 @@ func TestHospitalCreate_BadRequestBinding(t *testing.T) {
 
 	hh := handlers.NewHospitalHandler(mock)
 	router := gin.New()
-	router.POST("/api/hospital", hh.Create)
+	router.POST("/api/admin/hospitals", hh.Create)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/hospital", bytes.NewReader([]byte(`{}`)))
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/hospitals", bytes.NewReader([]byte(`{}`)))
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 }
+
+type adminHospitalRepo struct {
+	mockHospitalRepo
+	hospitals map[uint]*models.Hospital
+}
+
+func (m *adminHospitalRepo) FindByID(id uint) (*models.Hospital, error) {
+	if h, ok := m.hospitals[id]; ok {
+		return h, nil
+	}
+	return nil, repositories.ErrHospitalNotFound
+}
+
+func newAdminHospitalRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := &adminHospitalRepo{hospitals: map[uint]*models.Hospital{
+		1: {ID: 1, Name: "Hospital A"},
+		2: {ID: 2, Name: "Hospital B"},
+	}}
+	repo.RenameFn = func(id uint, name string) error {
+		if name == "Hospital B" {
+			return &repositories.DuplicateKeyError{Field: "name"}
+		}
+		if _, ok := repo.hospitals[id]; !ok {
+			return repositories.ErrHospitalNotFound
+		}
+		repo.hospitals[id].Name = name
+		return nil
+	}
+	repo.SetActiveFn = func(id uint, at *time.Time) error {
+		repo.hospitals[id].DeactivatedAt = at
+		return nil
+	}
+	repo.SecurityFn = func(id uint, settings repositories.HospitalSecurity) error {
+		h, ok := repo.hospitals[id]
+		if !ok {
+			return repositories.ErrHospitalNotFound
+		}
+		if settings.RequireMFA != nil {
+			h.RequireMFA = *settings.RequireMFA
+		}
+		if settings.RequireStaffApproval != nil {
+			h.RequireStaffApproval = *settings.RequireStaffApproval
+		}
+		return nil
+	}
+	repo.DeleteFn = func(id uint) error {
+		if id == 1 {
+			return repositories.ErrHospitalInUse
+		}
+		return nil
+	}
+
+	hh := handlers.NewHospitalHandler(repo)
+	r := gin.New()
+	r.POST("/api/admin/hospitals", hh.Create)
+	r.PATCH("/api/admin/hospitals/:hospital_id", hh.Rename)
+	r.POST("/api/admin/hospitals/:hospital_id/deactivate", hh.Deactivate)
+	r.POST("/api/admin/hospitals/:hospital_id/activate", hh.Activate)
+	r.PATCH("/api/admin/hospitals/:hospital_id/security", hh.UpdateSecurity)
+	r.DELETE("/api/admin/hospitals/:hospital_id", hh.Delete)
+	return r
+}
+
+func TestHospitalAdmin_Endpoints(t *testing.T) {
+	r := newAdminHospitalRouter()
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/admin/hospitals", `{"name":"admin"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/admin/hospitals", `{"name":"42"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/api/admin/hospitals/1", `{"name":"Hospital A North"}`, http.StatusOK},
+		{http.MethodPatch, "/api/admin/hospitals/1", `{"name":"Hospital B"}`, http.StatusConflict},
+		{http.MethodPatch, "/api/admin/hospitals/9", `{"name":"Hospital Z"}`, http.StatusNotFound},
+		{http.MethodPatch, "/api/admin/hospitals/x", `{"name":"Hospital Z"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/admin/hospitals/1", ``, http.StatusConflict},
+		{http.MethodDelete, "/api/admin/hospitals/2", ``, http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(rr, req)
+		require.Equal(t, tc.want, rr.Code, "%s %s %s", tc.method, tc.path, tc.body)
+	}
+}
+
+func TestHospitalAdmin_DeactivateAndActivate(t *testing.T) {
+	r := newAdminHospitalRouter()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/hospitals/2/deactivate", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp models.Hospital
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.False(t, resp.Active())
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/hospitals/2/activate", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = models.Hospital{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.True(t, resp.Active())
+}
+
+func TestHospitalAdmin_UpdateSecurity(t *testing.T) {
+	r := newAdminHospitalRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/api/admin/hospitals/2/security", bytes.NewReader([]byte(`{"require_mfa":true}`)))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp models.Hospital
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.True(t, resp.RequireMFA)
+	require.False(t, resp.RequireStaffApproval)
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPatch, "/api/admin/hospitals/9/security", bytes.NewReader([]byte(`{"require_mfa":true}`)))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
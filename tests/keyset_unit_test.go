@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/keyset"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeys(t *testing.T) *keyset.Set {
+	t.Helper()
+	keys := keyset.NewSet(time.Hour)
+	m, err := keyset.NewManager(keys, "", keyset.EdDSA, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Tick(time.Now()))
+	return keys
+}
+
+func verify(keys *keyset.Set, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc, jwt.WithValidMethods(keys.Algorithms()))
+	return err
+}
+
+func TestKeyset_SignsWithKidAndVerifies(t *testing.T) {
+	for _, alg := range keyset.Supported {
+		keys := keyset.NewSet(time.Hour)
+		m, err := keyset.NewManager(keys, "", alg, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, m.Tick(time.Now()))
+
+		token, err := keys.Sign(jwt.MapClaims{"sub": "1"})
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		require.NoError(t, err)
+		require.Equal(t, keys.Active().ID, parsed.Header["kid"])
+		require.Equal(t, alg, parsed.Header["alg"])
+		require.NoError(t, verify(keys, token), alg)
+
+		jwks := keys.JWKS()
+		require.Len(t, jwks.Keys, 1)
+		require.Equal(t, alg, jwks.Keys[0].Alg)
+	}
+}
+
+func TestKeyset_RejectsForeignAndDowngradedTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	other := newTestKeys(t)
+
+	foreign, err := other.Sign(jwt.MapClaims{"sub": "1"})
+	require.NoError(t, err)
+	require.Error(t, verify(keys, foreign))
+
+	// HS256 signed with the public key bytes, under a known kid.
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT","kid":"` + keys.Active().ID + `"}`))
+	body := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1"}`))
+	mac := hmac.New(sha256.New, []byte(keys.JWKS().Keys[0].X))
+	mac.Write([]byte(header + "." + body))
+	forged := header + "." + body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	require.Error(t, verify(keys, forged))
+}
+
+func TestKeyset_PinsVerificationToItsKeysAlgorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	require.Equal(t, []string{keyset.EdDSA}, keys.Algorithms())
+
+	// An RS256 token is refused before its kid is even looked up.
+	rsa := keyset.NewSet(time.Hour)
+	m, err := keyset.NewManager(rsa, "", keyset.RS256, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Tick(time.Now()))
+	token, err := rsa.Sign(jwt.MapClaims{"sub": "1"})
+	require.NoError(t, err)
+	require.ErrorIs(t, verify(keys, token), jwt.ErrTokenSignatureInvalid)
+
+	// After a change of algorithm, the old key's tokens still verify until
+	// it is retired.
+	now := time.Now()
+	old, err := keyset.NewKey("old", rsa.Active().Private, now.Add(-time.Minute))
+	require.NoError(t, err)
+	current, err := keyset.NewKey("current", keys.Active().Private, now)
+	require.NoError(t, err)
+	both := keyset.NewSet(time.Hour)
+	both.Replace([]*keyset.Key{old}, now)
+	token, err = both.Sign(jwt.MapClaims{"sub": "1"})
+	require.NoError(t, err)
+	both.Replace([]*keyset.Key{old, current}, now)
+	require.ElementsMatch(t, keyset.Supported, both.Algorithms())
+	require.NoError(t, verify(both, token))
+}
+
+func TestKeyset_RotationKeepsOldKeyUntilRetention(t *testing.T) {
+	dir := t.TempDir()
+	keys := keyset.NewSet(time.Hour)
+	m, err := keyset.NewManager(keys, dir, keyset.EdDSA, 24*time.Hour, 2*time.Minute)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, m.Tick(start))
+	first := keys.Active().ID
+	oldToken, err := keys.Sign(jwt.MapClaims{"sub": "1"})
+	require.NoError(t, err)
+
+	// Due: a successor is published but only signs after the lead.
+	now := time.Now()
+	require.NoError(t, m.Tick(now))
+	require.Len(t, keys.Keys(), 2)
+	require.Equal(t, first, keys.Active().ID)
+	require.Len(t, keys.JWKS().Keys, 2)
+
+	// Another instance reading the same directory sees both keys.
+	peer := keyset.NewSet(time.Hour)
+	pm, err := keyset.NewManager(peer, dir, keyset.EdDSA, 24*time.Hour, 2*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, pm.Tick(now))
+	require.Len(t, peer.Keys(), 2)
+	require.NoError(t, verify(peer, oldToken))
+
+	// Within retention of the successor's activation the old key verifies;
+	// after it, the key and its file are gone.
+	require.NoError(t, m.Tick(now.Add(30*time.Minute)))
+	require.NoError(t, verify(keys, oldToken))
+	require.NoError(t, m.Tick(now.Add(2*time.Hour)))
+	require.Len(t, keys.Keys(), 1)
+	require.Error(t, verify(keys, oldToken))
+	_, err = os.Stat(filepath.Join(dir, first+".pem"))
+	require.True(t, os.IsNotExist(err))
+
+	info, err := os.Stat(filepath.Join(dir, keys.Active().ID+".pem"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestKeyset_RejectsBadConfig(t *testing.T) {
+	_, err := keyset.NewManager(keyset.NewSet(time.Hour), "", "HS256", 0, 0)
+	require.Error(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a key"), 0o600))
+	m, err := keyset.NewManager(keyset.NewSet(time.Hour), dir, keyset.EdDSA, 0, 0)
+	require.NoError(t, err)
+	err = m.Tick(time.Now())
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "bad.pem"))
+}
+
+func TestKeyset_LoadOnlyReadsTheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	_, err := keyset.Load(dir, time.Now())
+	require.Error(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// A key long past rotation still signs, and nothing is added or removed.
+	m, err := keyset.NewManager(keyset.NewSet(time.Hour), dir, keyset.EdDSA, time.Hour, 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Tick(time.Now().Add(-48*time.Hour)))
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	keys, err := keyset.Load(dir, time.Now())
+	require.NoError(t, err)
+	token, err := keys.Sign(jwt.MapClaims{"sub": "1"})
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	require.Equal(t, strings.TrimSuffix(entries[0].Name(), ".pem"), parsed.Header["kid"])
+
+	after, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+
+	"agnos_candidate_assignment/masking"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMasking(t *testing.T) {
+	require.Equal(t, "S******", masking.Name("Somchai"))
+	require.Equal(t, "ส****", masking.Name("สมชาย"))
+	require.Equal(t, "M*** J***", masking.Name("Mary Jane"))
+	require.Equal(t, "L*", masking.Name("Li"))
+	require.Equal(t, "*", masking.Name("A"))
+	require.Equal(t, "", masking.Name(""))
+
+	require.Equal(t, "******5678", masking.Phone("081-234-5678"))
+	require.Equal(t, "123", masking.Phone("123"))
+
+	require.Equal(t, "*********0001", masking.ID("1-1007-00000-00-1"))
+	require.Equal(t, "*****4567", masking.ID("AA1234567"))
+
+	require.Equal(t, "s******@example.com", masking.Email("somchai@example.com"))
+	require.Equal(t, "*@example.com", masking.Email("s@example.com"))
+}
+
+func TestMasking_Grouped(t *testing.T) {
+	require.Equal(t, "1-2345-XXXXX-XX-3", masking.NationalID("1234567890123"))
+	require.Equal(t, "1-1007-XXXXX-XX-1", masking.NationalID("1-1007-00000-00-1"))
+	require.Equal(t, "*****4567", masking.NationalID("AA1234567"))
+
+	require.Equal(t, "08X-XXX-0001", masking.GroupedPhone("0812340001"))
+	require.Equal(t, "08X-XXX-5678", masking.GroupedPhone("081-234-5678"))
+	require.Equal(t, "*****4567", masking.GroupedPhone("021234567"))
+
+	require.Equal(t, "1985-XX-XX", masking.BirthDate("1985-04-12T00:00:00Z"))
+}
+
+func TestMaskingPolicy_Apply(t *testing.T) {
+	patient := map[string]interface{}{
+		"id":            42,
+		"national_id":   "1234567890123",
+		"phone_number":  "0812340001",
+		"email":         "somchai@example.com",
+		"first_name_en": "Somchai",
+		"passport_id":   nil,
+	}
+	policy := masking.Policy{
+		"national_id":  masking.Mask,
+		"phone_number": masking.Mask,
+		"email":        masking.Omit,
+		"passport_id":  masking.Mask,
+		"last_name_en": masking.Omit,
+	}
+	require.False(t, policy.Full())
+	policy.Apply(patient)
+
+	require.Equal(t, map[string]interface{}{
+		"id":            42,
+		"national_id":   "1-2345-XXXXX-XX-3",
+		"phone_number":  "08X-XXX-0001",
+		"first_name_en": "Somchai",
+		"passport_id":   nil,
+	}, patient)
+
+	require.True(t, masking.Policy{"email": masking.Full}.Full())
+	require.True(t, masking.IsPatientField("date_of_birth"))
+	require.False(t, masking.IsPatientField("patient_hn"))
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/database"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// migrationTestDB connects to TEST_DATABASE_URL without migrating; see
+// row_security_integration_test.go.
+func migrationTestDB(t *testing.T) *gorm.DB {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := database.NewPostgresConnectionNoMigrate(&config.Config{DatabaseUrl: url})
+	require.NoError(t, err)
+	return db
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := migrationTestDB(t)
+	migrations, err := database.Migrations()
+	require.NoError(t, err)
+	migrator := database.NewMigrator(db, migrations)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	require.Empty(t, applied, "a second up has nothing to do")
+
+	states, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, states, len(migrations))
+	for _, s := range states {
+		require.NotNil(t, s.AppliedAt)
+		require.False(t, s.Modified)
+		require.False(t, s.Missing)
+	}
+
+	last := migrations[len(migrations)-1]
+	reverted, err := migrator.Down(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, last.Version, reverted[0].Version)
+
+	states, err = migrator.Status()
+	require.NoError(t, err)
+	require.Nil(t, states[len(states)-1].AppliedAt)
+
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, last.Version, applied[0].Version)
+}
+
+func TestMigrator_RefusesDrift(t *testing.T) {
+	db := migrationTestDB(t)
+	migrations, err := database.Migrations()
+	require.NoError(t, err)
+	_, err = database.NewMigrator(db, migrations).Up()
+	require.NoError(t, err)
+
+	edited := append([]database.Migration(nil), migrations...)
+	edited[0].Checksum = "0000000000000000000000000000000000000000000000000000000000000000"
+	_, err = database.NewMigrator(db, edited).Up()
+	require.ErrorIs(t, err, database.ErrChecksumMismatch)
+	_, err = database.NewMigrator(db, edited).Down(1)
+	require.ErrorIs(t, err, database.ErrChecksumMismatch)
+
+	older := migrations[:len(migrations)-1]
+	_, err = database.NewMigrator(db, older).Up()
+	require.ErrorIs(t, err, database.ErrUnknownMigration)
+
+	states, err := database.NewMigrator(db, older).Status()
+	require.NoError(t, err)
+	require.True(t, states[len(states)-1].Missing)
+}
+
+// A migration replayed by hand against a database that already has it, such
+// as after a partial run, must not fail on objects it created.
+func TestMigration0014_Reruns(t *testing.T) {
+	db := migrationTestDB(t)
+	migrations, err := database.Migrations()
+	require.NoError(t, err)
+	_, err = database.NewMigrator(db, migrations).Up()
+	require.NoError(t, err)
+
+	for _, mig := range migrations {
+		if mig.Version == 14 {
+			require.NoError(t, db.Exec(mig.Up).Error)
+			return
+		}
+	}
+	t.Fatal("migration 0014 not found")
+}
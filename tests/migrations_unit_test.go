@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"agnos_candidate_assignment/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Load(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t (c);")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+		"0001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (c int);")},
+		"0001_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+		"README.md":               {Data: []byte("not a migration")},
+	}
+	migrations, err := database.LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.EqualValues(t, 1, migrations[0].Version)
+	require.Equal(t, "create_t", migrations[0].Name)
+	require.Equal(t, "DROP TABLE t;", migrations[0].Down)
+	require.EqualValues(t, 2, migrations[1].Version)
+	require.Len(t, migrations[0].Checksum, 64)
+	require.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+
+	// The checksum covers the up file only.
+	fsys["0001_create_t.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE IF EXISTS t;")}
+	again, err := database.LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Equal(t, migrations[0].Checksum, again[0].Checksum)
+	fsys["0001_create_t.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE t (c bigint);")}
+	again, err = database.LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.NotEqual(t, migrations[0].Checksum, again[0].Checksum)
+}
+
+func TestMigrations_LoadRejects(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {
+			"0001_create_t.up.sql": {Data: []byte("CREATE TABLE t (c int);")},
+		},
+		"empty up": {
+			"0001_create_t.up.sql":   {Data: []byte("  \n")},
+			"0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+		"bad name": {
+			"create_t.up.sql": {Data: []byte("CREATE TABLE t (c int);")},
+		},
+		"version named twice": {
+			"0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (c int);")},
+			"0001_create_u.down.sql": {Data: []byte("DROP TABLE u;")},
+		},
+	} {
+		_, err := database.LoadMigrations(fsys)
+		require.Error(t, err, name)
+	}
+}
+
+func TestMigrations_BuiltIn(t *testing.T) {
+	migrations, err := database.Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		require.EqualValues(t, i+1, m.Version, "versions run 1, 2, 3, ... without gaps")
+		require.NotEmpty(t, m.Up)
+		require.NotEmpty(t, m.Down)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"agnos_candidate_assignment/password"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	p := &password.Policy{MinLength: 10, MinClasses: 3}
+	n, err := p.ReadBreached(strings.NewReader(strings.Join([]string{
+		"# leaked",
+		"Password123!",
+		"",
+		// SHA-1 of "Summer2024!x", in Pwned Passwords format.
+		"2ac347c398d658ae5a3be32eff3a4b15c587d787:12",
+	}, "\n")))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Short1!", false},
+		{"alllowercaseletters", false},
+		{"lowercase and digits 123", true},
+		{"Password123!", false},
+		{"nurse.somchai", false},
+		{"Nurse.Somchai", false},
+		{"Nurse.Somchai.1", true},
+		{"Summer2024!x", false},
+		{strings.Repeat("Aa1", 25), false},
+	}
+	for _, tc := range cases {
+		err := p.Check(tc.password, "nurse.somchai")
+		if tc.ok {
+			require.NoError(t, err, tc.password)
+			continue
+		}
+		var policyErr *password.Error
+		require.ErrorAs(t, err, &policyErr, tc.password)
+	}
+}
+
+func TestPasswordPolicy_BreachedSHA1(t *testing.T) {
+	p := &password.Policy{}
+	// SHA-1 of "password".
+	_, err := p.ReadBreached(strings.NewReader("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"))
+	require.NoError(t, err)
+	require.Error(t, p.Check("password", ""))
+	require.NoError(t, p.Check("passw0rd", ""))
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	low := password.Hasher{Cost: bcrypt.MinCost}
+	hash, err := low.Hash("Str0ng-enough")
+	require.NoError(t, err)
+	require.NoError(t, low.Compare(hash, "Str0ng-enough"))
+	require.Error(t, low.Compare(hash, "wrong"))
+	require.False(t, low.NeedsRehash(hash))
+
+	require.True(t, password.Hasher{Cost: bcrypt.MinCost + 1}.NeedsRehash(hash))
+	require.True(t, low.NeedsRehash("not a bcrypt hash"))
+}
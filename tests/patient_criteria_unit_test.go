@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/translit"
+
+	"github.com/stretchr/testify/require"
+)
+
+// plainIndex stands in for the repository's blind index so tests can see
+// which column and value each lookup used.
+func plainIndex(column, value string) string {
+	return column + ":" + value
+}
+
+func TestPatientCriteria_Where_BindsValues(t *testing.T) {
+	evil := "x' OR 1=1 --"
+	criteria := repositories.PatientSearchCriteria{
+		PatientHN: evil,
+		Email:     evil,
+		FirstName: evil,
+		Gender:    models.Male,
+	}
+
+	sql, args := criteria.Where(plainIndex, time.Now())
+	require.NotContains(t, sql, evil)
+	require.NotContains(t, sql, "1=1")
+	require.Contains(t, args, evil)
+	require.Contains(t, sql, "patient_hn = ?")
+	require.Contains(t, sql, "gender = ?")
+}
+
+func TestPatientCriteria_Where_Groups(t *testing.T) {
+	criteria := repositories.PatientSearchCriteria{
+		Gender: models.Female,
+		AnyOf: []repositories.PatientSearchCriteria{
+			{LastNameEN: "Jaidee"},
+			{PhoneNumber: "0812345678"},
+		},
+	}
+
+	sql, args := criteria.Where(plainIndex, time.Now())
+	require.Equal(t, "(gender = ? AND ((last_name_en_bidx = ?) OR phone_number_bidx = ?))", sql)
+	require.Equal(t, []interface{}{"F", "last_name_en:Jaidee", "phone_number:0812345678"}, args)
+}
+
+func TestPatientCriteria_Where_EncryptedColumns(t *testing.T) {
+	criteria := repositories.PatientSearchCriteria{NationalID: "1100700000001", FirstName: "Somchai"}
+	key := translit.Key("Somchai")
+
+	sql, args := criteria.Where(plainIndex, time.Now())
+	require.Equal(t, "(national_id_bidx = ? AND (first_name_th_bidx = ? OR first_name_en_bidx = ? OR first_name_th_key = ? OR first_name_en_key = ?))", sql)
+	require.Equal(t, []interface{}{"national_id:1100700000001", "first_name_th:Somchai", "first_name_en:Somchai", "first_name_th_key:" + key, "first_name_en_key:" + key}, args)
+	require.NotContains(t, args, "1100700000001")
+	require.NotContains(t, args, key)
+}
+
+func TestPatientCriteria_Where_PartialNamesUseKeyTrigrams(t *testing.T) {
+	// translit.Key("Jaidee") is "caidi".
+	criteria := repositories.PatientSearchCriteria{LastNameTH: "Jaidee", Match: repositories.MatchPrefix}
+	sql, args := criteria.Where(plainIndex, time.Now())
+	require.Equal(t, "(last_name_th_grams @> ?::text[])", sql)
+	require.Equal(t, []interface{}{models.TokenSet{
+		"last_name_th_grams:^^c", "last_name_th_grams:^ca", "last_name_th_grams:cai",
+		"last_name_th_grams:aid", "last_name_th_grams:idi",
+	}}, args)
+
+	criteria = repositories.PatientSearchCriteria{LastNameEN: "Jaidee", Match: repositories.MatchContains}
+	_, args = criteria.Where(plainIndex, time.Now())
+	require.Equal(t, []interface{}{models.TokenSet{
+		"last_name_en_grams:cai", "last_name_en_grams:aid", "last_name_en_grams:idi",
+	}}, args)
+
+	// Too short to hold a trigram, so it matches as a prefix.
+	criteria = repositories.PatientSearchCriteria{LastNameEN: "Ja", Match: repositories.MatchContains}
+	_, args = criteria.Where(plainIndex, time.Now())
+	require.Equal(t, []interface{}{models.TokenSet{"last_name_en_grams:^^c", "last_name_en_grams:^ca"}}, args)
+
+	criteria = repositories.PatientSearchCriteria{LastNameEN: "Jaidee", Match: repositories.MatchFuzzy}
+	sql, _ = criteria.Where(plainIndex, time.Now())
+	require.Equal(t, "((last_name_en_grams && ?::text[] AND token_similarity(last_name_en_grams, ?::text[]) >= ?))", sql)
+
+	// A value with no phonetic key cannot match partially.
+	criteria = repositories.PatientSearchCriteria{MiddleName: "123", Match: repositories.MatchContains}
+	sql, args = criteria.Where(plainIndex, time.Now())
+	require.Equal(t, "FALSE", sql)
+	require.Empty(t, args)
+}
+
+func TestTokenSet_RoundTrip(t *testing.T) {
+	v, err := models.TokenSet{"ab12", "cd34"}.Value()
+	require.NoError(t, err)
+	require.Equal(t, "{ab12,cd34}", v)
+
+	var s models.TokenSet
+	require.NoError(t, s.Scan(v))
+	require.Equal(t, models.TokenSet{"ab12", "cd34"}, s)
+	require.NoError(t, s.Scan("{}"))
+	require.Equal(t, models.TokenSet{}, s)
+	require.NoError(t, s.Scan(nil))
+	require.Nil(t, s)
+}
+
+func TestPatientCriteria_Where_EmptyAlternativeMatchesAll(t *testing.T) {
+	criteria := repositories.PatientSearchCriteria{
+		AnyOf: []repositories.PatientSearchCriteria{{Email: "a@example.com"}, {}},
+	}
+
+	sql, _ := criteria.Where(plainIndex, time.Now())
+	require.Empty(t, sql)
+	require.True(t, criteria.IsEmpty())
+}
+
+func TestPatientCriteria_Where_AgeBounds(t *testing.T) {
+	min, max := 30, 39
+	criteria := repositories.PatientSearchCriteria{AgeMin: &min, AgeMax: &max}
+
+	sql, args := criteria.Where(plainIndex, time.Date(2026, 3, 15, 10, 0, 0, 0, time.UTC))
+	require.Equal(t, "(date_of_birth <= ? AND date_of_birth > ?)", sql)
+	require.Equal(t, []interface{}{
+		time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(1986, 3, 15, 0, 0, 0, 0, time.UTC),
+	}, args)
+}
+
+func TestPatientCriteria_Simple(t *testing.T) {
+	require.True(t, (&repositories.PatientSearchCriteria{NationalID: "1"}).Simple())
+	require.False(t, (&repositories.PatientSearchCriteria{AllOf: []repositories.PatientSearchCriteria{{Email: "a"}}}).Simple())
+	min := 1
+	require.False(t, (&repositories.PatientSearchCriteria{AgeMin: &min}).Simple())
+}
@@ -1,39 +1,65 @@
 package tests
 
 import (
+	"bytes"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"agnos_candidate_assignment/handlers"
 	"agnos_candidate_assignment/middleware"
 	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+	"agnos_candidate_assignment/throttle"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
 
 type mockPatientService struct {
-	SearchFn func(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error)
+	SearchFn func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error)
 	GetByFn  func(hospitalID uint, id string) (*models.Patient, error)
+	GetFn    func(hospitalID, id uint) (*models.Patient, error)
+	CreateFn func(hospitalID uint, p *models.Patient) error
+	UpdateFn func(hospitalID, id uint, upd services.PatientUpdate) (*models.Patient, error)
+	DeleteFn func(hospitalID, id uint) error
+	VerifyFn func(hospitalID uint, id string) (*services.PatientVerification, error)
 }
 
-func (m *mockPatientService) Search(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error) {
-	return m.SearchFn(hospitalID, filters)
+func (m *mockPatientService) Search(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+	return m.SearchFn(hospitalID, criteria, page)
 }
 func (m *mockPatientService) GetByNationalOrPassport(hospitalID uint, id string) (*models.Patient, error) {
 	return m.GetByFn(hospitalID, id)
 }
+func (m *mockPatientService) Verify(hospitalID uint, id string) (*services.PatientVerification, error) {
+	return m.VerifyFn(hospitalID, id)
+}
+func (m *mockPatientService) Get(hospitalID, id uint) (*models.Patient, error) {
+	return m.GetFn(hospitalID, id)
+}
+func (m *mockPatientService) Create(hospitalID uint, p *models.Patient) error {
+	return m.CreateFn(hospitalID, p)
+}
+func (m *mockPatientService) Update(hospitalID, id uint, upd services.PatientUpdate) (*models.Patient, error) {
+	return m.UpdateFn(hospitalID, id, upd)
+}
+func (m *mockPatientService) Delete(hospitalID, id uint) error {
+	return m.DeleteFn(hospitalID, id)
+}
 
 func TestPatientSearch_Authorized_Positive(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mock := &mockPatientService{SearchFn: func(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error) {
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
 		a := "A"
-		return []models.Patient{{ID: 1, FirstNameTH: &a}}, nil
+		return &repositories.PatientPage{Patients: []models.Patient{{ID: 1, FirstNameTH: &a}}}, nil
 	}}
 
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/search", func(c *gin.Context) {
 		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
@@ -49,7 +75,7 @@ func TestPatientSearch_Authorized_Positive(t *testing.T) {
 func TestPatientSearch_Unauthorized_NoClaims(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mock := &mockPatientService{}
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/search", func(c *gin.Context) { ph.Search(c) })
 
@@ -66,10 +92,11 @@ func TestPatientGetByID_Success(t *testing.T) {
 		return &models.Patient{ID: 42, FirstNameTH: &a}, nil
 	}}
 
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/:id", func(c *gin.Context) {
 		c.Set("hospital_id", uint(2))
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
 		ph.GetByID(c)
 	})
 
@@ -81,11 +108,12 @@ func TestPatientGetByID_Success(t *testing.T) {
 
 func TestPatientGetByID_NotFound(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	err := services.ErrPatientNotFound
 	mock := &mockPatientService{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
-		return nil, errors.New("not found")
+		return nil, err
 	}}
 
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/:id", func(c *gin.Context) {
 		c.Set("hospital_id", uint(2))
@@ -96,12 +124,18 @@ func TestPatientGetByID_NotFound(t *testing.T) {
 	rr := httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
 	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// A failed lookup is not reported as a missing patient.
+	err = errors.New("HIS lookup failed: connection refused")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/X", nil))
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
 }
 
 func TestPatientGetByID_NoHospitalContext(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mock := &mockPatientService{}
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/:id", func(c *gin.Context) { ph.GetByID(c) })
 
@@ -113,11 +147,11 @@ func TestPatientGetByID_NoHospitalContext(t *testing.T) {
 
 func TestPatientSearch_ServiceError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mock := &mockPatientService{SearchFn: func(hospitalID uint, filters map[string]interface{}) ([]models.Patient, error) {
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
 		return nil, errors.New("boom")
 	}}
 
-	ph := handlers.NewPatientHandler(mock)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
 	r := gin.New()
 	r.GET("/api/patient/search", func(c *gin.Context) {
 		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
@@ -129,3 +163,309 @@ func TestPatientSearch_ServiceError(t *testing.T) {
 	r.ServeHTTP(rr, req)
 	require.Equal(t, http.StatusInternalServerError, rr.Code)
 }
+
+func newPatientCRUDRouter(mock *mockPatientService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	withClaims := func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+	}
+	r.POST("/api/patient", withClaims, ph.Create)
+	r.PATCH("/api/patient/:patient_id", withClaims, ph.Update)
+	r.DELETE("/api/patient/:patient_id", withClaims, ph.Delete)
+	return r
+}
+
+func TestPatientCreate_Positive(t *testing.T) {
+	var gotHospital uint
+	r := newPatientCRUDRouter(&mockPatientService{CreateFn: func(hospitalID uint, p *models.Patient) error {
+		gotHospital = hospitalID
+		p.ID = 10
+		return nil
+	}})
+
+	body := `{"patient_hn":"HN1","date_of_birth":"1990-01-02","gender":"F"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/patient", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Equal(t, uint(2), gotHospital)
+}
+
+func TestPatientCreate_BadDate(t *testing.T) {
+	r := newPatientCRUDRouter(&mockPatientService{})
+
+	body := `{"patient_hn":"HN1","date_of_birth":"02/01/1990","gender":"F"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/patient", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatientCreate_Conflict(t *testing.T) {
+	r := newPatientCRUDRouter(&mockPatientService{CreateFn: func(hospitalID uint, p *models.Patient) error {
+		return &services.ConflictError{Field: "patient_hn"}
+	}})
+
+	body := `{"patient_hn":"HN1","date_of_birth":"1990-01-02","gender":"F"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/patient", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestPatientUpdate_PassesOnlyProvidedFields(t *testing.T) {
+	var got services.PatientUpdate
+	r := newPatientCRUDRouter(&mockPatientService{UpdateFn: func(hospitalID, id uint, upd services.PatientUpdate) (*models.Patient, error) {
+		got = upd
+		return &models.Patient{ID: id}, nil
+	}})
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/patient/5", bytes.NewReader([]byte(`{"email":"a@b.c"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotNil(t, got.Email)
+	require.Nil(t, got.PhoneNumber)
+	require.Nil(t, got.DateOfBirth)
+}
+
+func TestPatientDelete_NotFound(t *testing.T) {
+	r := newPatientCRUDRouter(&mockPatientService{DeleteFn: func(hospitalID, id uint) error {
+		return services.ErrPatientNotFound
+	}})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/patient/5", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPatientDelete_InvalidID(t *testing.T) {
+	r := newPatientCRUDRouter(&mockPatientService{})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/patient/abc", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatientGetByID_MalformedID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockPatientService{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		return nil, &services.ValidationError{Message: "invalid national_id: checksum mismatch"}
+	}}
+
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/:id", func(c *gin.Context) {
+		c.Set("hospital_id", uint(2))
+		ph.GetByID(c)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/1100700000002", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "checksum")
+}
+
+func TestPatientSearch_PassesPageRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got repositories.PageRequest
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		got = page
+		total := int64(42)
+		return &repositories.PatientPage{Patients: []models.Patient{}, NextCursor: "next", Total: &total}, nil
+	}}
+
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.Search(c)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?limit=5&cursor=abc&sort=-updated_at&total=true", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, repositories.PageRequest{Limit: 5, Cursor: "abc", Sort: "-updated_at", IncludeTotal: true}, got)
+	require.JSONEq(t, `{"patients":[],"next_cursor":"next","total":42}`, rr.Body.String())
+}
+
+func TestPatientSearch_InvalidLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ph := handlers.NewPatientHandler(&mockPatientService{}, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.Search(c)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?limit=abc", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatientSearch_MatchMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got repositories.MatchMode
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		got = criteria.Match
+		score := 0.8
+		return &repositories.PatientPage{Patients: []models.Patient{{ID: 1, MatchScore: &score}}}, nil
+	}}
+
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.Search(c)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?first_name=Somch&match=fuzzy", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, repositories.MatchFuzzy, got)
+	require.Contains(t, rr.Body.String(), `"match_score":0.8`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?first_name=Somch&match=soundex", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?gender=X", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatientSearchAdvanced_BindsGroups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got repositories.PatientSearchCriteria
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		got = criteria
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	r.POST("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.SearchAdvanced(c)
+	})
+
+	body := `{"gender":"F","dob_from":"1980-01-01","any_of":[{"last_name":"Jaidee"},{"phone_number":"0812345678"}],"match":"prefix"}`
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/patient/search?limit=10", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, models.Female, got.Gender)
+	require.Equal(t, repositories.MatchPrefix, got.Match)
+	require.Equal(t, time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), *got.DOBFrom)
+	require.Len(t, got.AnyOf, 2)
+	require.Equal(t, "Jaidee", got.AnyOf[0].LastName)
+}
+
+func TestPatientSearchAdvanced_RejectsBadInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ph := handlers.NewPatientHandler(&mockPatientService{}, newFieldPolicies())
+	r := gin.New()
+	r.POST("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.SearchAdvanced(c)
+	})
+
+	group := func(n int, inner string) string {
+		return `{"any_of":[` + strings.TrimSuffix(strings.Repeat(inner+",", n), ",") + `]}`
+	}
+	for _, body := range []string{
+		`{"dob_to":"31/02/1989"}`,
+		`{"any_of":[{"any_of":[{"any_of":[{"any_of":[{"email":"a"}]}]}]}]}`,
+		`not json`,
+		`{"first_name":"Somchai","match":"soundex"}`,
+		`{"all_of":[{"gender":"X"}]}`,
+		group(11, `{"email":"a"}`),
+		group(6, group(9, `{"email":"a"}`)),
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/patient/search", strings.NewReader(body)))
+		require.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestPatientSearch_DateAndAgeFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got repositories.PatientSearchCriteria
+	mock := &mockPatientService{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		got = criteria
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.Search(c)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?date_of_birth=12/04/2530&age_min=30&age_max=45", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, time.Date(1987, time.April, 12, 0, 0, 0, 0, time.UTC), *got.DateOfBirth)
+	require.Equal(t, 30, *got.AgeMin)
+	require.Equal(t, 45, *got.AgeMax)
+
+	// A bare BE year searches the whole year, narrowed by an explicit bound.
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?date_of_birth=2530&dob_to=1987-06-30", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Nil(t, got.DateOfBirth)
+	require.Equal(t, time.Date(1987, time.January, 1, 0, 0, 0, 0, time.UTC), *got.DOBFrom)
+	require.Equal(t, time.Date(1987, time.June, 30, 0, 0, 0, 0, time.UTC), *got.DOBTo)
+}
+
+func TestPatientSearch_BadDateOrAgeIs400(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ph := handlers.NewPatientHandler(&mockPatientService{}, newFieldPolicies())
+	r := gin.New()
+	r.GET("/api/patient/search", func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{HospitalID: 2})
+		ph.Search(c)
+	})
+
+	for _, q := range []string{"date_of_birth=30/02/2530", "dob_from=soon", "dob_to=87", "age_min=-1", "age_max=old"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/patient/search?"+q, nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code, q)
+		require.Contains(t, rr.Body.String(), strings.SplitN(q, "=", 2)[0], q)
+	}
+}
+
+func TestPatientVerify_RateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockPatientService{VerifyFn: func(hospitalID uint, id string) (*services.PatientVerification, error) {
+		if id != "1100700000001" {
+			return nil, services.ErrPatientNotFound
+		}
+		return &services.PatientVerification{PatientID: 9, FirstNameEN: "S******", BirthYear: 1985}, nil
+	}}
+	ph := handlers.NewPatientHandler(mock, newFieldPolicies())
+	r := gin.New()
+	withHospital := func(c *gin.Context) { c.Set("hospital_id", uint(2)) }
+	r.GET("/api/h/patient/verify/:id", middleware.RateLimit(throttle.NewLimiter(2, time.Minute)), withHospital, ph.Verify)
+
+	do := func(id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/h/patient/verify/"+id, nil))
+		return rr
+	}
+
+	rr := do("1100700000001")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"first_name_en":"S******","birth_year":1985}`, rr.Body.String())
+	require.Equal(t, http.StatusNotFound, do("1100700000002").Code)
+
+	rr = do("1100700000001")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
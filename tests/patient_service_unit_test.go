@@ -0,0 +1,380 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/his"
+	"agnos_candidate_assignment/his/histest"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockPatientRepo struct {
+	SearchFn       func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error)
+	GetByFn        func(hospitalID uint, id string) (*models.Patient, error)
+	CreateFn       func(p *models.Patient) error
+	GetByIDFn      func(hospitalID, id uint) (*models.Patient, error)
+	UpdateFieldsFn func(hospitalID, id uint, fields map[string]interface{}) error
+	upserted       []models.Patient
+}
+
+func (m *mockPatientRepo) Create(p *models.Patient) error {
+	return m.CreateFn(p)
+}
+
+func (m *mockPatientRepo) GetByID(hospitalID, id uint) (*models.Patient, error) {
+	return m.GetByIDFn(hospitalID, id)
+}
+
+func (m *mockPatientRepo) UpdateFields(hospitalID, id uint, fields map[string]interface{}) error {
+	return m.UpdateFieldsFn(hospitalID, id, fields)
+}
+
+func (m *mockPatientRepo) Delete(hospitalID, id uint) error {
+	return gorm.ErrRecordNotFound
+}
+
+func (m *mockPatientRepo) Upsert(p *models.Patient) error {
+	p.ID = uint(len(m.upserted) + 1)
+	m.upserted = append(m.upserted, *p)
+	return nil
+}
+
+func (m *mockPatientRepo) Search(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+	return m.SearchFn(hospitalID, criteria, page)
+}
+
+func (m *mockPatientRepo) GetByNationalID(hospitalID uint, id string) (*models.Patient, error) {
+	return m.GetByFn(hospitalID, id)
+}
+
+func (m *mockPatientRepo) GetByPassportID(hospitalID uint, id string) (*models.Patient, error) {
+	return m.GetByFn(hospitalID, id)
+}
+
+type stubHospitalRepo struct {
+	mockHospitalRepo
+	hospital *models.Hospital
+}
+
+func (m *stubHospitalRepo) FindByID(id uint) (*models.Hospital, error) {
+	return m.hospital, nil
+}
+
+func TestPatientService_GetBy_FallsThroughToHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN7", "1100700000001"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		return nil, gorm.ErrRecordNotFound
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	p, err := svc.GetByNationalOrPassport(3, "1100700000001")
+	require.NoError(t, err)
+	require.Equal(t, uint(3), p.HospitalID)
+	require.Len(t, repo.upserted, 1)
+	require.Equal(t, "HN7", repo.upserted[0].PatientHN)
+}
+
+func TestPatientService_GetBy_LocalHitSkipsHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN7", "1100700000001"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		return &models.Patient{ID: 1, HospitalID: hospitalID}, nil
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	_, err := svc.GetByNationalOrPassport(3, "1100700000001")
+	require.NoError(t, err)
+	require.Equal(t, 0, srv.Requests())
+}
+
+func TestPatientService_Search_FallsThroughToHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN7", "1100700000001"), hisPatient("HN8", "1100700000019"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{}
+	repo.SearchFn = func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return &repositories.PatientPage{Patients: repo.upserted}, nil
+	}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	results, err := svc.Search(3, repositories.PatientSearchCriteria{NationalID: "1100700000019"}, repositories.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, results.Patients, 1)
+	require.Equal(t, "HN8", results.Patients[0].PatientHN)
+}
+
+func TestPatientService_Search_LaterPagesSkipHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN8", "1100700000019"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	_, err := svc.Search(3, repositories.PatientSearchCriteria{NationalID: "1100700000019"}, repositories.PageRequest{Cursor: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, 0, srv.Requests())
+}
+
+func TestPatientService_Search_InvalidCursorIsValidationError(t *testing.T) {
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return nil, repositories.ErrInvalidCursor
+	}}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	_, err := svc.Search(3, repositories.PatientSearchCriteria{}, repositories.PageRequest{Cursor: "garbage"})
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestPatientService_GetBy_NoHISReturnsLocalError(t *testing.T) {
+	repo := &mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		return nil, errors.New("record not found")
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	_, err := svc.GetByNationalOrPassport(3, "AA1234567")
+	require.Error(t, err)
+}
+
+func TestPatientService_GetBy_FailuresAreNotNotFound(t *testing.T) {
+	srv := histest.NewServer()
+	url := srv.URL
+	srv.Close()
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &url}}
+
+	// The HIS is down: the lookup failed rather than found nothing.
+	repo := &mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		return nil, gorm.ErrRecordNotFound
+	}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+	_, err := svc.GetByNationalOrPassport(3, "1100700000001")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, services.ErrPatientNotFound)
+
+	// A database error is returned without asking the HIS.
+	dbErr := errors.New("connection refused")
+	repo.GetByFn = func(hospitalID uint, id string) (*models.Patient, error) {
+		return nil, dbErr
+	}
+	_, err = svc.GetByNationalOrPassport(3, "1100700000001")
+	require.ErrorIs(t, err, dbErr)
+}
+
+func TestPatientService_Create_RequiresDateOfBirthAndGender(t *testing.T) {
+	svc := services.NewPatientService(&mockPatientRepo{}, nil, nil)
+
+	err := svc.Create(1, &models.Patient{PatientHN: "HN1", Gender: models.Male})
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	err = svc.Create(1, &models.Patient{PatientHN: "HN1", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: "X"})
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestPatientService_Create_DuplicateMapsToConflict(t *testing.T) {
+	repo := &mockPatientRepo{CreateFn: func(p *models.Patient) error {
+		return &repositories.DuplicateKeyError{Field: "national_id"}
+	}}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	err := svc.Create(1, &models.Patient{PatientHN: "HN1", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: models.Female})
+	var conflictErr *services.ConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	require.Equal(t, "national_id", conflictErr.Field)
+}
+
+func TestPatientService_Update_EmptyStringClearsField(t *testing.T) {
+	var got map[string]interface{}
+	repo := &mockPatientRepo{
+		UpdateFieldsFn: func(hospitalID, id uint, fields map[string]interface{}) error {
+			got = fields
+			return nil
+		},
+		GetByIDFn: func(hospitalID, id uint) (*models.Patient, error) {
+			return &models.Patient{ID: id, HospitalID: hospitalID}, nil
+		},
+	}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	empty, email := "", "new@example.com"
+	_, err := svc.Update(1, 5, services.PatientUpdate{PassportID: &empty, Email: &email})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"passport_id": nil, "email": "new@example.com"}, got)
+}
+
+func TestPatientService_Delete_NotFound(t *testing.T) {
+	svc := services.NewPatientService(&mockPatientRepo{}, nil, nil)
+	require.ErrorIs(t, svc.Delete(1, 99), services.ErrPatientNotFound)
+}
+
+func TestPatientService_GetBy_RoutesByIDKind(t *testing.T) {
+	var nationalCalls, passportCalls []string
+	repo := &passportRoutingRepo{
+		mockPatientRepo: mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+			nationalCalls = append(nationalCalls, id)
+			return &models.Patient{ID: 1}, nil
+		}},
+		GetByPassportFn: func(hospitalID uint, id string) (*models.Patient, error) {
+			passportCalls = append(passportCalls, id)
+			return &models.Patient{ID: 2}, nil
+		},
+	}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	_, err := svc.GetByNationalOrPassport(1, "1-1007-00000-00-1")
+	require.NoError(t, err)
+	_, err = svc.GetByNationalOrPassport(1, "aa1234567")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"1100700000001"}, nationalCalls)
+	require.Equal(t, []string{"AA1234567"}, passportCalls)
+}
+
+func TestPatientService_GetBy_MalformedIDIsValidationError(t *testing.T) {
+	svc := services.NewPatientService(&mockPatientRepo{}, nil, nil)
+
+	_, err := svc.GetByNationalOrPassport(1, "1100700000002")
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Contains(t, validationErr.Error(), "checksum")
+}
+
+func TestPatientService_Create_NormalizesIDs(t *testing.T) {
+	var created *models.Patient
+	repo := &mockPatientRepo{CreateFn: func(p *models.Patient) error {
+		created = p
+		return nil
+	}}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	nat, pp := "1 1007 00000 00 1", "aa123456<<<"
+	err := svc.Create(1, &models.Patient{PatientHN: "HN1", NationalID: &nat, PassportID: &pp, DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: models.Male})
+	require.NoError(t, err)
+	require.Equal(t, "1100700000001", *created.NationalID)
+	require.Equal(t, "AA123456", *created.PassportID)
+}
+
+type passportRoutingRepo struct {
+	mockPatientRepo
+	GetByPassportFn func(hospitalID uint, id string) (*models.Patient, error)
+}
+
+func (m *passportRoutingRepo) GetByPassportID(hospitalID uint, id string) (*models.Patient, error) {
+	return m.GetByPassportFn(hospitalID, id)
+}
+
+func TestPatientService_Search_FuzzySkipsHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN8", "1100700000019"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	_, err := svc.Search(3, repositories.PatientSearchCriteria{FirstName: "Somch", Match: repositories.MatchFuzzy}, repositories.PageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, srv.Requests())
+}
+
+func TestPatientService_Search_NormalizesNestedIDs(t *testing.T) {
+	var got repositories.PatientSearchCriteria
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		got = criteria
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+	svc := services.NewPatientService(repo, nil, nil)
+
+	_, err := svc.Search(3, repositories.PatientSearchCriteria{
+		AnyOf: []repositories.PatientSearchCriteria{{NationalID: "1-1007-00000-01-9"}, {PassportID: "aa1234567"}},
+	}, repositories.PageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, repositories.MatchExact, got.Match)
+	require.Equal(t, "1100700000019", got.AnyOf[0].NationalID)
+	require.Equal(t, "AA1234567", got.AnyOf[1].PassportID)
+}
+
+func TestPatientService_Search_RejectsInvalidCriteria(t *testing.T) {
+	svc := services.NewPatientService(&mockPatientRepo{}, nil, nil)
+	min, max := 40, 30
+
+	for _, criteria := range []repositories.PatientSearchCriteria{
+		{Gender: "X"},
+		{AgeMin: &min, AgeMax: &max},
+		{AllOf: []repositories.PatientSearchCriteria{{NationalID: "1100700000012"}}},
+		{Match: "soundex"},
+	} {
+		_, err := svc.Search(3, criteria, repositories.PageRequest{})
+		var validationErr *services.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+	}
+}
+
+func TestPatientService_Search_GroupsSkipHIS(t *testing.T) {
+	srv := histest.NewServer(hisPatient("HN8", "1100700000019"))
+	defer srv.Close()
+
+	repo := &mockPatientRepo{SearchFn: func(hospitalID uint, criteria repositories.PatientSearchCriteria, page repositories.PageRequest) (*repositories.PatientPage, error) {
+		return &repositories.PatientPage{Patients: []models.Patient{}}, nil
+	}}
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &srv.URL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	_, err := svc.Search(3, repositories.PatientSearchCriteria{
+		AnyOf: []repositories.PatientSearchCriteria{{NationalID: "1100700000019"}, {Email: "a@example.com"}},
+	}, repositories.PageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, srv.Requests())
+}
+
+func TestPatientService_Verify_MasksAndSkipsHIS(t *testing.T) {
+	first, last, phone, email := "Somchai", "Jaidee", "081-234-5678", "somchai@example.com"
+	repo := &mockPatientRepo{GetByFn: func(hospitalID uint, id string) (*models.Patient, error) {
+		if id != "1100700000001" {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &models.Patient{
+			ID:          9,
+			FirstNameEN: &first,
+			LastNameEN:  &last,
+			PhoneNumber: &phone,
+			Email:       &email,
+			DateOfBirth: time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC),
+		}, nil
+	}}
+	hisURL := "http://his.invalid"
+	hosp := &stubHospitalRepo{hospital: &models.Hospital{ID: 3, APIURL: &hisURL}}
+	svc := services.NewPatientService(repo, hosp, his.NewRegistry(time.Second))
+
+	v, err := svc.Verify(3, "1-1007-00000-00-1")
+	require.NoError(t, err)
+	require.Equal(t, &services.PatientVerification{
+		PatientID:   9,
+		FirstNameEN: "S******",
+		LastNameEN:  "J*****",
+		BirthYear:   1985,
+		PhoneNumber: "******5678",
+		Email:       "s******@example.com",
+	}, v)
+
+	_, err = svc.Verify(3, "AA1234567")
+	require.ErrorIs(t, err, services.ErrPatientNotFound)
+}
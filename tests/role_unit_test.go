@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type mockStaffRepo struct {
+	staff   map[uint]*models.Staff
+	admins  int64
+	updated map[uint]uint
+
+	profiles map[uint]repositories.StaffProfile
+}
+
+func (m *mockStaffRepo) GetByID(hospitalID, id uint) (*models.Staff, error) {
+	if s, ok := m.staff[id]; ok && s.HospitalID == hospitalID {
+		return s, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockStaffRepo) CountByRole(hospitalID, roleID uint) (int64, error) {
+	return m.admins, nil
+}
+
+func (m *mockStaffRepo) UpdateRole(hospitalID, staffID, roleID uint) error {
+	if m.updated == nil {
+		m.updated = map[uint]uint{}
+	}
+	m.updated[staffID] = roleID
+	return nil
+}
+
+func (m *mockStaffRepo) UpdateStatus(hospitalID, staffID uint, status string) error {
+	m.staff[staffID].Status = status
+	return nil
+}
+
+func (m *mockStaffRepo) ClearLoginFailures(hospitalID, staffID uint) error {
+	m.staff[staffID].FailedLogins = 0
+	m.staff[staffID].LockedUntil = nil
+	return nil
+}
+
+func (m *mockStaffRepo) List(hospitalID uint, filter repositories.StaffFilter, page repositories.PageRequest) (*repositories.StaffPage, error) {
+	result := &repositories.StaffPage{Staff: []models.Staff{}}
+	for id := uint(1); id <= 100; id++ {
+		s, ok := m.staff[id]
+		if !ok || s.HospitalID != hospitalID || (filter.Status != "" && s.Status != filter.Status) {
+			continue
+		}
+		result.Staff = append(result.Staff, *s)
+	}
+	return result, nil
+}
+
+func (m *mockStaffRepo) UpdateProfile(hospitalID, staffID uint, profile repositories.StaffProfile) error {
+	if m.profiles == nil {
+		m.profiles = map[uint]repositories.StaffProfile{}
+	}
+	m.profiles[staffID] = profile
+	return nil
+}
+
+func (m *mockStaffRepo) Disable(hospitalID, staffID uint, now time.Time) error {
+	m.staff[staffID].Status = models.StaffDisabled
+	return nil
+}
+
+func (m *mockStaffRepo) SoftDelete(hospitalID, staffID uint, now time.Time) error {
+	delete(m.staff, staffID)
+	return nil
+}
+
+type mockRoleRepo struct{}
+
+var testRoles = map[string]*models.Role{
+	models.RoleAdmin: {ID: 1, Name: models.RoleAdmin},
+	models.RoleNurse: {ID: 3, Name: models.RoleNurse},
+}
+
+func (mockRoleRepo) FindByName(name string) (*models.Role, error) {
+	if r, ok := testRoles[name]; ok {
+		return r, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (mockRoleRepo) List() ([]models.Role, error) {
+	return []models.Role{*testRoles[models.RoleAdmin], *testRoles[models.RoleNurse]}, nil
+}
+
+func newAdminStaffRepo(admins int64) *mockStaffRepo {
+	adminID := testRoles[models.RoleAdmin].ID
+	return &mockStaffRepo{
+		admins: admins,
+		staff: map[uint]*models.Staff{
+			7: {ID: 7, HospitalID: 2, RoleID: &adminID, Role: testRoles[models.RoleAdmin], Status: models.StaffActive},
+		},
+	}
+}
+
+func TestAssignRole_Succeeds(t *testing.T) {
+	repo := newAdminStaffRepo(2)
+	svc := services.NewRoleService(repo, mockRoleRepo{})
+
+	staff, err := svc.AssignRole(2, 7, models.RoleNurse)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleNurse, staff.Role.Name)
+	require.Equal(t, uint(3), repo.updated[7])
+}
+
+func TestAssignRole_KeepsLastAdmin(t *testing.T) {
+	repo := newAdminStaffRepo(1)
+	svc := services.NewRoleService(repo, mockRoleRepo{})
+
+	_, err := svc.AssignRole(2, 7, models.RoleNurse)
+	require.ErrorIs(t, err, services.ErrLastAdmin)
+	require.Empty(t, repo.updated)
+}
+
+func TestAssignRole_OtherHospitalOrUnknownRole(t *testing.T) {
+	svc := services.NewRoleService(newAdminStaffRepo(2), mockRoleRepo{})
+
+	_, err := svc.AssignRole(5, 7, models.RoleNurse)
+	require.ErrorIs(t, err, services.ErrStaffNotFound)
+
+	_, err = svc.AssignRole(2, 7, "janitor")
+	require.ErrorIs(t, err, services.ErrRoleNotFound)
+}
+
+func TestAssignRoleHandler_MapsErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := handlers.NewRoleHandler(services.NewRoleService(newAdminStaffRepo(1), mockRoleRepo{}))
+	r := gin.New()
+	r.PUT("/api/h/staff/:staff_id/role", func(c *gin.Context) { c.Set("hospital_id", uint(2)) }, h.Assign)
+
+	cases := []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/h/staff/7/role", `{"role":"nurse"}`, http.StatusConflict},
+		{"/api/h/staff/7/role", `{"role":"janitor"}`, http.StatusBadRequest},
+		{"/api/h/staff/8/role", `{"role":"nurse"}`, http.StatusNotFound},
+		{"/api/h/staff/x/role", `{"role":"nurse"}`, http.StatusBadRequest},
+		{"/api/h/staff/7/role", `{}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(rr, req)
+		require.Equal(t, tc.want, rr.Code, "%s %s", tc.path, tc.body)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(claims *middleware.StaffClaims) *gin.Engine {
+		r := gin.New()
+		r.GET("/x", func(c *gin.Context) {
+			if claims != nil {
+				c.Set(string(middleware.StaffContextKey), claims)
+			}
+		}, middleware.RequirePermission(models.PermPatientDelete), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
+
+	cases := []struct {
+		claims *middleware.StaffClaims
+		want   int
+	}{
+		{&middleware.StaffClaims{Role: models.RoleAdmin, Permissions: models.AllPermissions}, http.StatusOK},
+		{&middleware.StaffClaims{Role: models.RoleNurse, Permissions: models.DefaultRoles[models.RoleNurse]}, http.StatusForbidden},
+		{&middleware.StaffClaims{}, http.StatusForbidden},
+		{nil, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		newRouter(tc.claims).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/x", nil))
+		require.Equal(t, tc.want, rr.Code)
+	}
+}
@@ -7,34 +7,79 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/middleware"
 	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
 
 type mockAuthService struct {
-	RegisterFn func(hospital, username, password string) (*models.Staff, error)
-	LoginFn    func(hospital, username, password string) (string, *models.Staff, error)
+	RegisterFn func(hospital, username, password, invitationToken string) (*models.Staff, error)
+	LoginFn    func(hospital, username, password, clientIP string) (*services.LoginResult, error)
+	RefreshFn  func(hospital, refreshToken string) (*services.TokenPair, *models.Staff, error)
+	LogoutFn   func(staffID uint, jti string, accessExpiresAt time.Time, refreshToken string) error
+
+	CompleteMFALoginFn func(hospital, mfaToken, code string) (*services.LoginResult, error)
+	ChallengeEnrollFn  func(hospital, mfaToken string) (*services.MFAEnrollment, error)
+	StartEnrollmentFn  func(staffID uint) (*services.MFAEnrollment, error)
+	ConfirmFn          func(staffID uint, code string) ([]string, error)
+	DisableMFAFn       func(staffID uint, code string) error
+
+	ChangePasswordFn func(staffID uint, oldPassword, newPassword string) (*services.TokenPair, *models.Staff, error)
+	ResetPasswordFn  func(hospital, resetToken, newPassword string) error
 }
 
-func (m *mockAuthService) Register(hospital, username, password string) (*models.Staff, error) {
-	return m.RegisterFn(hospital, username, password)
+func (m *mockAuthService) Register(hospital, username, password, invitationToken string) (*models.Staff, error) {
+	return m.RegisterFn(hospital, username, password, invitationToken)
+}
+func (m *mockAuthService) Login(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+	return m.LoginFn(hospital, username, password, clientIP)
+}
+func (m *mockAuthService) Refresh(hospital, refreshToken string) (*services.TokenPair, *models.Staff, error) {
+	return m.RefreshFn(hospital, refreshToken)
+}
+func (m *mockAuthService) Logout(staffID uint, jti string, accessExpiresAt time.Time, refreshToken string) error {
+	return m.LogoutFn(staffID, jti, accessExpiresAt, refreshToken)
+}
+func (m *mockAuthService) CompleteMFALogin(hospital, mfaToken, code string) (*services.LoginResult, error) {
+	return m.CompleteMFALoginFn(hospital, mfaToken, code)
+}
+func (m *mockAuthService) StartMFAEnrollmentWithChallenge(hospital, mfaToken string) (*services.MFAEnrollment, error) {
+	return m.ChallengeEnrollFn(hospital, mfaToken)
+}
+func (m *mockAuthService) StartMFAEnrollment(hospitalID, staffID uint) (*services.MFAEnrollment, error) {
+	return m.StartEnrollmentFn(staffID)
 }
-func (m *mockAuthService) Login(hospital, username, password string) (string, *models.Staff, error) {
-	return m.LoginFn(hospital, username, password)
+func (m *mockAuthService) ConfirmMFAEnrollment(hospitalID, staffID uint, code string) ([]string, error) {
+	return m.ConfirmFn(staffID, code)
+}
+func (m *mockAuthService) DisableMFA(hospitalID, staffID uint, code string) error {
+	return m.DisableMFAFn(staffID, code)
+}
+func (m *mockAuthService) ChangePassword(hospitalID, staffID uint, oldPassword, newPassword string) (*services.TokenPair, *models.Staff, error) {
+	return m.ChangePasswordFn(staffID, oldPassword, newPassword)
+}
+func (m *mockAuthService) ResetPassword(hospital, resetToken, newPassword string) error {
+	return m.ResetPasswordFn(hospital, resetToken, newPassword)
 }
 
 func TestStaffRegister_PositiveAndLogin_Positive(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mock := &mockAuthService{
-		RegisterFn: func(hospital, username, password string) (*models.Staff, error) {
+		RegisterFn: func(hospital, username, password, invitationToken string) (*models.Staff, error) {
 			return &models.Staff{ID: 7, UserName: username}, nil
 		},
-		LoginFn: func(hospital, username, password string) (string, *models.Staff, error) {
-			return "tok", &models.Staff{ID: 7, UserName: username, HospitalID: 2}, nil
+		LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+			return &services.LoginResult{
+				Tokens: &services.TokenPair{AccessToken: "tok", RefreshToken: "ref"},
+				Staff:  &models.Staff{ID: 7, UserName: username, HospitalID: 2},
+			}, nil
 		},
 	}
 
@@ -74,8 +119,8 @@ func TestStaffRegister_MissingFields(t *testing.T) {
 
 func TestStaffLogin_WrongCredentials(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mock := &mockAuthService{LoginFn: func(hospital, username, password string) (string, *models.Staff, error) {
-		return "", nil, errors.New("invalid")
+	mock := &mockAuthService{LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+		return nil, errors.New("invalid")
 	}}
 	sh := handlers.NewStaffHandler(mock)
 	router := gin.New()
@@ -92,7 +137,7 @@ func TestStaffLogin_WrongCredentials(t *testing.T) {
 
 func TestStaffRegister_ServiceError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mock := &mockAuthService{RegisterFn: func(hospital, username, password string) (*models.Staff, error) {
+	mock := &mockAuthService{RegisterFn: func(hospital, username, password, invitationToken string) (*models.Staff, error) {
 		return nil, errors.New("service fail")
 	}}
 
@@ -108,3 +153,321 @@ func TestStaffRegister_ServiceError(t *testing.T) {
 	router.ServeHTTP(rrec, rreq)
 	require.Equal(t, http.StatusInternalServerError, rrec.Code)
 }
+
+func TestStaffRegister_InvitationErrorsAre403(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotToken string
+	mock := &mockAuthService{RegisterFn: func(hospital, username, password, invitationToken string) (*models.Staff, error) {
+		gotToken = invitationToken
+		if invitationToken == "" {
+			return nil, services.ErrInvitationRequired
+		}
+		return nil, repositories.ErrInvitationInvalid
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/create", sh.Register)
+
+	for _, body := range []string{`{"username":"u","password":"p"}`, `{"username":"u","password":"p","invitation_token":"used"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/H/staff/create", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusForbidden, rr.Code, body)
+	}
+	require.Equal(t, "used", gotToken)
+}
+
+func TestStaffRegister_TakenUsernameIs409(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{RegisterFn: func(hospital, username, password, invitationToken string) (*models.Staff, error) {
+		return nil, services.ErrUsernameTaken
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/create", sh.Register)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/H/staff/create", bytes.NewReader([]byte(`{"username":"u","password":"p","invitation_token":"t"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestStaffRegisterAndLogin_UnknownHospitalIs404(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{
+		RegisterFn: func(hospital, username, password, invitationToken string) (*models.Staff, error) {
+			return nil, repositories.ErrHospitalNotFound
+		},
+		LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+			return nil, repositories.ErrHospitalNotFound
+		},
+	}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/create", sh.Register)
+	router.POST("/api/:hospital/staff/login", sh.Login)
+
+	for _, path := range []string{"/api/Nowhere/staff/create", "/api/Nowhere/staff/login"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(`{"username":"u","password":"p","invitation_token":"t"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNotFound, rr.Code, path)
+	}
+}
+
+func TestStaffLogin_PendingIs403(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+		return nil, services.ErrStaffPending
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/login", sh.Login)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/H/staff/login", bytes.NewReader([]byte(`{"username":"u","password":"p"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestStaffRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{RefreshFn: func(hospital, refreshToken string) (*services.TokenPair, *models.Staff, error) {
+		switch refreshToken {
+		case "good":
+			return &services.TokenPair{AccessToken: "a2", RefreshToken: "r2"}, &models.Staff{ID: 7}, nil
+		case "used":
+			return nil, nil, services.ErrRefreshTokenReused
+		}
+		return nil, nil, services.ErrInvalidRefreshToken
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/refresh", sh.Refresh)
+
+	cases := map[string]int{
+		`{"refresh_token":"good"}`: http.StatusOK,
+		`{"refresh_token":"used"}`: http.StatusUnauthorized,
+		`{"refresh_token":"junk"}`: http.StatusUnauthorized,
+		`{}`:                       http.StatusBadRequest,
+	}
+	for body, want := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/H/staff/refresh", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, want, rr.Code, body)
+		if want == http.StatusOK {
+			var resp map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, "a2", resp["token"])
+			require.Equal(t, "r2", resp["refresh_token"])
+		}
+	}
+}
+
+func TestStaffLogout_RevokesCallingToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotJTI, gotRefresh string
+	mock := &mockAuthService{LogoutFn: func(staffID uint, jti string, accessExpiresAt time.Time, refreshToken string) error {
+		gotJTI, gotRefresh = jti, refreshToken
+		return nil
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/logout", func(c *gin.Context) {
+		claims := &middleware.StaffClaims{StaffID: 7, HospitalID: 2}
+		claims.ID = "jti-1"
+		c.Set(string(middleware.StaffContextKey), claims)
+	}, sh.Logout)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/H/staff/logout", bytes.NewReader([]byte(`{"refresh_token":"r1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "jti-1", gotJTI)
+	require.Equal(t, "r1", gotRefresh)
+}
+
+func TestStaffLogin_MFAChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{
+		LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+			return &services.LoginResult{Staff: &models.Staff{ID: 7}, MFAToken: "challenge", EnrollmentRequired: true}, nil
+		},
+		CompleteMFALoginFn: func(hospital, mfaToken, code string) (*services.LoginResult, error) {
+			if mfaToken != "challenge" {
+				return nil, services.ErrMFAChallengeInvalid
+			}
+			if code != "123456" {
+				return nil, services.ErrMFACodeInvalid
+			}
+			return &services.LoginResult{
+				Tokens:        &services.TokenPair{AccessToken: "tok", RefreshToken: "ref"},
+				Staff:         &models.Staff{ID: 7, HospitalID: 2},
+				RecoveryCodes: []string{"AAAAA-BBBBB"},
+			}, nil
+		},
+	}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/login", sh.Login)
+	router.POST("/api/:hospital/staff/login/mfa", sh.LoginMFA)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/api/H/staff/login", `{"username":"u","password":"p"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var challenge map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &challenge))
+	require.Equal(t, true, challenge["mfa_required"])
+	require.Equal(t, true, challenge["enrollment_required"])
+	require.Equal(t, "challenge", challenge["mfa_token"])
+	require.NotContains(t, challenge, "token")
+
+	require.Equal(t, http.StatusUnauthorized, post("/api/H/staff/login/mfa", `{"mfa_token":"challenge","code":"000000"}`).Code)
+	require.Equal(t, http.StatusUnauthorized, post("/api/H/staff/login/mfa", `{"mfa_token":"other","code":"123456"}`).Code)
+	require.Equal(t, http.StatusBadRequest, post("/api/H/staff/login/mfa", `{"mfa_token":"challenge"}`).Code)
+
+	rr = post("/api/H/staff/login/mfa", `{"mfa_token":"challenge","code":"123456"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "tok", resp["token"])
+	require.Equal(t, []interface{}{"AAAAA-BBBBB"}, resp["recovery_codes"])
+}
+
+func TestStaffMFA_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var disabled bool
+	mock := &mockAuthService{
+		StartEnrollmentFn: func(staffID uint) (*services.MFAEnrollment, error) {
+			return &services.MFAEnrollment{Secret: "S", URI: "otpauth://totp/x"}, nil
+		},
+		ConfirmFn: func(staffID uint, code string) ([]string, error) {
+			if code != "123456" {
+				return nil, services.ErrMFACodeInvalid
+			}
+			return []string{"AAAAA-BBBBB"}, nil
+		},
+		DisableMFAFn: func(staffID uint, code string) error {
+			if staffID == 8 {
+				return services.ErrMFARequired
+			}
+			disabled = true
+			return nil
+		},
+	}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	staffID := uint(7)
+	router.Use(func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{StaffID: staffID, HospitalID: 2})
+	})
+	router.POST("/api/:hospital/staff/mfa/enroll", sh.StartMFAEnrollment)
+	router.POST("/api/:hospital/staff/mfa/verify", sh.ConfirmMFAEnrollment)
+	router.DELETE("/api/:hospital/staff/mfa", sh.DisableMFA)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/H/staff/mfa/enroll", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "otpauth://totp/x")
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/H/staff/mfa/verify", `{"code":"000000"}`).Code)
+	rr = do(http.MethodPost, "/api/H/staff/mfa/verify", `{"code":"123456"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "AAAAA-BBBBB")
+
+	staffID = 8
+	require.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/api/H/staff/mfa", `{"code":"123456"}`).Code)
+	require.False(t, disabled)
+	staffID = 7
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/H/staff/mfa", `{"code":"123456"}`).Code)
+	require.True(t, disabled)
+}
+
+func TestStaffLogin_ThrottledIs429(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotIP string
+	mock := &mockAuthService{LoginFn: func(hospital, username, password, clientIP string) (*services.LoginResult, error) {
+		gotIP = clientIP
+		return nil, &services.LoginThrottledError{RetryAfter: 1500 * time.Millisecond}
+	}}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	router.POST("/api/:hospital/staff/login", sh.Login)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/H/staff/login", bytes.NewReader([]byte(`{"username":"u","password":"p"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.9:4000"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("Retry-After"))
+	require.Equal(t, "203.0.113.9", gotIP)
+}
+
+func TestStaffPassword_ChangeAndReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := &mockAuthService{
+		ChangePasswordFn: func(staffID uint, oldPassword, newPassword string) (*services.TokenPair, *models.Staff, error) {
+			switch {
+			case oldPassword != "old":
+				return nil, nil, services.ErrWrongPassword
+			case newPassword == "weak":
+				return nil, nil, &services.ValidationError{Message: "password must be at least 10 characters"}
+			}
+			return &services.TokenPair{AccessToken: "new"}, &models.Staff{ID: staffID}, nil
+		},
+		ResetPasswordFn: func(hospital, resetToken, newPassword string) error {
+			if resetToken != "reset" {
+				return repositories.ErrResetTokenInvalid
+			}
+			return nil
+		},
+	}
+	sh := handlers.NewStaffHandler(mock)
+	router := gin.New()
+	withStaff := func(c *gin.Context) {
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{StaffID: 7, HospitalID: 2})
+	}
+	router.PUT("/api/:hospital/staff/me/password", withStaff, sh.ChangePassword)
+	router.POST("/api/:hospital/staff/password/reset", sh.ResetPassword)
+
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPut, "/api/H/staff/me/password", `{"old_password":"old","new_password":"Str0ng-enough"}`, http.StatusOK},
+		{http.MethodPut, "/api/H/staff/me/password", `{"old_password":"bad","new_password":"Str0ng-enough"}`, http.StatusForbidden},
+		{http.MethodPut, "/api/H/staff/me/password", `{"old_password":"old","new_password":"weak"}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/H/staff/me/password", `{"new_password":"Str0ng-enough"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/H/staff/password/reset", `{"token":"reset","new_password":"Str0ng-enough"}`, http.StatusNoContent},
+		{http.MethodPost, "/api/H/staff/password/reset", `{"token":"other","new_password":"Str0ng-enough"}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, tc.want, rr.Code, "%s %s %s", tc.method, tc.path, tc.body)
+	}
+}
@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/repositories"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type mockInvitationRepo struct {
+	created []*models.StaffInvitation
+	err     error
+}
+
+func (m *mockInvitationRepo) Create(inv *models.StaffInvitation) error {
+	if m.err != nil {
+		return m.err
+	}
+	inv.ID = uint(len(m.created) + 1)
+	m.created = append(m.created, inv)
+	return nil
+}
+
+type mockPasswordRepo struct {
+	resets []*models.PasswordResetToken
+}
+
+func (m *mockPasswordRepo) CreateReset(token *models.PasswordResetToken) error {
+	token.ID = uint(len(m.resets) + 1)
+	m.resets = append(m.resets, token)
+	return nil
+}
+
+func TestStaffInvite_StoresOnlyTokenHash(t *testing.T) {
+	invites := &mockInvitationRepo{}
+	svc := services.NewStaffService(newAdminStaffRepo(1), mockRoleRepo{}, invites, &mockPasswordRepo{})
+
+	token, inv, err := svc.Invite(2, 7, models.RoleNurse, 0)
+	require.NoError(t, err)
+	require.Len(t, invites.created, 1)
+	require.NotEmpty(t, token)
+	require.NotContains(t, inv.TokenHash, token)
+	require.Equal(t, services.HashToken(token), inv.TokenHash)
+	require.Equal(t, uint(3), *inv.RoleID)
+	require.WithinDuration(t, time.Now().Add(services.DefaultInvitationTTL), inv.ExpiresAt, time.Minute)
+
+	other, _, err := svc.Invite(2, 7, "", time.Hour)
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+}
+
+func TestStaffInvite_RejectsBadInput(t *testing.T) {
+	svc := services.NewStaffService(newAdminStaffRepo(1), mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{})
+
+	_, _, err := svc.Invite(2, 7, "janitor", 0)
+	require.ErrorIs(t, err, services.ErrRoleNotFound)
+
+	_, _, err = svc.Invite(2, 7, "", services.MaxInvitationTTL+time.Hour)
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestStaffApprove(t *testing.T) {
+	repo := newAdminStaffRepo(1)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffPending}
+	svc := services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{})
+
+	_, err := svc.Approve(5, 8)
+	require.ErrorIs(t, err, services.ErrStaffNotFound)
+
+	staff, err := svc.Approve(2, 8)
+	require.NoError(t, err)
+	require.Equal(t, models.StaffActive, staff.Status)
+
+	_, err = svc.Approve(2, 8)
+	require.ErrorIs(t, err, services.ErrStaffNotPending)
+}
+
+func TestStaffAdminHandler_InviteAndApprove(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newAdminStaffRepo(1)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffPending}
+	h := handlers.NewStaffAdminHandler(services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{}))
+	r := gin.New()
+	withAdmin := func(c *gin.Context) {
+		c.Set("hospital_id", uint(2))
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{StaffID: 7, HospitalID: 2})
+	}
+	r.POST("/api/h/staff/invitations", withAdmin, h.Invite)
+	r.POST("/api/h/staff/:staff_id/approve", withAdmin, h.Approve)
+
+	cases := []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/h/staff/invitations", `{"role":"nurse","expires_in_hours":24}`, http.StatusCreated},
+		{"/api/h/staff/invitations", ``, http.StatusCreated},
+		{"/api/h/staff/invitations", `{"role":"janitor"}`, http.StatusBadRequest},
+		{"/api/h/staff/invitations", `{"expires_in_hours":-1}`, http.StatusBadRequest},
+		{"/api/h/staff/8/approve", ``, http.StatusOK},
+		{"/api/h/staff/8/approve", ``, http.StatusConflict},
+		{"/api/h/staff/9/approve", ``, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(rr, req)
+		require.Equal(t, tc.want, rr.Code, "%s %s", tc.path, tc.body)
+	}
+}
+
+func TestStaffAdminHandler_InviteAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	invites := &mockInvitationRepo{}
+	h := handlers.NewStaffAdminHandler(services.NewStaffService(newAdminStaffRepo(1), mockRoleRepo{}, invites, &mockPasswordRepo{}))
+	r := gin.New()
+	r.POST("/api/admin/hospitals/:hospital_id/invitations", func(c *gin.Context) {
+		c.Set(middleware.SystemAdminContextKey, &middleware.SystemAdminClaims{AdminID: 4})
+	}, h.InviteAdmin)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/hospitals/2/invitations", nil))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Len(t, invites.created, 1)
+	inv := invites.created[0]
+	require.Equal(t, uint(2), inv.HospitalID)
+	require.Equal(t, uint(4), *inv.CreatedByAdminID)
+	require.Nil(t, inv.CreatedByID)
+	require.Equal(t, models.RoleAdmin, inv.Role.Name)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/hospitals/abc/invitations", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	invites.err = repositories.ErrHospitalNotFound
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/hospitals/99/invitations", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestStaffAdminHandler_Lockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newAdminStaffRepo(1)
+	until := time.Now().Add(10 * time.Minute)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffActive, FailedLogins: 10, LockedUntil: &until}
+	repo.staff[9] = &models.Staff{ID: 9, HospitalID: 5, Status: models.StaffActive}
+	h := handlers.NewStaffAdminHandler(services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{}))
+	r := gin.New()
+	withHospital := func(c *gin.Context) { c.Set("hospital_id", uint(2)) }
+	r.GET("/api/h/staff/:staff_id/lockout", withHospital, h.Lockout)
+	r.DELETE("/api/h/staff/:staff_id/lockout", withHospital, h.Unlock)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/api/h/staff/8/lockout")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var lockout services.Lockout
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &lockout))
+	require.True(t, lockout.Locked)
+	require.Equal(t, 10, lockout.FailedLogins)
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/h/staff/9/lockout").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/h/staff/9/lockout").Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/api/h/staff/x/lockout").Code)
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/h/staff/8/lockout").Code)
+	require.Nil(t, repo.staff[8].LockedUntil)
+
+	lockout = services.Lockout{}
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/api/h/staff/8/lockout").Body.Bytes(), &lockout))
+	require.False(t, lockout.Locked)
+	require.Zero(t, lockout.FailedLogins)
+}
+
+func TestStaffIssuePasswordReset(t *testing.T) {
+	repo := newAdminStaffRepo(1)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffActive}
+	resets := &mockPasswordRepo{}
+	svc := services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, resets)
+
+	_, _, err := svc.IssuePasswordReset(5, 7, 8)
+	require.ErrorIs(t, err, services.ErrStaffNotFound)
+	require.Empty(t, resets.resets)
+
+	token, reset, err := svc.IssuePasswordReset(2, 7, 8)
+	require.NoError(t, err)
+	require.Len(t, resets.resets, 1)
+	require.Equal(t, services.HashToken(token), reset.TokenHash)
+	require.Equal(t, uint(8), reset.StaffID)
+	require.Equal(t, uint(7), reset.CreatedByID)
+	require.WithinDuration(t, time.Now().Add(services.PasswordResetTTL), reset.ExpiresAt, time.Minute)
+}
+
+func TestStaffDisableAndDelete_Guards(t *testing.T) {
+	repo := newAdminStaffRepo(1)
+	adminID := testRoles[models.RoleAdmin].ID
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffActive}
+	repo.staff[9] = &models.Staff{ID: 9, HospitalID: 2, Status: models.StaffActive, RoleID: &adminID, Role: testRoles[models.RoleAdmin]}
+	svc := services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{})
+
+	_, err := svc.Disable(2, 7, 7)
+	require.ErrorIs(t, err, services.ErrCannotModifySelf)
+	require.ErrorIs(t, svc.Delete(2, 7, 7), services.ErrCannotModifySelf)
+
+	_, err = svc.Disable(2, 8, 9)
+	require.ErrorIs(t, err, services.ErrLastAdmin)
+
+	_, err = svc.Enable(2, 8)
+	require.ErrorIs(t, err, services.ErrStaffNotDisabled)
+
+	staff, err := svc.Disable(2, 7, 8)
+	require.NoError(t, err)
+	require.Equal(t, models.StaffDisabled, staff.Status)
+
+	staff, err = svc.Enable(2, 8)
+	require.NoError(t, err)
+	require.Equal(t, models.StaffActive, staff.Status)
+
+	require.ErrorIs(t, svc.Delete(5, 7, 8), services.ErrStaffNotFound)
+	require.NoError(t, svc.Delete(2, 7, 8))
+	_, err = svc.Get(2, 8)
+	require.ErrorIs(t, err, services.ErrStaffNotFound)
+}
+
+func TestStaffUpdateProfile_TrimsAndValidates(t *testing.T) {
+	repo := newAdminStaffRepo(1)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffActive, Department: "ER"}
+	svc := services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{})
+
+	long := strings.Repeat("x", 65)
+	_, err := svc.UpdateProfile(2, 8, repositories.StaffProfile{LicenseNumber: &long})
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Empty(t, repo.profiles)
+
+	name := "  Dr. Somchai  "
+	staff, err := svc.UpdateProfile(2, 8, repositories.StaffProfile{DisplayName: &name})
+	require.NoError(t, err)
+	require.Equal(t, "Dr. Somchai", staff.DisplayName)
+	require.Equal(t, "ER", staff.Department)
+	require.Equal(t, "Dr. Somchai", *repo.profiles[8].DisplayName)
+	require.Nil(t, repo.profiles[8].Department)
+}
+
+func TestStaffAdminHandler_Management(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newAdminStaffRepo(2)
+	repo.staff[8] = &models.Staff{ID: 8, HospitalID: 2, Status: models.StaffActive}
+	repo.staff[9] = &models.Staff{ID: 9, HospitalID: 5, Status: models.StaffActive}
+	h := handlers.NewStaffAdminHandler(services.NewStaffService(repo, mockRoleRepo{}, &mockInvitationRepo{}, &mockPasswordRepo{}))
+	r := gin.New()
+	withAdmin := func(c *gin.Context) {
+		c.Set("hospital_id", uint(2))
+		c.Set(string(middleware.StaffContextKey), &middleware.StaffClaims{StaffID: 7, HospitalID: 2})
+	}
+	r.GET("/api/h/staff", withAdmin, h.List)
+	r.GET("/api/h/staff/me", withAdmin, h.Me)
+	r.GET("/api/h/staff/:staff_id", withAdmin, h.Get)
+	r.PATCH("/api/h/staff/:staff_id", withAdmin, h.UpdateProfile)
+	r.POST("/api/h/staff/:staff_id/disable", withAdmin, h.Disable)
+	r.POST("/api/h/staff/:staff_id/enable", withAdmin, h.Enable)
+	r.DELETE("/api/h/staff/:staff_id", withAdmin, h.Delete)
+
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/api/h/staff", ``, http.StatusOK},
+		{http.MethodGet, "/api/h/staff?status=retired", ``, http.StatusBadRequest},
+		{http.MethodGet, "/api/h/staff/me", ``, http.StatusOK},
+		{http.MethodGet, "/api/h/staff/8", ``, http.StatusOK},
+		{http.MethodGet, "/api/h/staff/9", ``, http.StatusNotFound},
+		{http.MethodPatch, "/api/h/staff/8", `{"department":"Cardiology"}`, http.StatusOK},
+		{http.MethodPatch, "/api/h/staff/8", `{"department":"` + strings.Repeat("x", 129) + `"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/h/staff/7/disable", ``, http.StatusConflict},
+		{http.MethodPost, "/api/h/staff/8/enable", ``, http.StatusConflict},
+		{http.MethodPost, "/api/h/staff/8/disable", ``, http.StatusOK},
+		{http.MethodPost, "/api/h/staff/8/enable", ``, http.StatusOK},
+		{http.MethodDelete, "/api/h/staff/7", ``, http.StatusConflict},
+		{http.MethodDelete, "/api/h/staff/8", ``, http.StatusNoContent},
+		{http.MethodDelete, "/api/h/staff/8", ``, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(rr, req)
+		require.Equal(t, tc.want, rr.Code, "%s %s %s", tc.method, tc.path, tc.body)
+	}
+}
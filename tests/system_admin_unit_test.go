@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/config"
+	"agnos_candidate_assignment/handlers"
+	"agnos_candidate_assignment/middleware"
+	"agnos_candidate_assignment/models"
+	"agnos_candidate_assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockSystemAdminRepo struct {
+	admins []*models.SystemAdmin
+}
+
+func (m *mockSystemAdminRepo) Create(admin *models.SystemAdmin) error {
+	admin.ID = uint(len(m.admins) + 1)
+	m.admins = append(m.admins, admin)
+	return nil
+}
+
+func (m *mockSystemAdminRepo) GetByID(id uint) (*models.SystemAdmin, error) {
+	for _, a := range m.admins {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockSystemAdminRepo) FindByUserName(userName string) (*models.SystemAdmin, error) {
+	for _, a := range m.admins {
+		if a.UserName == userName {
+			return a, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockSystemAdminRepo) Count() (int64, error) {
+	return int64(len(m.admins)), nil
+}
+
+type mockDenylist struct {
+	denied map[string]time.Time
+}
+
+func (m *mockDenylist) Deny(jti string, expiresAt time.Time) error {
+	if m.denied == nil {
+		m.denied = map[string]time.Time{}
+	}
+	m.denied[jti] = expiresAt
+	return nil
+}
+
+func (m *mockDenylist) IsDenied(jti string) (bool, error) {
+	_, ok := m.denied[jti]
+	return ok, nil
+}
+
+func TestSystemAdmin_BootstrapOnce(t *testing.T) {
+	repo := &mockSystemAdminRepo{}
+	conf := &config.Config{JWTIssuer: "agnos", JWTAudience: "agnos-api", SystemAdminUsername: "root", SystemAdminPassword: "pw"}
+	svc := services.NewSystemAdminService(repo, &mockDenylist{}, newTestKeys(t), conf)
+
+	created, err := svc.EnsureBootstrap()
+	require.NoError(t, err)
+	require.True(t, created)
+	require.NotEqual(t, "pw", repo.admins[0].PasswordHash)
+
+	created, err = svc.EnsureBootstrap()
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Len(t, repo.admins, 1)
+
+	_, _, err = svc.Login("root", "wrong", "10.0.0.1")
+	require.ErrorIs(t, err, services.ErrInvalidCredentials)
+}
+
+func TestSystemAdminAuth_AcceptsOnlyAdminTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockSystemAdminRepo{}
+	conf := &config.Config{JWTIssuer: "agnos", JWTAudience: "agnos-api", SystemAdminUsername: "root", SystemAdminPassword: "pw"}
+	keys := newTestKeys(t)
+	svc := services.NewSystemAdminService(repo, &mockDenylist{}, keys, conf)
+	_, err := svc.EnsureBootstrap()
+	require.NoError(t, err)
+
+	adminToken, _, err := svc.Login("root", "pw", "10.0.0.1")
+	require.NoError(t, err)
+	staffToken, err := keys.Sign(jwt.MapClaims{
+		"staff_id": 1, "hospital_id": 1, "role": models.RoleAdmin, "perms": models.AllPermissions,
+		"iss": conf.JWTIssuer, "aud": conf.JWTAudience, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	forgedScope, err := keys.Sign(jwt.MapClaims{
+		"admin_id": 1, "scope": models.SystemAdminScope, "jti": "forged",
+		"iss": conf.JWTIssuer, "aud": conf.JWTAudience, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/api/admin/hospitals", middleware.SystemAdminAuth(conf, keys, repo, &mockDenylist{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for token, want := range map[string]int{adminToken: http.StatusOK, staffToken: http.StatusUnauthorized, forgedScope: http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/hospitals", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, want, rr.Code)
+	}
+}
+
+func TestSystemAdmin_LoginIsThrottled(t *testing.T) {
+	repo := &mockSystemAdminRepo{}
+	conf := &config.Config{JWTIssuer: "agnos", JWTAudience: "agnos-api", SystemAdminUsername: "root", SystemAdminPassword: "pw",
+		BcryptCost: 4, LoginMaxFailures: 5, LoginIPMaxFailures: 50, LoginLockout: time.Hour}
+	svc := services.NewSystemAdminService(repo, &mockDenylist{}, newTestKeys(t), conf)
+	_, err := svc.EnsureBootstrap()
+	require.NoError(t, err)
+
+	// Known and unknown usernames are locked alike, so a lockout does not
+	// reveal which exist; even the right password waits out the lock.
+	for _, username := range []string{"root", "nobody"} {
+		var throttled *services.LoginThrottledError
+		for i := 0; i < 10 && throttled == nil; i++ {
+			_, _, err = svc.Login(username, "wrong", "10.0.0.1")
+			if !errors.As(err, &throttled) {
+				require.ErrorIs(t, err, services.ErrInvalidCredentials)
+			}
+		}
+		require.NotNil(t, throttled, username)
+		require.Positive(t, throttled.RetryAfter)
+	}
+	_, _, err = svc.Login("root", "pw", "10.0.0.2")
+	var throttled *services.LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+}
+
+type failingAdminRepo struct {
+	mockSystemAdminRepo
+}
+
+func (m *failingAdminRepo) FindByUserName(userName string) (*models.SystemAdmin, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSystemAdmin_LoginReturnsDatabaseErrors(t *testing.T) {
+	conf := &config.Config{JWTIssuer: "agnos", JWTAudience: "agnos-api", BcryptCost: 4}
+	svc := services.NewSystemAdminService(&failingAdminRepo{}, &mockDenylist{}, newTestKeys(t), conf)
+
+	_, _, err := svc.Login("root", "pw", "10.0.0.1")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, services.ErrInvalidCredentials)
+}
+
+func TestSystemAdminAuth_RefusesLoggedOutTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockSystemAdminRepo{}
+	denylist := &mockDenylist{}
+	conf := &config.Config{JWTIssuer: "agnos", JWTAudience: "agnos-api", SystemAdminUsername: "root", SystemAdminPassword: "pw", BcryptCost: 4}
+	keys := newTestKeys(t)
+	svc := services.NewSystemAdminService(repo, denylist, keys, conf)
+	_, err := svc.EnsureBootstrap()
+	require.NoError(t, err)
+	token, _, err := svc.Login("root", "pw", "10.0.0.1")
+	require.NoError(t, err)
+
+	adminHandler := handlers.NewAdminHandler(svc)
+	auth := middleware.SystemAdminAuth(conf, keys, repo, denylist)
+	r := gin.New()
+	r.POST("/api/admin/logout", auth, adminHandler.Logout)
+	r.GET("/api/admin/hospitals", auth, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	call := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "/api/admin/hospitals"))
+	require.Equal(t, http.StatusNoContent, call(http.MethodPost, "/api/admin/logout"))
+	require.Len(t, denylist.denied, 1)
+	require.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/admin/hospitals"))
+}
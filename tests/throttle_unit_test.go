@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/throttle"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottlePolicy_Delay(t *testing.T) {
+	p := throttle.Policy{FreeAttempts: 3, MaxFailures: 10, Lockout: 15 * time.Minute}
+
+	for n := 0; n <= 3; n++ {
+		require.Zero(t, p.Delay(n), "failures=%d", n)
+	}
+	require.Equal(t, time.Second, p.Delay(4))
+	require.Equal(t, 2*time.Second, p.Delay(5))
+	require.Equal(t, 32*time.Second, p.Delay(9))
+	require.Equal(t, 15*time.Minute, p.Delay(10))
+	require.Equal(t, 15*time.Minute, p.Delay(100))
+
+	short := throttle.Policy{FreeAttempts: 0, MaxFailures: 100, Lockout: 10 * time.Second}
+	require.Equal(t, 10*time.Second, short.Delay(20))
+}
+
+func TestThrottleTracker(t *testing.T) {
+	tr := throttle.NewTracker(throttle.Policy{FreeAttempts: 1, MaxFailures: 3, Lockout: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+
+	require.Zero(t, tr.Fail("ip", now))
+	require.Zero(t, tr.Wait("ip", now))
+
+	require.Equal(t, time.Second, tr.Fail("ip", now))
+	require.Equal(t, time.Second, tr.Wait("ip", now))
+	require.Zero(t, tr.Wait("ip", now.Add(time.Second)))
+	require.Zero(t, tr.Wait("other", now))
+
+	require.Equal(t, time.Minute, tr.Fail("ip", now.Add(2*time.Second)))
+	require.Equal(t, 30*time.Second, tr.Wait("ip", now.Add(32*time.Second)))
+
+	// Once the lock has passed and the failures are forgotten, the count
+	// starts over.
+	later := now.Add(3 * time.Minute)
+	require.Zero(t, tr.Wait("ip", later))
+	require.Zero(t, tr.Fail("ip", later))
+
+	tr.Reset("ip")
+	require.Zero(t, tr.Fail("ip", later))
+}
+
+func TestThrottleLimiter(t *testing.T) {
+	l := throttle.NewLimiter(2, time.Minute)
+	now := time.Now()
+
+	require.Zero(t, l.Allow("10.0.0.1", now))
+	require.Zero(t, l.Allow("10.0.0.1", now.Add(time.Second)))
+	require.Equal(t, 50*time.Second, l.Allow("10.0.0.1", now.Add(10*time.Second)))
+	require.Zero(t, l.Allow("10.0.0.2", now.Add(10*time.Second)))
+
+	require.Zero(t, l.Allow("10.0.0.1", now.Add(time.Minute)))
+}
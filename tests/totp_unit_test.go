@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/totp"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		code, err := totp.CodeAt(rfc6238Secret, totp.Step(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tc.code, code, "T=%d", tc.unix)
+	}
+}
+
+func TestTOTP_ValidateWithinSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := totp.Validate(rfc6238Secret, "081804", now, 1)
+	require.True(t, ok)
+	require.Equal(t, totp.Step(now), step)
+
+	// The previous step's code is still accepted, one further back is not.
+	prev, _ := totp.CodeAt(rfc6238Secret, totp.Step(now)-1)
+	step, ok = totp.Validate(rfc6238Secret, prev, now, 1)
+	require.True(t, ok)
+	require.Equal(t, totp.Step(now)-1, step)
+
+	old, _ := totp.CodeAt(rfc6238Secret, totp.Step(now)-2)
+	_, ok = totp.Validate(rfc6238Secret, old, now, 1)
+	require.False(t, ok)
+
+	_, ok = totp.Validate(rfc6238Secret, "12345", now, 1)
+	require.False(t, ok)
+}
+
+func TestTOTP_GenerateSecretAndURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	code, err := totp.CodeAt(secret, 1)
+	require.NoError(t, err)
+	require.Len(t, code, totp.Digits)
+
+	u, err := url.Parse(totp.URI("Agnos", "alice@Hospital A", secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.True(t, strings.HasPrefix(u.Path, "/Agnos:alice@Hospital A"))
+	require.Equal(t, secret, u.Query().Get("secret"))
+	require.Equal(t, "Agnos", u.Query().Get("issuer"))
+}
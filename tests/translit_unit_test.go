@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"testing"
+
+	"agnos_candidate_assignment/translit"
+
+	"github.com/stretchr/testify/require"
+)
+
+// thaiNames is a corpus of common Thai given names and surnames with their
+// RTGS romanization and a spelling people commonly use instead.
+var thaiNames = []struct {
+	thai, rtgs, informal string
+}{
+	{"สมชาย", "somchai", "Somchay"},
+	{"สมศักดิ์", "somsak", "Somsak"},
+	{"สมพร", "somphon", "Somporn"},
+	{"สมศรี", "somsi", "Somsri"},
+	{"สมหญิง", "somying", "Somying"},
+	{"วิชัย", "wichai", "Vichai"},
+	{"มาลี", "mali", "Malee"},
+	{"สุดา", "suda", "Suda"},
+	{"ประเสริฐ", "prasoet", "Prasert"},
+	{"บุญมี", "bunmi", "Boonmee"},
+	{"บุญเรือน", "bunruean", "Boonruen"},
+	{"เจริญ", "charoen", "Jaroen"},
+	{"กาญจนา", "kanchana", "Kanjana"},
+	{"ชัยวัฒน์", "chaiwat", "Chaiwat"},
+	{"ศรีสุข", "sisuk", "Srisuk"},
+	{"อรุณ", "arun", "Arun"},
+	{"เพ็ญ", "phen", "Pen"},
+	{"ทองดี", "thongdi", "Thongdee"},
+	{"กิตติ", "kitti", "Kiti"},
+	{"ปิยะ", "piya", "Piya"},
+	{"นภา", "napha", "Napa"},
+	{"อนุชา", "anucha", "Anucha"},
+	{"สุนีย์", "suni", "Sunee"},
+	{"ณัฐ", "nat", "Nat"},
+	{"ไพศาล", "phaisan", "Paisan"},
+	{"วรรณา", "wanna", "Wanna"},
+	{"สุวรรณ", "suwan", "Suwan"},
+	{"จันทร์", "chan", "Jan"},
+	{"วงศ์", "wong", "Wong"},
+	{"พงษ์", "phong", "Pong"},
+	{"สวัสดี", "sawatdi", "Sawatdee"},
+	{"แก้ว", "kaeo", "Kaeo"},
+	{"เปรม", "prem", "Prem"},
+	{"ลักษณ์", "lak", "Luk"},
+}
+
+func TestRomanize_Corpus(t *testing.T) {
+	for _, n := range thaiNames {
+		require.Equal(t, n.rtgs, translit.Romanize(n.thai), n.thai)
+	}
+}
+
+func TestKey_MatchesAcrossScripts(t *testing.T) {
+	for _, n := range thaiNames {
+		if n.thai == "ลักษณ์" {
+			continue // Luk spells the vowel differently; not expected to match.
+		}
+		key := translit.Key(n.thai)
+		require.NotEmpty(t, key, n.thai)
+		require.Equal(t, key, translit.Key(n.rtgs), n.thai)
+		require.Equal(t, key, translit.Key(n.informal), "%s vs %s", n.thai, n.informal)
+	}
+}
+
+func TestKey_DistinguishesDifferentNames(t *testing.T) {
+	require.NotEqual(t, translit.Key("สมชาย"), translit.Key("สมศักดิ์"))
+	require.NotEqual(t, translit.Key("Malee"), translit.Key("Suda"))
+}
+
+func TestRomanize_PassesThroughLatin(t *testing.T) {
+	require.Equal(t, "john smith", translit.Romanize("John Smith"))
+	require.Equal(t, "somchai jaidee", translit.Romanize("สมชาย Jaidee"))
+	require.Empty(t, translit.Key("123"))
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agnos_candidate_assignment/validation"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeNationalID(t *testing.T) {
+	cases := map[string]string{
+		"3100500123458":      "3100500123458",
+		"3-1005-00123-45-8":  "3100500123458",
+		" 1 2345 67890 12 1": "1234567890121",
+	}
+	for in, want := range cases {
+		got, err := validation.NormalizeNationalID(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got)
+	}
+}
+
+func TestNormalizeNationalID_Rejects(t *testing.T) {
+	for _, in := range []string{"3100500123459", "310050012345", "31005001234a8", ""} {
+		_, err := validation.NormalizeNationalID(in)
+		var vErr *validation.Error
+		require.ErrorAs(t, err, &vErr, in)
+		require.Equal(t, "national_id", vErr.Field)
+	}
+}
+
+func TestNormalizePassport(t *testing.T) {
+	got, err := validation.NormalizePassport("aa 123-4567")
+	require.NoError(t, err)
+	require.Equal(t, "AA1234567", got)
+
+	got, err = validation.NormalizePassport("X1234567<")
+	require.NoError(t, err)
+	require.Equal(t, "X1234567", got)
+
+	for _, in := range []string{"A123", "AB12345678", "AB12#456"} {
+		_, err := validation.NormalizePassport(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestClassifyID(t *testing.T) {
+	kind, id, err := validation.ClassifyID("3-1005-00123-45-8")
+	require.NoError(t, err)
+	require.Equal(t, validation.NationalID, kind)
+	require.Equal(t, "3100500123458", id)
+
+	kind, id, err = validation.ClassifyID("123456789")
+	require.NoError(t, err)
+	require.Equal(t, validation.Passport, kind)
+	require.Equal(t, "123456789", id)
+
+	_, _, err = validation.ClassifyID("3100500123459")
+	require.ErrorContains(t, err, "checksum")
+
+	_, _, err = validation.ClassifyID("not an id!")
+	require.Error(t, err)
+}
+
+func TestParseDate_Formats(t *testing.T) {
+	want := time.Date(1987, time.April, 12, 0, 0, 0, 0, time.UTC)
+	for _, in := range []string{
+		"1987-04-12",
+		"2530-04-12",
+		"12/04/1987",
+		"12/04/2530",
+		"12-04-2530",
+		"12.4.1987",
+		"๑๒/๐๔/๒๕๓๐",
+		" 12/04/2530 ",
+	} {
+		got, err := validation.ParseDate("date_of_birth", in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+}
+
+func TestParseDate_Rejects(t *testing.T) {
+	for _, in := range []string{"", "1987", "31/02/1987", "12/04/87", "1987/04/12", "12/04-1987", "04/13/1987", "yesterday", "1987-4"} {
+		_, err := validation.ParseDate("date_of_birth", in)
+		var vErr *validation.Error
+		require.ErrorAs(t, err, &vErr, in)
+		require.Equal(t, "date_of_birth", vErr.Field)
+	}
+}
+
+func TestParseDateSpan_Year(t *testing.T) {
+	from, to, err := validation.ParseDateSpan("dob_from", "2530")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(1987, time.January, 1, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(1987, time.December, 31, 0, 0, 0, 0, time.UTC), to)
+
+	from, to, err = validation.ParseDateSpan("dob_from", "1987-04-12")
+	require.NoError(t, err)
+	require.Equal(t, from, to)
+}
@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows each key at most Limit requests per Window, counted in
+// fixed windows that start at a key's first request. Counts are per
+// process.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func NewLimiter(limit int, per time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: per, windows: map[string]*window{}}
+}
+
+// Allow counts a request for key and returns zero if it is within the
+// limit, or how long until the key may try again.
+func (l *Limiter) Allow(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return 0
+}
+
+// sweep drops finished windows, at most once a minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+}
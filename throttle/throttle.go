@@ -0,0 +1,117 @@
+// Package throttle slows down repeated failures, such as password guesses:
+// the first few are free, each later one doubles the wait before the next
+// attempt, and enough of them lock the key out for a while. Limiter caps
+// plain request rates.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy describes how failures are penalized. Failures stop counting once
+// there has been none for Lockout.
+type Policy struct {
+	// FreeAttempts failures in a row carry no delay.
+	FreeAttempts int
+	// MaxFailures failures in a row lock the key for Lockout.
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+// Delay returns how long to refuse attempts after the given number of
+// consecutive failures: one second after the first failure beyond
+// FreeAttempts, doubling with each further one, and Lockout from MaxFailures
+// on.
+func (p Policy) Delay(failures int) time.Duration {
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	extra := failures - p.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+	if extra > 30 {
+		return p.Lockout
+	}
+	d := time.Second << (extra - 1)
+	if d > p.Lockout {
+		return p.Lockout
+	}
+	return d
+}
+
+// Expired reports whether a run of failures whose latest was at last has
+// been forgotten by now.
+func (p Policy) Expired(last, now time.Time) bool {
+	return now.Sub(last) > p.Lockout
+}
+
+// Tracker counts failures per key in memory, for keys with no other place
+// to keep them, such as client IPs. Counts are per process.
+type Tracker struct {
+	policy Policy
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	failures     int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{policy: policy, entries: map[string]*entry{}}
+}
+
+// Wait returns how much longer key is refused, or zero.
+func (t *Tracker) Wait(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key]
+	if !ok || !now.Before(e.blockedUntil) {
+		return 0
+	}
+	return e.blockedUntil.Sub(now)
+}
+
+// Fail records a failure for key and returns the wait it now imposes.
+func (t *Tracker) Fail(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(now)
+
+	e, ok := t.entries[key]
+	if !ok || t.policy.Expired(e.last, now) {
+		e = &entry{}
+		t.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	delay := t.policy.Delay(e.failures)
+	e.blockedUntil = now.Add(delay)
+	return delay
+}
+
+// Reset forgets key's failures.
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	delete(t.entries, key)
+	t.mu.Unlock()
+}
+
+// sweep drops forgotten entries, at most once a minute.
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+	for key, e := range t.entries {
+		if t.policy.Expired(e.last, now) && !now.Before(e.blockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
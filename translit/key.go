@@ -0,0 +1,82 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// keyRewrites folds common informal romanizations onto the same spelling as
+// RTGS, applied in order: "Boonmee" and "bunmi", "Somporn" and "somphon",
+// "Vichai" and "wichai" all share a key.
+var keyRewrites = []struct{ from, to string }{
+	{"ph", "p"},
+	{"th", "t"},
+	{"kh", "k"},
+	{"ch", "c"},
+	{"j", "c"},
+	{"v", "w"},
+	{"uea", "ue"},
+	{"ee", "i"},
+	{"oo", "u"},
+	{"ou", "u"},
+	{"oe", "e"},
+	{"ay", "ai"},
+	{"oy", "oi"},
+	{"rn", "n"},
+	{"sr", "s"},
+}
+
+// Key returns a phonetic key for a name in either script. Thai is romanized
+// first; the result is lower-cased, reduced to ASCII letters, folded with
+// keyRewrites and stripped of doubled letters. Names that sound alike to a
+// Thai speaker usually share a key, though the mapping is lossy by design.
+func Key(name string) string {
+	s := Romanize(name)
+
+	var b strings.Builder
+	for _, r := range s {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	s = b.String()
+
+	for _, rw := range keyRewrites {
+		s = strings.ReplaceAll(s, rw.from, rw.to)
+	}
+	// "Prasert" for ประเสริฐ: an r closing a syllable is not pronounced.
+	s = dropSilentR(s)
+
+	b.Reset()
+	var prev rune
+	for _, r := range s {
+		if r == prev {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	s = b.String()
+
+	// A final d is pronounced t.
+	if strings.HasSuffix(s, "d") {
+		s = strings.TrimSuffix(s, "d") + "t"
+	}
+	return s
+}
+
+func isVowelLetter(r byte) bool {
+	return strings.IndexByte("aeiou", r) >= 0
+}
+
+// dropSilentR removes an r that follows a vowel and precedes a consonant.
+func dropSilentR(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'r' && i > 0 && i+1 < len(s) && isVowelLetter(s[i-1]) && !isVowelLetter(s[i+1]) {
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,434 @@
+// Package translit romanizes Thai script following the Royal Thai General
+// System (RTGS) and derives phonetic keys that let a romanized query match a
+// Thai-script name and vice versa.
+//
+// Thai spelling does not mark every vowel, so romanization is rule based and
+// approximate: names whose implicit vowels break the usual rules (e.g.
+// ธนพล, thanaphon) romanize differently from their official spelling.
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	saraA          = 'ะ'
+	maiHanAkat     = 'ั'
+	saraAa         = 'า'
+	saraAm         = 'ำ'
+	saraI          = 'ิ'
+	saraIi         = 'ี'
+	saraUe         = 'ึ'
+	saraUee        = 'ื'
+	saraU          = 'ุ'
+	saraUu         = 'ู'
+	saraE          = 'เ'
+	saraAe         = 'แ'
+	saraO          = 'โ'
+	saraAiMaimuan  = 'ใ'
+	saraAiMaimalai = 'ไ'
+	maiYamok       = 'ๆ'
+	maiTaikhu      = '็'
+	thanthakhat    = '์'
+	oAng           = 'อ'
+	woWaen         = 'ว'
+	yoYak          = 'ย'
+	roRua          = 'ร'
+	loLing         = 'ล'
+	hoHip          = 'ห'
+	ruEe           = 'ฤ'
+)
+
+// initials and finals give the RTGS value of each consonant at the start
+// and at the end of a syllable.
+var initials = map[rune]string{
+	'ก': "k", 'ข': "kh", 'ฃ': "kh", 'ค': "kh", 'ฅ': "kh", 'ฆ': "kh", 'ง': "ng",
+	'จ': "ch", 'ฉ': "ch", 'ช': "ch", 'ซ': "s", 'ฌ': "ch", 'ญ': "y",
+	'ฎ': "d", 'ฏ': "t", 'ฐ': "th", 'ฑ': "th", 'ฒ': "th", 'ณ': "n",
+	'ด': "d", 'ต': "t", 'ถ': "th", 'ท': "th", 'ธ': "th", 'น': "n",
+	'บ': "b", 'ป': "p", 'ผ': "ph", 'ฝ': "f", 'พ': "ph", 'ฟ': "f", 'ภ': "ph", 'ม': "m",
+	'ย': "y", 'ร': "r", 'ล': "l", 'ว': "w", 'ศ': "s", 'ษ': "s", 'ส': "s",
+	'ห': "h", 'ฬ': "l", 'อ': "", 'ฮ': "h", 'ฦ': "l",
+}
+
+var finals = map[rune]string{
+	'ก': "k", 'ข': "k", 'ฃ': "k", 'ค': "k", 'ฅ': "k", 'ฆ': "k", 'ง': "ng",
+	'จ': "t", 'ฉ': "t", 'ช': "t", 'ซ': "t", 'ฌ': "t", 'ญ': "n",
+	'ฎ': "t", 'ฏ': "t", 'ฐ': "t", 'ฑ': "t", 'ฒ': "t", 'ณ': "n",
+	'ด': "t", 'ต': "t", 'ถ': "t", 'ท': "t", 'ธ': "t", 'น': "n",
+	'บ': "p", 'ป': "p", 'ผ': "p", 'ฝ': "p", 'พ': "p", 'ฟ': "p", 'ภ': "p", 'ม': "m",
+	'ย': "i", 'ร': "n", 'ล': "n", 'ว': "o", 'ศ': "t", 'ษ': "t", 'ส': "t",
+	'ห': "", 'ฬ': "n", 'อ': "", 'ฮ': "",
+}
+
+// clusterHeads can take ร, ล or ว as a second onset consonant.
+var clusterHeads = map[rune]bool{
+	'ก': true, 'ข': true, 'ค': true, 'ต': true, 'ป': true, 'ผ': true,
+	'พ': true, 'บ': true, 'ด': true, 'ท': true, 'ฟ': true, 'ศ': true, 'ส': true,
+}
+
+// silentHTargets are the sonorants a leading ห only modifies the tone of.
+var silentHTargets = map[rune]bool{
+	'ง': true, 'ญ': true, 'น': true, 'ม': true, 'ย': true, 'ร': true, 'ล': true, 'ว': true,
+}
+
+func isConsonant(r rune) bool {
+	return r >= 'ก' && r <= 'ฮ' && r != ruEe && r != 'ฦ'
+}
+
+func isLeadVowel(r rune) bool {
+	return r >= saraE && r <= saraAiMaimalai
+}
+
+func isToneMark(r rune) bool {
+	return r >= '่' && r <= '๋'
+}
+
+// isDependentVowel reports vowel signs that can only follow a consonant, which
+// makes that consonant the onset of a syllable.
+func isDependentVowel(r rune) bool {
+	switch r {
+	case saraA, maiHanAkat, saraAa, saraAm, saraI, saraIi, saraUe, saraUee, saraU, saraUu, maiTaikhu:
+		return true
+	}
+	return false
+}
+
+func isThai(r rune) bool {
+	return r >= 0x0E00 && r <= 0x0E7F
+}
+
+// Romanize converts Thai script to lower-case RTGS. Non-Thai text is
+// lower-cased and passed through.
+func Romanize(s string) string {
+	var out strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if !isThai(runes[i]) {
+			out.WriteRune(unicode.ToLower(runes[i]))
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isThai(runes[j]) {
+			j++
+		}
+		out.WriteString(romanizeWord(runes[i:j]))
+		i = j
+	}
+	return out.String()
+}
+
+func romanizeWord(word []rune) string {
+	r := prepare(word)
+	p := &parser{r: r}
+	for p.i < len(p.r) {
+		p.syllable()
+	}
+	return p.out.String()
+}
+
+// prepare drops tone marks and repetition signs, maps Thai digits and removes
+// letters silenced by the thanthakhat (์).
+func prepare(word []rune) []rune {
+	r := make([]rune, 0, len(word))
+	for _, c := range word {
+		switch {
+		case isToneMark(c), c == maiYamok, c == 'ฯ':
+			continue
+		case c >= '๐' && c <= '๙':
+			r = append(r, '0'+(c-'๐'))
+			continue
+		}
+		r = append(r, c)
+	}
+
+	for k := 0; k < len(r); k++ {
+		if r[k] != thanthakhat {
+			continue
+		}
+		start := k - 1
+		if start >= 0 && (r[start] == saraI || r[start] == saraU) {
+			start--
+		}
+		if start < 0 {
+			r = append(r[:k], r[k+1:]...)
+			k--
+			continue
+		}
+		// A consonant left stranded after a final (the ท of จันทร์, the ษ
+		// of ลักษณ์) is silent too.
+		if start >= 3 && isConsonant(r[start-1]) && isConsonant(r[start-2]) && (isDependentVowel(r[start-3]) || r[start-3] == saraAa) {
+			start--
+		}
+		r = append(r[:start], r[k+1:]...)
+		k = start - 1
+	}
+	return r
+}
+
+type parser struct {
+	r   []rune
+	i   int
+	out strings.Builder
+}
+
+func (p *parser) at(i int) rune {
+	if i < 0 || i >= len(p.r) {
+		return 0
+	}
+	return p.r[i]
+}
+
+// startsSyllable reports whether the consonant at i is the onset of a new
+// syllable rather than the final of the current one.
+func (p *parser) startsSyllable(i int) bool {
+	next := p.at(i + 1)
+	if isDependentVowel(next) {
+		return true
+	}
+	// a consonant followed by -อ with nothing after, or by a cluster that
+	// itself carries a vowel, opens a syllable.
+	if next == oAng && !isConsonant(p.at(i+2)) {
+		return true
+	}
+	if (next == roRua || next == loLing) && clusterHeads[p.r[i]] && isDependentVowel(p.at(i+2)) {
+		return true
+	}
+	// -รร- and a medial ว (สวน) both need this consonant as their onset.
+	if next == roRua && p.at(i+2) == roRua {
+		return true
+	}
+	if next == woWaen && isConsonant(p.at(i+2)) && !isDependentVowel(p.at(i+3)) {
+		return true
+	}
+	return false
+}
+
+// combinesWithE reports vowel signs that complete a เ- vowel (เ-ิ, เ-ีย,
+// เ-ือ, เ-็).
+func combinesWithE(r rune) bool {
+	return r == saraI || r == saraIi || r == saraUee || r == maiTaikhu
+}
+
+func (p *parser) syllable() {
+	c := p.r[p.i]
+	switch {
+	case c >= '0' && c <= '9':
+		p.out.WriteRune(c)
+		p.i++
+		return
+	case c == ruEe:
+		p.i++
+		if isConsonant(p.at(p.i)) && !p.startsSyllable(p.i) {
+			p.out.WriteString("ri")
+			p.final("i")
+		} else {
+			p.out.WriteString("rue")
+		}
+		return
+	}
+
+	var lead rune
+	if isLeadVowel(c) {
+		lead = c
+		p.i++
+	}
+	if !isConsonant(p.at(p.i)) {
+		p.i++
+		return
+	}
+
+	// With a lead vowel, an onset followed by a consonant that carries its
+	// own vowel sign is a separate open syllable (เจริญ = cha-roen).
+	if lead == saraE && isConsonant(p.at(p.i+1)) && !p.isCluster(p.i) && combinesWithE(p.at(p.i+2)) {
+		p.out.WriteString(initials[p.r[p.i]] + "a")
+		p.i++
+	}
+
+	p.onset()
+	vowel, closed := p.vowel(lead)
+	p.out.WriteString(vowel)
+	if closed {
+		p.final(vowel)
+	}
+}
+
+// isCluster reports whether the consonants at i and i+1 form a two-consonant
+// onset such as กร, ปล or กว.
+func (p *parser) isCluster(i int) bool {
+	first, second := p.at(i), p.at(i+1)
+	if !clusterHeads[first] {
+		return false
+	}
+	switch second {
+	case roRua, loLing:
+		next := p.at(i + 2)
+		return next != 0 && next != roRua && (isDependentVowel(next) || isLeadVowel(p.at(i-1)) || isConsonant(next) || next == oAng)
+	case woWaen:
+		return (first == 'ก' || first == 'ข' || first == 'ค') && isDependentVowel(p.at(i+2))
+	}
+	return false
+}
+
+func (p *parser) onset() {
+	first := p.r[p.i]
+	p.i++
+
+	switch {
+	case first == hoHip && silentHTargets[p.at(p.i)] && p.at(p.i+1) != 0:
+		p.out.WriteString(initials[p.r[p.i]])
+		p.i++
+		return
+	case first == oAng && p.at(p.i) == yoYak && p.at(p.i+1) != 0:
+		p.out.WriteString("y")
+		p.i++
+		return
+	}
+
+	if p.isCluster(p.i - 1) {
+		second := p.r[p.i]
+		p.i++
+		switch {
+		case second == roRua && (first == 'ท' || first == 'ศ' || first == 'ส'):
+			p.out.WriteString("s")
+		default:
+			p.out.WriteString(initials[first] + initials[second])
+		}
+		return
+	}
+	p.out.WriteString(initials[first])
+}
+
+// vowel reads the vowel of the current syllable and reports whether it may
+// be followed by a final consonant.
+func (p *parser) vowel(lead rune) (string, bool) {
+	c, next := p.at(p.i), p.at(p.i+1)
+	skipA := func() {
+		if p.at(p.i) == saraA {
+			p.i++
+		}
+	}
+
+	switch lead {
+	case saraE:
+		switch {
+		case c == saraIi && next == yoYak:
+			p.i += 2
+			skipA()
+			return "ia", true
+		case c == saraUee && next == oAng:
+			p.i += 2
+			skipA()
+			return "uea", true
+		case c == saraAa && next == saraA:
+			p.i += 2
+			return "o", false
+		case c == saraAa:
+			p.i++
+			return "ao", false
+		case c == saraI:
+			p.i++
+			return "oe", true
+		case c == maiTaikhu:
+			p.i++
+			return "e", true
+		case c == oAng:
+			p.i++
+			skipA()
+			return "oe", false
+		case c == saraA:
+			p.i++
+			return "e", false
+		case c == yoYak && !p.startsSyllable(p.i):
+			p.i++
+			return "oei", false
+		}
+		return "e", true
+	case saraAe:
+		switch c {
+		case maiTaikhu:
+			p.i++
+		case saraA:
+			p.i++
+			return "ae", false
+		}
+		return "ae", true
+	case saraO:
+		if c == saraA {
+			p.i++
+			return "o", false
+		}
+		return "o", true
+	case saraAiMaimuan, saraAiMaimalai:
+		return "ai", true
+	}
+
+	switch {
+	case c == maiHanAkat && next == woWaen:
+		p.i += 2
+		skipA()
+		return "ua", true
+	case c == maiHanAkat:
+		p.i++
+		return "a", true
+	case c == saraAa:
+		p.i++
+		return "a", true
+	case c == saraAm:
+		p.i++
+		return "am", false
+	case c == saraA:
+		p.i++
+		return "a", false
+	case c == saraI, c == saraIi:
+		p.i++
+		return "i", true
+	case c == saraUe:
+		p.i++
+		return "ue", true
+	case c == saraUee:
+		p.i++
+		if p.at(p.i) == oAng {
+			p.i++
+		}
+		return "ue", true
+	case c == saraU, c == saraUu:
+		p.i++
+		return "u", true
+	case c == maiTaikhu:
+		p.i++
+		return "o", true
+	case c == oAng && !isDependentVowel(next):
+		p.i++
+		return "o", true
+	case c == roRua && next == roRua:
+		p.i += 2
+		if isConsonant(p.at(p.i)) && !p.startsSyllable(p.i) {
+			return "a", true
+		}
+		return "an", false
+	case c == woWaen && isConsonant(next) && !p.startsSyllable(p.i+1):
+		p.i++
+		return "ua", true
+	case isConsonant(c) && !p.startsSyllable(p.i):
+		return "o", true
+	}
+	return "a", false
+}
+
+// final consumes a final consonant if one closes the syllable. Finals ย and
+// ว extend the vowel instead (ชาย chai, ข้าว khao).
+func (p *parser) final(vowel string) {
+	c := p.at(p.i)
+	if !isConsonant(c) || p.startsSyllable(p.i) {
+		return
+	}
+	p.i++
+	f := finals[c]
+	if c == yoYak && strings.HasSuffix(vowel, "i") {
+		return
+	}
+	p.out.WriteString(f)
+}
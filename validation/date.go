@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// buddhistEraOffset is the difference between Buddhist Era and Common Era
+// years (BE 2530 = CE 1987).
+const buddhistEraOffset = 543
+
+// minBuddhistEraYear is the smallest year read as Buddhist Era. No living
+// patient was born after CE 2400 or before BE 2400 (CE 1857), so the ranges
+// cannot be confused.
+const minBuddhistEraYear = 2400
+
+// ParseDate parses a calendar date the way staff type it: ISO 8601
+// (1987-04-12), or day first as DD/MM/YYYY, DD-MM-YYYY or DD.MM.YYYY, in
+// Arabic or Thai digits. Years from 2400 up are taken as Buddhist Era and
+// converted, so 12/04/2530 is 1987-04-12. field names the input in errors.
+func ParseDate(field, s string) (time.Time, error) {
+	from, to, err := ParseDateSpan(field, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !from.Equal(to) {
+		return time.Time{}, &Error{Field: field, Reason: "must be a full date, not a year"}
+	}
+	return from, nil
+}
+
+// ParseDateSpan is ParseDate that also accepts a bare year (1987 or 2530),
+// returning the first and last day it covers. A full date covers one day.
+func ParseDateSpan(field, s string) (time.Time, time.Time, error) {
+	s = thaiDigitsToArabic(strings.TrimSpace(s))
+	invalid := &Error{Field: field, Reason: "must be YYYY-MM-DD, DD/MM/YYYY or a year, in CE or BE"}
+
+	var parts []string
+	dayFirst := true
+	switch {
+	case isDigits(s):
+		parts = []string{s}
+	case strings.Count(s, "-") == 2 && len(s) >= 4 && isDigits(s[:4]):
+		parts = strings.Split(s, "-")
+		dayFirst = false
+	default:
+		sep := strings.IndexAny(s, "/-.")
+		if sep < 0 {
+			return time.Time{}, time.Time{}, invalid
+		}
+		parts = strings.Split(s, s[sep:sep+1])
+		if len(parts) != 3 {
+			return time.Time{}, time.Time{}, invalid
+		}
+	}
+
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || p == "" || !isDigits(p) {
+			return time.Time{}, time.Time{}, invalid
+		}
+		nums[i] = n
+	}
+
+	if len(nums) == 1 {
+		if len(parts[0]) != 4 {
+			return time.Time{}, time.Time{}, invalid
+		}
+		year := toCommonEra(nums[0])
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	year, month, day := nums[0], nums[1], nums[2]
+	yearDigits := len(parts[0])
+	if dayFirst {
+		year, day = nums[2], nums[0]
+		yearDigits = len(parts[2])
+	}
+	if yearDigits != 4 {
+		return time.Time{}, time.Time{}, invalid
+	}
+	year = toCommonEra(year)
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes 31/02 into March; reject instead.
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return time.Time{}, time.Time{}, &Error{Field: field, Reason: "is not a real calendar date"}
+	}
+	return t, t, nil
+}
+
+func toCommonEra(year int) int {
+	if year >= minBuddhistEraYear {
+		return year - buddhistEraOffset
+	}
+	return year
+}
+
+func thaiDigitsToArabic(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '๐' && r <= '๙' {
+			return '0' + (r - '๐')
+		}
+		return r
+	}, s)
+}
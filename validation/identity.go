@@ -0,0 +1,108 @@
+// Package validation checks and normalizes patient input: identity
+// documents and dates as staff type them.
+package validation
+
+import "strings"
+
+type IDKind string
+
+const (
+	NationalID IDKind = "national_id"
+	Passport   IDKind = "passport_id"
+)
+
+const (
+	passportMinLength = 6
+	passportMaxLength = 9
+)
+
+// Error explains why an identifier was rejected.
+type Error struct {
+	Field  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "invalid " + e.Field + ": " + e.Reason
+}
+
+// normalize drops the separators people commonly type inside IDs.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// NormalizeNationalID validates a 13-digit Thai citizen ID, accepting
+// dashes and spaces (e.g. 1-1007-00000-00-1), and returns the bare digits.
+func NormalizeNationalID(s string) (string, error) {
+	id := normalize(s)
+	if len(id) != 13 {
+		return "", &Error{Field: string(NationalID), Reason: "must be 13 digits"}
+	}
+	if !isDigits(id) {
+		return "", &Error{Field: string(NationalID), Reason: "must contain only digits"}
+	}
+	if nationalIDCheckDigit(id[:12]) != id[12]-'0' {
+		return "", &Error{Field: string(NationalID), Reason: "checksum mismatch"}
+	}
+	return id, nil
+}
+
+// NormalizePassport validates an ICAO 9303 passport number: 6 to 9 upper-case
+// letters or digits. MRZ filler characters ('<') are stripped.
+func NormalizePassport(s string) (string, error) {
+	id := strings.TrimRight(strings.ToUpper(normalize(s)), "<")
+	if len(id) < passportMinLength || len(id) > passportMaxLength {
+		return "", &Error{Field: string(Passport), Reason: "must be 6 to 9 characters"}
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return "", &Error{Field: string(Passport), Reason: "may only contain letters A-Z and digits"}
+		}
+	}
+	return id, nil
+}
+
+// ClassifyID decides whether a lookup key is a national ID or a passport
+// number. Any 13-digit value is treated as a national ID and must pass the
+// checksum; everything else must be a valid passport number.
+func ClassifyID(s string) (IDKind, string, error) {
+	id := normalize(s)
+	if id == "" {
+		return "", "", &Error{Field: "id", Reason: "must not be empty"}
+	}
+	if len(id) == 13 && isDigits(id) {
+		normalized, err := NormalizeNationalID(id)
+		return NationalID, normalized, err
+	}
+	normalized, err := NormalizePassport(id)
+	if err != nil {
+		return "", "", &Error{Field: "id", Reason: "is neither a 13-digit national ID nor a valid passport number"}
+	}
+	return Passport, normalized, nil
+}
+
+// nationalIDCheckDigit implements the mod-11 checksum: the first 12 digits
+// are weighted 13 down to 2 and the check digit is (11 - sum%11) % 10.
+func nationalIDCheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		sum += int(first12[i]-'0') * (13 - i)
+	}
+	return byte((11 - sum%11) % 10)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}